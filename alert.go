@@ -0,0 +1,303 @@
+package httptines
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// alertMetrics lists the metrics that can be used in alert rules.
+var alertMetrics = map[string]bool{
+	"fail_rate":     true,
+	"alive_proxies": true,
+	"rpm":           true,
+}
+
+// Alert represents a triggered or resolved alert rule.
+type Alert struct {
+	// Namespace is the run label
+	Namespace string `json:"namespace,omitempty"`
+	// Rule is the original rule definition
+	Rule string `json:"rule"`
+	// Value is the metric value at the moment of evaluation
+	Value float64 `json:"value"`
+	// Firing indicates whether the alert is triggered (true) or resolved (false)
+	Firing bool `json:"firing"`
+	// At is the time of evaluation
+	At time.Time `json:"at"`
+}
+
+// alertRule represents a parsed alert rule, e.g. "fail_rate > 30% for 5m".
+type alertRule struct {
+	raw    string
+	metric string
+	op     string
+	value  float64
+	dur    time.Duration
+	since  time.Time
+	firing bool
+}
+
+// parseAlertRule parses a rule in the form "<metric> <op> <value>[%] [for <duration>]".
+// Parameters:
+//   - s: Rule definition
+//
+// Returns:
+//   - *alertRule: Parsed rule
+//   - error: Any error that occurred during parsing
+func parseAlertRule(s string) (*alertRule, error) {
+	f := strings.Fields(s)
+	if len(f) != 3 && len(f) != 5 {
+		return nil, fmt.Errorf("invalid alert rule %q", s)
+	}
+
+	r := &alertRule{raw: s, metric: f[0], op: f[1]}
+
+	if !alertMetrics[r.metric] {
+		return nil, fmt.Errorf("unknown metric %q in alert rule %q", r.metric, s)
+	}
+
+	switch r.op {
+	case ">", "<", ">=", "<=":
+	default:
+		return nil, fmt.Errorf("unknown operator %q in alert rule %q", r.op, s)
+	}
+
+	v, err := strconv.ParseFloat(strings.TrimSuffix(f[2], "%"), 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value %q in alert rule %q", f[2], s)
+	}
+	r.value = v
+
+	if len(f) == 5 {
+		if f[3] != "for" {
+			return nil, fmt.Errorf("invalid alert rule %q", s)
+		}
+		if r.dur, err = time.ParseDuration(f[4]); err != nil {
+			return nil, fmt.Errorf("invalid duration %q in alert rule %q", f[4], s)
+		}
+	}
+
+	return r, nil
+}
+
+// parseAlertRules parses all rules, logging and skipping invalid ones.
+// Parameters:
+//   - rules: Rule definitions
+//
+// Returns:
+//   - []*alertRule: Parsed rules
+func (w *Worker) parseAlertRules(rules []string) []*alertRule {
+	var parsed []*alertRule
+	for _, s := range rules {
+		r, err := parseAlertRule(s)
+		if err != nil {
+			w.werr(err.Error())
+			continue
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed
+}
+
+// matches reports whether the value satisfies the rule condition.
+// Parameters:
+//   - v: Metric value
+//
+// Returns:
+//   - bool: True if the condition holds
+func (r *alertRule) matches(v float64) bool {
+	switch r.op {
+	case ">":
+		return v > r.value
+	case "<":
+		return v < r.value
+	case ">=":
+		return v >= r.value
+	default:
+		return v <= r.value
+	}
+}
+
+// evaluate updates the rule state with the current metric value.
+// Parameters:
+//   - v: Metric value
+//   - now: Time of evaluation
+//
+// Returns:
+//   - bool: True if the rule state has changed (triggered or resolved)
+func (r *alertRule) evaluate(v float64, now time.Time) bool {
+	if !r.matches(v) {
+		r.since = time.Time{}
+		if r.firing {
+			r.firing = false
+			return true
+		}
+		return false
+	}
+
+	if r.since.IsZero() {
+		r.since = now
+	}
+
+	if !r.firing && now.Sub(r.since) >= r.dur {
+		r.firing = true
+		return true
+	}
+
+	return false
+}
+
+// alertSample is a copy of the values alert rules are evaluated against.
+type alertSample struct {
+	at       time.Time // Time of the sample
+	pooled   bool      // Whether a check cycle has completed
+	alive    int       // Proxies in the pool
+	rpm      int       // Successful requests in the last minute
+	positive int       // Successful requests of all proxies so far
+	negative int       // Failed requests of all proxies so far
+}
+
+// sampleAlerts copies the values alert rules are evaluated against.
+// Parameters:
+//   - now: Time of the sample
+//
+// Returns:
+//   - alertSample: Current values
+func (w *Worker) sampleAlerts(now time.Time) alertSample {
+	w.stat.m.RLock()
+	positive, negative := w.stat.requests()
+	rpm := w.stat.rpm()
+	w.stat.m.RUnlock()
+
+	return alertSample{at: now, pooled: w.refresh.completed(), alive: w.servers.len(), rpm: rpm, positive: positive, negative: negative}
+}
+
+// sample returns the rule's metric. The fail rate is computed over the requests made
+// within the rule's duration, or since the previous sample without one.
+// Parameters:
+//   - samples: Samples in chronological order, the current one last
+//
+// Returns:
+//   - float64: Metric value
+//   - bool: False if the metric isn't known yet, e.g. the pool before the first check
+func (r *alertRule) sample(samples []alertSample) (float64, bool) {
+	cur := samples[len(samples)-1]
+	switch r.metric {
+	case "alive_proxies":
+		return float64(cur.alive), cur.pooled
+	case "rpm":
+		return float64(cur.rpm), true
+	}
+
+	var base alertSample
+	for i := len(samples) - 2; i >= 0; i-- {
+		base = samples[i]
+		if cur.at.Sub(base.at) >= r.dur {
+			break
+		}
+	}
+
+	positive, negative := max(cur.positive-base.positive, 0), max(cur.negative-base.negative, 0)
+	if total := positive + negative; total > 0 {
+		return float64(negative*100) / float64(total), true
+	}
+	return 0, true
+}
+
+// watchAlerts evaluates the alert rules every StatInterval seconds until the run ends.
+func (w *Worker) watchAlerts() {
+	if len(w.alerts) == 0 {
+		return
+	}
+
+	var window time.Duration
+	for _, r := range w.alerts {
+		window = max(window, r.dur)
+	}
+
+	ticker := time.NewTicker(time.Duration(w.StatInterval) * time.Second)
+	defer ticker.Stop()
+
+	var samples []alertSample
+	for {
+		select {
+		case <-w.quit:
+			return
+		case now := <-ticker.C:
+			samples = append(samples, w.sampleAlerts(now))
+			// Only the newest sample older than the longest window is needed
+			i := 0
+			for i+2 < len(samples) && now.Sub(samples[i+1].at) >= window {
+				i++
+			}
+			samples = samples[i:]
+			w.evaluateAlerts(samples)
+		}
+	}
+}
+
+// evaluateAlerts checks all alert rules against the samples.
+// Parameters:
+//   - samples: Samples in chronological order, the current one last
+func (w *Worker) evaluateAlerts(samples []alertSample) {
+	now := samples[len(samples)-1].at
+	for _, r := range w.alerts {
+		v, ok := r.sample(samples)
+		if ok && r.evaluate(v, now) {
+			w.notify(Alert{Namespace: w.Namespace, Rule: r.raw, Value: v, Firing: r.firing, At: now})
+		}
+	}
+}
+
+// notify reports an alert to the log, connected clients and the webhook.
+// Parameters:
+//   - a: Alert to report
+func (w *Worker) notify(a Alert) {
+	state := "resolved"
+	if a.Firing {
+		state = "triggered"
+	}
+	w.wlog(fmt.Sprintf("alert %s: %s (value %.2f)", state, a.Rule, a.Value))
+
+	select {
+	case broadcast <- newMessage(Payload{"alert", a}, "", w.PayloadEncoders):
+	default:
+	}
+
+	if w.AlertWebhook != "" {
+		go w.sendWebhook(w.baseContext(), w.AlertWebhook, a)
+	}
+}
+
+// sendWebhook posts the alert as JSON to the given URL.
+// Parameters:
+//   - ctx: Context for the request
+//   - u: Webhook URL
+//   - a: Alert to send
+func (w *Worker) sendWebhook(ctx context.Context, u string, a Alert) {
+	body, _ := json.Marshal(a)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u, bytes.NewReader(body))
+	if err != nil {
+		w.werr(fmt.Sprintf("error sending alert to %s: %v", u, err))
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		w.werr(fmt.Sprintf("error sending alert to %s: %v", u, err))
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		w.werr(fmt.Sprintf("failed to send alert to %s: status %d", u, resp.StatusCode))
+	}
+}
@@ -0,0 +1,127 @@
+package httptines
+
+import (
+	"io"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Alert", func() {
+	Describe("parseAlertRule()", func() {
+		It("parses a rule with duration", func() {
+			r, err := parseAlertRule("fail_rate > 30% for 5m")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.metric).To(Equal("fail_rate"))
+			Expect(r.op).To(Equal(">"))
+			Expect(r.value).To(Equal(30.0))
+			Expect(r.dur).To(Equal(5 * time.Minute))
+		})
+
+		It("parses a rule without duration", func() {
+			r, err := parseAlertRule("alive_proxies < 10")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.value).To(Equal(10.0))
+			Expect(r.dur).To(BeZero())
+		})
+
+		It("rejects unknown metrics", func() {
+			_, err := parseAlertRule("latency > 10")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects malformed rules", func() {
+			_, err := parseAlertRule("rpm < 100 during 5m")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("evaluate()", func() {
+		var r *alertRule
+
+		BeforeEach(func() {
+			r, _ = parseAlertRule("rpm < 100 for 1m")
+		})
+
+		It("triggers after the condition holds for the duration", func() {
+			now := time.Now()
+			Expect(r.evaluate(50, now)).To(BeFalse())
+			Expect(r.evaluate(50, now.Add(time.Minute))).To(BeTrue())
+			Expect(r.firing).To(BeTrue())
+		})
+
+		It("resolves when the condition no longer holds", func() {
+			now := time.Now()
+			r.evaluate(50, now)
+			r.evaluate(50, now.Add(time.Minute))
+
+			Expect(r.evaluate(150, now.Add(2*time.Minute))).To(BeTrue())
+			Expect(r.firing).To(BeFalse())
+		})
+
+		It("resets the timer when the condition is interrupted", func() {
+			now := time.Now()
+			r.evaluate(50, now)
+			r.evaluate(150, now.Add(30*time.Second))
+
+			Expect(r.evaluate(50, now.Add(time.Minute))).To(BeFalse())
+		})
+	})
+
+	Describe("sample()", func() {
+		now := time.Now()
+		samples := []alertSample{
+			{at: now.Add(-10 * time.Minute), positive: 0, negative: 100},
+			{at: now.Add(-5 * time.Minute), positive: 50, negative: 100},
+			{at: now.Add(-time.Minute), positive: 90, negative: 110},
+			{at: now, pooled: true, alive: 3, rpm: 40, positive: 100, negative: 110},
+		}
+
+		It("computes the fail rate over the rule's duration", func() {
+			r, _ := parseAlertRule("fail_rate > 30% for 5m")
+			v, ok := r.sample(samples)
+			Expect(ok).To(BeTrue())
+			Expect(v).To(BeNumerically("~", 16.67, 0.01))
+		})
+
+		It("computes the fail rate since the previous sample without a duration", func() {
+			r, _ := parseAlertRule("fail_rate > 30%")
+			v, _ := r.sample(samples)
+			Expect(v).To(BeZero())
+		})
+
+		It("computes the fail rate since the start with a single sample", func() {
+			r, _ := parseAlertRule("fail_rate > 30%")
+			v, _ := r.sample(samples[:1])
+			Expect(v).To(Equal(100.0))
+		})
+
+		It("reports the pool once a check has completed", func() {
+			r, _ := parseAlertRule("alive_proxies < 5")
+			v, ok := r.sample(samples)
+			Expect(ok).To(BeTrue())
+			Expect(v).To(Equal(3.0))
+
+			_, ok = r.sample(samples[:1])
+			Expect(ok).To(BeFalse())
+		})
+	})
+
+	Describe("evaluateAlerts()", func() {
+		It("resolves a fail rate alert once the proxies recover", func() {
+			w := &Worker{Logger: io.Discard, alerts: []*alertRule{}}
+			r, _ := parseAlertRule("fail_rate > 30%")
+			w.alerts = append(w.alerts, r)
+
+			now := time.Now()
+			samples := []alertSample{{at: now, negative: 10}}
+			w.evaluateAlerts(samples)
+			Expect(r.firing).To(BeTrue())
+
+			samples = append(samples, alertSample{at: now.Add(time.Second), positive: 10, negative: 10})
+			w.evaluateAlerts(samples)
+			Expect(r.firing).To(BeFalse())
+		})
+	})
+})
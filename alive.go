@@ -0,0 +1,261 @@
+package httptines
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// aliveEntry is a proxy saved in the alive cache.
+type aliveEntry struct {
+	// URL is the proxy URL including credentials
+	URL string `json:"url"`
+	// Capacity is the capacity found by the last check
+	Capacity int `json:"capacity"`
+	// Latency is the response time in milliseconds measured by the last check
+	Latency int `json:"latency"`
+	// Cached indicates that the proxy was detected serving cached content
+	Cached bool `json:"cached,omitempty"`
+	// Anonymity is the anonymity level found by the last check, empty if unknown
+	Anonymity string `json:"anonymity,omitempty"`
+	// Tests contains the results of the last check against each test URL
+	Tests map[string]bool `json:"tests,omitempty"`
+	// Checks identifies the check settings the proxy passed, see checkSettings
+	Checks string `json:"checks"`
+	// Region is the region of the worker that checked the proxy, empty if not set
+	Region string `json:"region,omitempty"`
+	// Seen is the time the proxy was last seen alive
+	Seen time.Time `json:"seen"`
+}
+
+// loadAliveCache reads the proxies saved in the alive cache.
+// Parameters:
+//   - path: Cache file path
+//   - ttl: Period after which an entry is ignored
+//   - now: Current time
+//
+// Returns:
+//   - []aliveEntry: Entries seen alive within the TTL, empty if the file doesn't exist
+//   - error: Any error that occurred while reading the file
+func loadAliveCache(path string, ttl time.Duration, now time.Time) ([]aliveEntry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []aliveEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+
+	fresh := entries[:0]
+	for _, e := range entries {
+		if now.Sub(e.Seen) < ttl {
+			fresh = append(fresh, e)
+		}
+	}
+	return fresh, nil
+}
+
+// saveAliveCache writes the proxies to the alive cache. The file may contain
+// credentials, so it is only readable by the owner.
+// Parameters:
+//   - path: Cache file path
+//   - entries: Proxies to save
+//
+// Returns:
+//   - error: Any error that occurred while writing the file
+func saveAliveCache(path string, entries []aliveEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// checkSettings identifies the settings a proxy check depends on, so proxies
+// that passed the checks with other settings aren't restored without a check.
+// Returns:
+//   - string: Hex encoded digest of the check settings
+func (w *Worker) checkSettings() string {
+	data, _ := json.Marshal(struct {
+		Target    string
+		Targets   []string
+		Quorum    int
+		Content   *IntegrityRule
+		CacheTest string
+		Judge     string
+	}{w.TestTarget, w.TestTargets, w.TestQuorum, w.TestContent, w.CacheCheckTarget, w.AnonymityJudge})
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// restoreAlive creates servers for the proxies saved in the alive cache. They
+// aren't checked again, their capacity, latency and check results are taken from
+// the cache. Proxies checked with other settings, below MinAnonymity or serving
+// cached content that CachingProxies excludes are returned to be checked instead.
+// Proxies saved by workers of several regions are restored from the entry of the
+// worker's region if there is one, and keep the region they were checked in.
+// Returns:
+//   - []*Server: Servers ready to process targets
+//   - proxyMap: Proxies to check before they are used
+func (w *Worker) restoreAlive() ([]*Server, proxyMap) {
+	if w.AliveCache == "" {
+		return nil, nil
+	}
+
+	entries, err := loadAliveCache(w.AliveCache, time.Duration(w.AliveCacheTTL)*time.Hour, time.Now())
+	if err != nil {
+		w.werr(fmt.Sprintf("error loading alive cache %s: %v", w.AliveCache, err))
+		return nil, nil
+	}
+
+	byKey := map[string]aliveEntry{}
+	urls := proxyMap{}
+	for _, e := range entries {
+		u, err := url.Parse(e.URL)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		key := proxyKey(u)
+		if c, ok := byKey[key]; ok && (w.local(c.Region) || !w.local(e.Region)) {
+			continue
+		}
+		byKey[key], urls[key] = e, u
+	}
+
+	checks := w.checkSettings()
+	proxies, recheck := proxyMap{}, proxyMap{}
+	for key, e := range byKey {
+		if e.Checks != checks || !anonymousEnough(e.Anonymity, w.MinAnonymity) || (e.Cached && w.CachingProxies != "tag") {
+			recheck[key] = urls[key]
+			continue
+		}
+		proxies[key] = urls[key]
+	}
+
+	var servers []*Server
+	for key, u := range w.skipBanned(w.skipExcluded(proxies)) {
+		e := byKey[key]
+		s := w.newServer(u)
+		s.Capacity = max(e.Capacity, 1)
+		s.CheckLatency = e.Latency
+		s.Cached = e.Cached
+		s.Anonymity = e.Anonymity
+		s.Tests = e.Tests
+		s.restored = e.Seen
+		if e.Region != "" {
+			s.Region = e.Region
+		}
+		servers = append(servers, s)
+	}
+
+	if len(servers) > 0 {
+		w.wlog(fmt.Sprintf("restored %d alive proxies from %s", len(servers), w.AliveCache))
+	}
+	if len(recheck) > 0 {
+		w.wlog(fmt.Sprintf("%d proxies from %s are checked again", len(recheck), w.AliveCache))
+	}
+	return servers, recheck
+}
+
+// saveAlive writes the enabled servers to the alive cache. Restored servers
+// that haven't succeeded yet keep the time they were last seen alive.
+// Gateway slots aren't cached, as they are added from Gateways. With Region set,
+// the entries of other regions are kept, so workers of several regions can share the file.
+func (w *Worker) saveAlive() {
+	if w.AliveCache == "" {
+		return
+	}
+
+	now := time.Now()
+	checks := w.checkSettings()
+	entries := []aliveEntry{}
+	for _, s := range w.servers.enabled() {
+		if s.slot > 0 {
+			continue
+		}
+		s.m.RLock()
+		seen := now
+		if !s.restored.IsZero() && s.Positive == 0 {
+			seen = s.restored
+		}
+		entries = append(entries, aliveEntry{
+			URL:       s.URL.String(),
+			Capacity:  s.Capacity,
+			Latency:   s.CheckLatency,
+			Cached:    s.Cached,
+			Anonymity: s.Anonymity,
+			Tests:     maps.Clone(s.Tests),
+			Checks:    checks,
+			Region:    s.Region,
+			Seen:      seen,
+		})
+		s.m.RUnlock()
+	}
+	entries = append(entries, w.otherRegions(entries)...)
+
+	slices.SortFunc(entries, func(a, b aliveEntry) int { return strings.Compare(a.URL, b.URL) })
+	if err := saveAliveCache(w.AliveCache, entries); err != nil {
+		w.werr(fmt.Sprintf("error saving alive cache %s: %v", w.AliveCache, err))
+	}
+}
+
+// otherRegions returns the entries of the alive cache saved by workers of other regions.
+// Parameters:
+//   - own: Entries saved by this worker, replacing those of the same proxy and region
+//
+// Returns:
+//   - []aliveEntry: Entries of other regions, empty if Region isn't set
+func (w *Worker) otherRegions(own []aliveEntry) []aliveEntry {
+	if w.Region == "" {
+		return nil
+	}
+
+	entries, err := loadAliveCache(w.AliveCache, time.Duration(w.AliveCacheTTL)*time.Hour, time.Now())
+	if err != nil {
+		return nil
+	}
+
+	saved := map[string]bool{}
+	for _, e := range own {
+		saved[e.Region+" "+e.URL] = true
+	}
+	return slices.DeleteFunc(entries, func(e aliveEntry) bool {
+		return e.Region == "" || e.Region == w.Region || saved[e.Region+" "+e.URL]
+	})
+}
+
+// enabled returns the registered servers that aren't disabled.
+// Returns:
+//   - []*Server: Enabled servers
+func (r *registry) enabled() []*Server {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	var servers []*Server
+	for _, s := range r.servers {
+		if atomic.LoadUint32(&s.Disabled) == 0 {
+			servers = append(servers, s)
+		}
+	}
+	return servers
+}
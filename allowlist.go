@@ -0,0 +1,56 @@
+package httptines
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// allowed reports whether the target's host is permitted by AllowedHosts.
+// A host is permitted if it equals an allowed domain or is its subdomain.
+// Parameters:
+//   - t: Target URL
+//
+// Returns:
+//   - bool: True if the target may be processed
+func (w *Worker) allowed(t string) bool {
+	if len(w.AllowedHosts) == 0 {
+		return true
+	}
+
+	u, err := url.Parse(t)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, d := range w.AllowedHosts {
+		d = strings.ToLower(strings.TrimPrefix(d, "."))
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// admit filters out targets that are not permitted by AllowedHosts, logging each rejection.
+// Parameters:
+//   - targets: Target URLs to check
+//
+// Returns:
+//   - []string: Permitted targets
+func (w *Worker) admit(targets []string) []string {
+	if len(w.AllowedHosts) == 0 {
+		return targets
+	}
+
+	admitted := make([]string, 0, len(targets))
+	for _, t := range targets {
+		if !w.allowed(t) {
+			w.wlog(fmt.Sprintf("target %s rejected: host is not allowed", t))
+			continue
+		}
+		admitted = append(admitted, t)
+	}
+	return admitted
+}
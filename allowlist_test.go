@@ -0,0 +1,40 @@
+package httptines
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Allowlist", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{}
+	})
+
+	Describe("allowed()", func() {
+		When("no hosts are configured", func() {
+			It("allows every target", func() {
+				Expect(w.allowed("http://anything.com")).To(BeTrue())
+			})
+		})
+
+		It("allows domains and their subdomains", func() {
+			w.AllowedHosts = []string{"Example.com"}
+
+			Expect(w.allowed("http://example.com/a")).To(BeTrue())
+			Expect(w.allowed("https://www.example.com:8443/a")).To(BeTrue())
+			Expect(w.allowed("http://badexample.com")).To(BeFalse())
+			Expect(w.allowed("http://example.com.evil.org")).To(BeFalse())
+		})
+	})
+
+	Describe("admit()", func() {
+		It("filters out rejected targets", func() {
+			w.AllowedHosts = []string{"example.com"}
+
+			result := w.admit([]string{"http://example.com/1", "http://other.com/1", "http://example.com/2"})
+			Expect(result).To(Equal([]string{"http://example.com/1", "http://example.com/2"}))
+		})
+	})
+})
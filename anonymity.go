@@ -0,0 +1,264 @@
+package httptines
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// anonymityLevels lists the anonymity levels from the lowest to the highest.
+var anonymityLevels = []string{"transparent", "anonymous", "elite"}
+
+// leakHeaders carry the client IP address when a proxy forwards it.
+var leakHeaders = []string{"X-Forwarded-For", "X-Real-Ip", "Forwarded", "Client-Ip", "X-Client-Ip", "X-Originating-Ip"}
+
+// proxyHeaders reveal that the request went through a proxy.
+var proxyHeaders = []string{"Via", "Proxy-Connection", "X-Proxy-Id", "X-Bluecoat-Via"}
+
+// clientAddr is the address of this host as seen by the anonymity judge.
+type clientAddr struct {
+	m     sync.Mutex
+	ip    netip.Addr
+	tried time.Time
+}
+
+// clientRetry is how long to wait before asking the judge for the client address again.
+const clientRetry = time.Minute
+
+// clientIP returns the address of this host, taken from a direct request to the
+// AnonymityJudge. The address is requested once; failed requests are retried after a minute.
+// Returns:
+//   - netip.Addr: Client address, invalid if the judge doesn't echo it
+func (w *Worker) clientIP() netip.Addr {
+	c := &w.client
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if c.ip.IsValid() || time.Since(c.tried) < clientRetry {
+		return c.ip
+	}
+	c.tried = time.Now()
+
+	ctx, cancel := context.WithTimeout(w.requestContext(), w.requestTimeout())
+	defer cancel()
+
+	body, err := fetchDirect(ctx, w.AnonymityJudge)
+	if err != nil {
+		w.werr(fmt.Sprintf("error requesting %s directly: %v, any forwarded address counts as a leak", w.AnonymityJudge, err))
+		return c.ip
+	}
+	if c.ip = echoedOrigin(body); !c.ip.IsValid() {
+		w.werr(fmt.Sprintf("%s doesn't echo the client address, any forwarded address counts as a leak", w.AnonymityJudge))
+	}
+	return c.ip
+}
+
+// fetchDirect requests the URL without a proxy.
+// Parameters:
+//   - ctx: Context of the request
+//   - u: URL to request
+//
+// Returns:
+//   - []byte: Response body
+//   - error: Any error that occurred during the request
+func fetchDirect(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// judgeAnonymity requests the judge endpoint through the proxy and classifies
+// the proxy by the headers it added to the request.
+// Parameters:
+//   - judge: URL echoing the request headers in the response body
+//   - client: Address of this host, invalid if unknown
+//
+// Returns:
+//   - string: "transparent" if the client address is forwarded, "anonymous" if
+//     only the use of a proxy is revealed, "elite" otherwise
+//   - error: Any error that occurred during the request
+func (s *Server) judgeAnonymity(judge string, client netip.Addr) (string, error) {
+	ctx, cancel := context.WithCancel(s.context())
+	defer cancel()
+
+	rep, err := request(ctx, judge, s, reqOpts{agent: s.agent})
+	if err != nil {
+		return "", err
+	}
+
+	return classifyAnonymity(rep.body, client), nil
+}
+
+// classifyAnonymity determines the anonymity level from the echoed request headers.
+// A forwarded address only makes a proxy transparent if it is the client address;
+// proxies forwarding other addresses are anonymous.
+// Parameters:
+//   - echo: Response body of the judge endpoint
+//   - client: Address of this host, invalid if unknown, in which case any forwarded address counts
+//
+// Returns:
+//   - string: Anonymity level
+func classifyAnonymity(echo []byte, client netip.Addr) string {
+	h := echoedHeaders(echo)
+	has := func(name string) bool { return len(h.Values(name)) > 0 }
+	leaks := func(name string) bool {
+		return slices.ContainsFunc(h.Values(name), func(v string) bool {
+			return !client.IsValid() || slices.Contains(forwardedAddrs(v), client)
+		})
+	}
+
+	switch {
+	case slices.ContainsFunc(leakHeaders, leaks):
+		return "transparent"
+	case slices.ContainsFunc(leakHeaders, has), slices.ContainsFunc(proxyHeaders, has):
+		return "anonymous"
+	default:
+		return "elite"
+	}
+}
+
+// echoedHeaders parses the request headers echoed by a judge, either as a JSON object,
+// optionally nested under "headers", or as "Name: value" or "HTTP_NAME = value" lines.
+// Parameters:
+//   - echo: Response body of the judge endpoint
+//
+// Returns:
+//   - http.Header: Echoed headers with canonical names
+func echoedHeaders(echo []byte) http.Header {
+	h := http.Header{}
+
+	var obj map[string]any
+	if json.Unmarshal(echo, &obj) == nil {
+		for k, v := range obj {
+			if nested, ok := v.(map[string]any); ok && strings.EqualFold(k, "headers") {
+				obj = nested
+				break
+			}
+		}
+		for k, v := range obj {
+			switch v := v.(type) {
+			case []any:
+				for _, e := range v {
+					h.Add(echoedName(k), fmt.Sprint(e))
+				}
+			case map[string]any:
+				// Nested objects aren't headers
+			default:
+				h.Add(echoedName(k), fmt.Sprint(v))
+			}
+		}
+		return h
+	}
+
+	for _, line := range strings.Split(string(echo), "\n") {
+		i := strings.IndexAny(line, ":=")
+		if i <= 0 {
+			continue
+		}
+		name := strings.TrimSpace(line[:i])
+		if name == "" || strings.ContainsAny(name, " \t<>") {
+			continue
+		}
+		h.Add(echoedName(name), strings.TrimSpace(line[i+1:]))
+	}
+	return h
+}
+
+// echoedName converts an echoed header name, e.g. "HTTP_X_FORWARDED_FOR", to its canonical form.
+// Parameters:
+//   - name: Echoed name
+//
+// Returns:
+//   - string: Canonical header name, e.g. "X-Forwarded-For"
+func echoedName(name string) string {
+	if len(name) > 5 && strings.EqualFold(name[:5], "HTTP_") {
+		name = name[5:]
+	}
+	return http.CanonicalHeaderKey(strings.ReplaceAll(name, "_", "-"))
+}
+
+// echoedOrigin returns the client address echoed by a judge, e.g. the "origin" field
+// of httpbin.org/get or the REMOTE_ADDR line of PHP judges.
+// Parameters:
+//   - echo: Response body of a direct request to the judge
+//
+// Returns:
+//   - netip.Addr: Client address, invalid if the judge doesn't echo it
+func echoedOrigin(echo []byte) netip.Addr {
+	var obj map[string]any
+	if json.Unmarshal(echo, &obj) == nil {
+		for k, v := range obj {
+			if s, ok := v.(string); ok && slices.Contains([]string{"origin", "ip", "remote_addr"}, strings.ToLower(k)) {
+				if addrs := forwardedAddrs(s); len(addrs) > 0 {
+					return addrs[0]
+				}
+			}
+		}
+		return netip.Addr{}
+	}
+
+	for _, line := range strings.Split(string(echo), "\n") {
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			name, value, ok = strings.Cut(line, ":")
+		}
+		if ok && strings.EqualFold(strings.TrimSpace(name), "REMOTE_ADDR") {
+			if addrs := forwardedAddrs(value); len(addrs) > 0 {
+				return addrs[0]
+			}
+		}
+	}
+	return netip.Addr{}
+}
+
+// forwardedAddrs extracts the IP addresses of a forwarding header value,
+// e.g. "1.2.3.4, 5.6.7.8" or `for="[2001:db8::1]:4711";proto=https`.
+// Parameters:
+//   - v: Header value
+//
+// Returns:
+//   - []netip.Addr: Addresses in the value
+func forwardedAddrs(v string) []netip.Addr {
+	var addrs []netip.Addr
+	for _, f := range strings.FieldsFunc(v, func(r rune) bool { return strings.ContainsRune(` ,;="`, r) }) {
+		if a, err := netip.ParseAddr(strings.Trim(f, "[]")); err == nil {
+			addrs = append(addrs, a.Unmap())
+		} else if ap, err := netip.ParseAddrPort(f); err == nil {
+			addrs = append(addrs, ap.Addr().Unmap())
+		}
+	}
+	return addrs
+}
+
+// anonymousEnough reports whether the level meets the minimum level.
+// Parameters:
+//   - level: Anonymity level of the proxy, empty if unknown
+//   - minimum: Required anonymity level, empty for no requirement
+//
+// Returns:
+//   - bool: True if the level is at least the minimum
+func anonymousEnough(level, minimum string) bool {
+	if minimum == "" {
+		return true
+	}
+	return slices.Index(anonymityLevels, level) >= slices.Index(anonymityLevels, minimum)
+}
@@ -0,0 +1,149 @@
+package httptines
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Anonymity", func() {
+	var (
+		w      *Worker
+		judge  *httptest.Server
+		target *httptest.Server
+	)
+
+	BeforeEach(func() {
+		judge = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			host, _, _ := net.SplitHostPort(r.RemoteAddr)
+			json.NewEncoder(w).Encode(map[string]any{"origin": host, "headers": r.Header})
+		}))
+		target = mockHTTPServer("ok")
+
+		w = &Worker{Strategy: "minimal", Timeout: 1, TestTarget: target.URL, AnonymityJudge: judge.URL}
+	})
+
+	AfterEach(func() {
+		judge.Close()
+		target.Close()
+	})
+
+	newServer := func(u *url.URL) *Server {
+		return &Server{URL: u, timeout: time.Second, agent: "checker/1.0", ctx: context.Background()}
+	}
+
+	Describe("classifyAnonymity()", func() {
+		client := netip.MustParseAddr("1.2.3.4")
+
+		It("classifies by the echoed headers", func() {
+			Expect(classifyAnonymity([]byte(`{"headers":{"X-Forwarded-For":["1.2.3.4"]}}`), client)).To(Equal("transparent"))
+			Expect(classifyAnonymity([]byte(`{"headers":{"via":["1.1 squid"]}}`), client)).To(Equal("anonymous"))
+			Expect(classifyAnonymity([]byte(`{"headers":{"User-Agent":["checker/1.0"]}}`), client)).To(Equal("elite"))
+		})
+
+		It("parses headers echoed as lines", func() {
+			Expect(classifyAnonymity([]byte("HTTP_X_FORWARDED_FOR = 10.0.0.1, 1.2.3.4\nREMOTE_ADDR = 5.6.7.8"), client)).To(Equal("transparent"))
+			Expect(classifyAnonymity([]byte("Forwarded: for=\"1.2.3.4:4711\";proto=https"), client)).To(Equal("transparent"))
+		})
+
+		It("ignores header names in values and other fields", func() {
+			Expect(classifyAnonymity([]byte(`{"headers":{"User-Agent":["via X-Forwarded-For"]}, "url": "http://x/?Via=1"}`), client)).To(Equal("elite"))
+		})
+
+		It("treats other forwarded addresses as anonymous", func() {
+			Expect(classifyAnonymity([]byte(`{"headers":{"X-Forwarded-For":"11.2.3.45"}}`), client)).To(Equal("anonymous"))
+		})
+
+		It("treats any forwarded address as a leak if the client address is unknown", func() {
+			Expect(classifyAnonymity([]byte(`{"headers":{"X-Forwarded-For":"11.2.3.45"}}`), netip.Addr{})).To(Equal("transparent"))
+		})
+	})
+
+	Describe("echoedOrigin()", func() {
+		It("reads the client address", func() {
+			Expect(echoedOrigin([]byte(`{"origin": "1.2.3.4, 5.6.7.8"}`))).To(Equal(netip.MustParseAddr("1.2.3.4")))
+			Expect(echoedOrigin([]byte("REMOTE_ADDR = 1.2.3.4\nHTTP_VIA = x"))).To(Equal(netip.MustParseAddr("1.2.3.4")))
+			Expect(echoedOrigin([]byte(`{"headers": {}}`)).IsValid()).To(BeFalse())
+		})
+	})
+
+	Describe("anonymousEnough()", func() {
+		It("compares the levels", func() {
+			Expect(anonymousEnough("elite", "anonymous")).To(BeTrue())
+			Expect(anonymousEnough("anonymous", "anonymous")).To(BeTrue())
+			Expect(anonymousEnough("transparent", "anonymous")).To(BeFalse())
+			Expect(anonymousEnough("", "transparent")).To(BeFalse())
+			Expect(anonymousEnough("", "")).To(BeTrue())
+		})
+	})
+
+	Describe("checkServer()", func() {
+		It("records the anonymity level", func() {
+			proxy, proxyURL := mockHeaderProxy(http.Header{"Via": {"1.1 proxy"}})
+			defer proxy.Close()
+
+			s := newServer(proxyURL)
+			Expect(w.checkServer(s)).To(BeTrue())
+			Expect(s.Anonymity).To(Equal("anonymous"))
+		})
+
+		It("excludes proxies below the minimum level", func() {
+			proxy, proxyURL := mockHeaderProxy(http.Header{"X-Forwarded-For": {"127.0.0.1"}})
+			defer proxy.Close()
+
+			w.MinAnonymity = "anonymous"
+			s := newServer(proxyURL)
+			Expect(w.checkServer(s)).To(BeFalse())
+			Expect(s.Anonymity).To(Equal("transparent"))
+		})
+
+		It("doesn't count a forwarded address other than the client's", func() {
+			proxy, proxyURL := mockHeaderProxy(http.Header{"X-Forwarded-For": {"10.0.0.1"}})
+			defer proxy.Close()
+
+			s := newServer(proxyURL)
+			Expect(w.checkServer(s)).To(BeTrue())
+			Expect(s.Anonymity).To(Equal("anonymous"))
+		})
+
+		It("accepts elite proxies", func() {
+			proxy, proxyURL := mockHeaderProxy(nil)
+			defer proxy.Close()
+
+			w.MinAnonymity = "elite"
+			Expect(w.checkServer(newServer(proxyURL))).To(BeTrue())
+		})
+	})
+})
+
+// mockHeaderProxy returns a proxy adding the headers to forwarded requests.
+func mockHeaderProxy(h http.Header) (*httptest.Server, *url.URL) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, _ := http.NewRequest(r.Method, r.URL.String(), nil)
+		req.Header = r.Header.Clone()
+		for k, v := range h {
+			req.Header[k] = v
+		}
+
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			http.Error(w, "Proxy Error", http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	u, _ := url.Parse(s.URL)
+	return s, u
+}
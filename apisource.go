@@ -0,0 +1,321 @@
+package httptines
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxAPIPages is the default limit of pages followed in an API source.
+const maxAPIPages = 100
+
+// APISource is a proxy source served by an API, e.g. of a commercial proxy provider,
+// returning proxies as JSON. Pages are followed through the Next field or the Link header.
+type APISource struct {
+	// URL is the API endpoint
+	URL string
+	// Schema is the proxy protocol of the entries (http/https/socks4/socks5), "http" if empty
+	Schema string
+	// Method is the HTTP method, GET if empty
+	Method string
+	// Headers are sent with every request to the scheme and host of URL, e.g.
+	// {"Authorization": "Bearer <key>"}. Pages on other hosts are requested without them.
+	Headers map[string]string
+	// Query parameters are added to every request unless the page URL already sets them
+	Query map[string]string
+	// Body is sent with every request, e.g. a JSON query for POST endpoints
+	Body string
+	// Items is the dot-separated path of the array of entries in the response,
+	// e.g. "data.proxies". Empty means the response itself is the array.
+	Items string
+	// Host is the path of the address within an entry, e.g. "ip" or "addr.host".
+	// Empty means the entry itself is a [user:password@]host:port string.
+	Host string
+	// Port is the path of the port within an entry if it isn't part of Host
+	Port string
+	// Protocol is the path of the entry's protocol overriding Schema, e.g. "type"
+	Protocol string
+	// Next is the path of the next page URL in the response, e.g. "links.next". If empty,
+	// the "next" relation of the Link header is followed.
+	Next string
+	// MaxPages limits the number of pages followed, 100 if zero
+	MaxPages int
+}
+
+// schema returns the proxy protocol of the source's entries.
+// Returns:
+//   - string: Proxy protocol
+func (s APISource) schema() string {
+	if s.Schema == "" {
+		return "http"
+	}
+	return strings.ToLower(s.Schema)
+}
+
+// fetch downloads all pages of the source and adds the proxies found.
+// Parameters:
+//   - ctx: Context cancelling the requests
+//   - timeout: Timeout of each page request
+//   - proxies: A map that stores the parsed proxy URLs
+//   - user: Credentials of entries without their own, nil if none
+//
+// Returns:
+//   - SourceStat: Counts of accepted and rejected entries
+//   - error: Any error that occurred while fetching a page
+func (s APISource) fetch(ctx context.Context, timeout time.Duration, proxies proxyMap, user *url.Userinfo) (SourceStat, error) {
+	var st SourceStat
+	client := &http.Client{Timeout: timeout}
+
+	limit := s.MaxPages
+	if limit <= 0 {
+		limit = maxAPIPages
+	}
+
+	link := s.URL
+	seen := map[string]bool{}
+	for page := 0; link != "" && page < limit && !seen[link]; page++ {
+		seen[link] = true
+
+		data, next, err := s.page(ctx, client, link)
+		if err != nil {
+			return st, err
+		}
+
+		items, ok := lookupPath(data, s.Items)
+		list, isList := items.([]any)
+		if !ok || !isList {
+			return st, fmt.Errorf("%s: no array at %q", link, s.Items)
+		}
+
+		for _, item := range list {
+			u, err := s.entry(item)
+			if err != nil {
+				st.Rejected++
+				continue
+			}
+
+			st.Accepted++
+			if u.User == nil {
+				u.User = user
+			}
+			if _, ok := proxies[proxyKey(u)]; ok {
+				st.Duplicates++
+				continue
+			}
+			proxies[proxyKey(u)] = u
+		}
+
+		link = next
+	}
+
+	return st, nil
+}
+
+// page requests a page of the source.
+// Parameters:
+//   - ctx: Context cancelling the request
+//   - client: HTTP client sending the request
+//   - link: Page URL
+//
+// Returns:
+//   - any: Decoded response
+//   - string: Absolute URL of the next page, empty if it is the last one
+//   - error: Any error that occurred while requesting or decoding the page
+func (s APISource) page(ctx context.Context, client *http.Client, link string) (any, string, error) {
+	u, err := url.Parse(link)
+	if err != nil {
+		return nil, "", err
+	}
+	q := u.Query()
+	for k, v := range s.Query {
+		if !q.Has(k) {
+			q.Set(k, v)
+		}
+	}
+	u.RawQuery = q.Encode()
+
+	method := s.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	var body io.Reader
+	if s.Body != "" {
+		body = strings.NewReader(s.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, "", err
+	}
+	if s.sameOrigin(u) {
+		for k, v := range s.Headers {
+			req.Header.Set(k, v)
+		}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("%s: status %d", link, resp.StatusCode)
+	}
+
+	d := json.NewDecoder(resp.Body)
+	d.UseNumber()
+	var data any
+	if err := d.Decode(&data); err != nil {
+		return nil, "", fmt.Errorf("%s: %w", link, err)
+	}
+
+	next := nextLink(resp.Header)
+	if s.Next != "" {
+		next = ""
+		if v, ok := lookupPath(data, s.Next); ok {
+			next, _ = v.(string)
+		}
+	}
+	if next == "" {
+		return data, "", nil
+	}
+
+	n, err := u.Parse(next)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: invalid next page %q", link, next)
+	}
+	return data, n.String(), nil
+}
+
+// sameOrigin reports whether the URL has the scheme and host of the source URL,
+// so the source's Headers, e.g. API keys, aren't sent to other hosts.
+// Parameters:
+//   - u: Page URL
+//
+// Returns:
+//   - bool: True if the URL is on the source's origin
+func (s APISource) sameOrigin(u *url.URL) bool {
+	o, err := url.Parse(s.URL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(o.Scheme, u.Scheme) && strings.EqualFold(o.Host, u.Host)
+}
+
+// entry builds the proxy URL of an entry.
+// Parameters:
+//   - item: Entry decoded from the response
+//
+// Returns:
+//   - *url.URL: Proxy URL
+//   - error: Error describing why the entry was rejected
+func (s APISource) entry(item any) (*url.URL, error) {
+	host, ok := lookupPath(item, s.Host)
+	if !ok {
+		return nil, errors.New("missing host")
+	}
+	line := fmt.Sprint(host)
+
+	if s.Port != "" {
+		port, ok := lookupPath(item, s.Port)
+		if !ok {
+			return nil, errors.New("missing port")
+		}
+		line += ":" + fmt.Sprint(port)
+	}
+
+	schema := s.schema()
+	if s.Protocol != "" {
+		if p, ok := lookupPath(item, s.Protocol); ok {
+			schema = strings.ToLower(fmt.Sprint(p))
+		}
+	}
+
+	return parseProxy(schema, line)
+}
+
+// lookupPath returns the value at the dot-separated path, where numbers index arrays.
+// Parameters:
+//   - v: Decoded JSON value
+//   - path: Path, e.g. "data.items.0", empty for v itself
+//
+// Returns:
+//   - any: Value at the path
+//   - bool: False if the path doesn't exist
+func lookupPath(v any, path string) (any, bool) {
+	if path == "" {
+		return v, v != nil
+	}
+
+	for _, key := range strings.Split(path, ".") {
+		switch c := v.(type) {
+		case map[string]any:
+			var ok bool
+			if v, ok = c[key]; !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			v = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return v, v != nil
+}
+
+// nextLink returns the target of the "next" relation of the Link header.
+// Parameters:
+//   - h: Response header
+//
+// Returns:
+//   - string: Next page URL, empty if there is none
+func nextLink(h http.Header) string {
+	for _, header := range h.Values("Link") {
+		for _, link := range strings.Split(header, ",") {
+			target, params, _ := strings.Cut(link, ";")
+			for _, p := range strings.Split(params, ";") {
+				p = strings.ReplaceAll(strings.TrimSpace(p), `"`, "")
+				if strings.EqualFold(p, "rel=next") {
+					return strings.Trim(strings.TrimSpace(target), "<>")
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// fetchAPIProxies retrieves proxies from the API sources.
+// Parameters:
+//   - ctx: Context cancelling the requests
+//   - timeout: Timeout of each page request
+//   - sources: API sources
+//   - creds: Credentials keyed by source URL
+//   - proxies: A map that stores the parsed proxy URLs
+//   - stats: Parse statistics keyed by source URL
+func (w *Worker) fetchAPIProxies(ctx context.Context, timeout time.Duration, sources []APISource, creds map[string]Credentials, proxies proxyMap, stats map[string]SourceStat) {
+	for _, s := range sources {
+		st, err := s.fetch(ctx, timeout, proxies, creds[s.URL].userinfo())
+		if err != nil {
+			w.werr(fmt.Sprintf("error fetching proxies from %s: %v", s.URL, err))
+		}
+		if st.Rejected > 0 {
+			w.wlog(fmt.Sprintf("%s: %d proxies accepted, %d entries rejected", s.URL, st.Accepted, st.Rejected))
+		}
+		if st.Duplicates > 0 {
+			w.wlog(fmt.Sprintf("%s: %d duplicate proxies skipped", s.URL, st.Duplicates))
+		}
+		stats[s.URL] = st
+	}
+}
@@ -0,0 +1,61 @@
+package httptines
+
+import (
+	"context"
+	"crypto/rand"
+	"net/http"
+)
+
+// attemptKey is the context key of the attempt ID.
+type attemptKey struct{}
+
+// newAttemptID returns a random ID identifying a single request attempt.
+// Returns:
+//   - string: Attempt ID
+func newAttemptID() string {
+	return rand.Text()[:16]
+}
+
+// withAttempt returns a copy of the context carrying the attempt ID.
+// Parameters:
+//   - ctx: Request context
+//   - id: Attempt ID
+//
+// Returns:
+//   - context.Context: Context carrying the attempt ID
+func withAttempt(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, attemptKey{}, id)
+}
+
+// AttemptID returns the ID of the request attempt the context belongs to, e.g. the
+// context of a request passed to PrepareRequest or of a Result. The same ID appears
+// in Statuses, Failed and the results stream of the attempt, and in the failure log
+// line or, for repetitions of the same error via the same proxy, in their summary.
+// Parameters:
+//   - ctx: Request context
+//
+// Returns:
+//   - string: Attempt ID, empty if the context doesn't belong to an attempt
+func AttemptID(ctx context.Context) string {
+	id, _ := ctx.Value(attemptKey{}).(string)
+	return id
+}
+
+// attemptHeader returns the request headers with the attempt ID if AttemptHeader is set.
+// Parameters:
+//   - h: Headers of the target
+//   - id: Attempt ID
+//
+// Returns:
+//   - http.Header: Headers to send, h itself if AttemptHeader is empty
+func (w *Worker) attemptHeader(h http.Header, id string) http.Header {
+	if w.AttemptHeader == "" {
+		return h
+	}
+	h = h.Clone()
+	if h == nil {
+		h = http.Header{}
+	}
+	h.Set(w.AttemptHeader, id)
+	return h
+}
@@ -0,0 +1,45 @@
+package httptines
+
+import (
+	"context"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Attempt", func() {
+	Describe("newAttemptID()", func() {
+		It("returns unique IDs", func() {
+			a, b := newAttemptID(), newAttemptID()
+			Expect(a).To(HaveLen(16))
+			Expect(a).NotTo(Equal(b))
+		})
+	})
+
+	Describe("AttemptID()", func() {
+		It("reads the ID from the context", func() {
+			Expect(AttemptID(withAttempt(context.Background(), "A1"))).To(Equal("A1"))
+			Expect(AttemptID(context.Background())).To(BeEmpty())
+		})
+	})
+
+	Describe("attemptHeader()", func() {
+		It("adds the ID without changing the target headers", func() {
+			w := &Worker{AttemptHeader: "X-Request-ID"}
+			h := http.Header{"Accept": {"text/html"}}
+
+			sent := w.attemptHeader(h, "A1")
+			Expect(sent.Get("X-Request-ID")).To(Equal("A1"))
+			Expect(sent.Get("Accept")).To(Equal("text/html"))
+			Expect(h).NotTo(HaveKey("X-Request-Id"))
+
+			Expect(w.attemptHeader(nil, "A1").Get("X-Request-ID")).To(Equal("A1"))
+		})
+
+		It("leaves the headers alone without AttemptHeader", func() {
+			h := http.Header{"Accept": {"text/html"}}
+			Expect((&Worker{}).attemptHeader(h, "A1")).To(Equal(h))
+		})
+	})
+})
@@ -0,0 +1,76 @@
+package httptines
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Auth", func() {
+	Describe("Credentials.userinfo()", func() {
+		It("returns nil without a username", func() {
+			Expect(Credentials{}.userinfo()).To(BeNil())
+		})
+
+		It("returns the username and password", func() {
+			Expect(Credentials{Username: "user", Password: "pass"}.userinfo().String()).To(Equal("user:pass"))
+		})
+	})
+
+	Describe("redactEntry()", func() {
+		It("hides the credentials", func() {
+			Expect(redactEntry("http://user:p@ss@1.2.3.4:x")).To(Equal("http://xxxxx@1.2.3.4:x"))
+			Expect(redactEntry("user:pass@1.2.3.4")).To(Equal("xxxxx@1.2.3.4"))
+			Expect(redactEntry("1.2.3.4:8080")).To(Equal("1.2.3.4:8080"))
+		})
+	})
+
+	Describe("Server.name()", func() {
+		It("hides the credentials", func() {
+			s := &Server{URL: &url.URL{Scheme: "http", User: url.UserPassword("user", "pass"), Host: "1.2.3.4:8080"}}
+			Expect(s.name()).To(Equal("http://1.2.3.4:8080"))
+		})
+	})
+
+	Describe("request()", func() {
+		var proxy *httptest.Server
+
+		BeforeEach(func() {
+			proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				user, pass, ok := (&http.Request{Header: http.Header{
+					"Authorization": r.Header.Values("Proxy-Authorization"),
+				}}).BasicAuth()
+				if !ok || user != "user" || pass != "pass" {
+					w.WriteHeader(http.StatusProxyAuthRequired)
+					return
+				}
+				w.Write([]byte("good"))
+			}))
+		})
+
+		AfterEach(func() {
+			proxy.Close()
+		})
+
+		It("authenticates to HTTP proxies", func() {
+			u, _ := url.Parse(proxy.URL)
+			u.User = url.UserPassword("user", "pass")
+
+			rep, err := request(context.Background(), "http://example.com", &Server{URL: u}, reqOpts{agent: "test"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(rep.body)).To(Equal("good"))
+		})
+
+		It("fails without credentials", func() {
+			u, _ := url.Parse(proxy.URL)
+
+			rep, err := request(context.Background(), "http://example.com", &Server{URL: u}, reqOpts{agent: "test"})
+			Expect(err).To(HaveOccurred())
+			Expect(rep.status).To(Equal(http.StatusProxyAuthRequired))
+		})
+	})
+})
@@ -0,0 +1,91 @@
+package httptines
+
+import (
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// backoff returns the delay before the next attempt of a target that failed n times.
+// The delay starts at BackoffBase, doubles with every failure up to BackoffMax
+// and is randomized by BackoffJitter percent.
+// Parameters:
+//   - n: Number of failed attempts
+//
+// Returns:
+//   - time.Duration: Delay before the next attempt, zero if backoff is disabled
+func (w *Worker) backoff(n int) time.Duration {
+	if w.BackoffBase <= 0 || n <= 0 {
+		return 0
+	}
+
+	base := time.Duration(w.BackoffBase) * time.Millisecond
+	limit := time.Duration(w.BackoffMax) * time.Millisecond
+
+	d := base
+	for i := 1; i < n && d < limit; i++ {
+		d *= 2
+	}
+	if limit > 0 && d > limit {
+		d = limit
+	}
+
+	if w.BackoffJitter > 0 {
+		spread := float64(d) * float64(min(w.BackoffJitter, 100)) / 100
+		d += time.Duration((rand.Float64()*2 - 1) * spread)
+	}
+
+	return d
+}
+
+// exhausted reports whether a target has used up its retries and moves it
+// to the dead-letter list if so.
+// Parameters:
+//   - u: Target URL
+//   - n: Number of failed attempts
+//
+// Returns:
+//   - bool: True if the target must not be retried
+func (w *Worker) exhausted(u string, n int) bool {
+	if w.MaxRetries <= 0 || n <= w.MaxRetries {
+		return false
+	}
+
+	w.abandon(u, n)
+	w.wlog(fmt.Sprintf("%s abandoned after %d attempts", u, n))
+
+	return true
+}
+
+// abandon gives up a target and moves it to the dead-letter list.
+// Parameters:
+//   - u: Target URL
+//   - n: Number of failed attempts
+func (w *Worker) abandon(u string, n int) {
+	w.settle(u)
+	w.finishClaim(u)
+	w.bury(u, n)
+	w.order.skip(u)
+	w.stat.abandon()
+}
+
+// retriable reports whether a failed attempt may be retried. Requests with
+// non-idempotent methods aren't sent again once they were written, unless RetryUnsafe is set.
+// Parameters:
+//   - method: HTTP method of the target
+//   - written: Whether the failed request was written to the connection
+//
+// Returns:
+//   - bool: True if the target may be retried
+func (w *Worker) retriable(method string, written bool) bool {
+	if w.RetryUnsafe || !written {
+		return true
+	}
+	switch strings.ToUpper(method) {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package httptines
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Backoff", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{BackoffMax: 1000, stat: &Stat{Targets: 1}}
+	})
+
+	Describe("backoff()", func() {
+		It("is disabled by default", func() {
+			Expect(w.backoff(3)).To(BeZero())
+		})
+
+		It("doubles the delay up to the limit", func() {
+			w.BackoffBase = 100
+
+			Expect(w.backoff(1)).To(Equal(100 * time.Millisecond))
+			Expect(w.backoff(2)).To(Equal(200 * time.Millisecond))
+			Expect(w.backoff(3)).To(Equal(400 * time.Millisecond))
+			Expect(w.backoff(10)).To(Equal(time.Second))
+		})
+
+		It("randomizes the delay by the jitter", func() {
+			w.BackoffBase = 100
+			w.BackoffJitter = 50
+
+			for range 100 {
+				Expect(w.backoff(1)).To(BeNumerically("~", 100*time.Millisecond, 50*time.Millisecond))
+			}
+		})
+	})
+
+	Describe("retriable()", func() {
+		It("retries idempotent methods and requests that weren't sent", func() {
+			Expect(w.retriable("", true)).To(BeTrue())
+			Expect(w.retriable("put", true)).To(BeTrue())
+			Expect(w.retriable("POST", false)).To(BeTrue())
+			Expect(w.retriable("POST", true)).To(BeFalse())
+			Expect(w.retriable("PATCH", true)).To(BeFalse())
+		})
+	})
+
+	Describe("retry()", func() {
+		It("abandons the target after the retry limit", func() {
+			w.MaxRetries = 2
+			for range 3 {
+				w.attempt("http://test1.com")
+				w.retry("http://test1.com")
+			}
+
+			Expect(w.shift(10)).To(Equal([]string{"http://test1.com", "http://test1.com"}))
+			Expect(w.stat.Abandoned).To(Equal(1))
+			Expect(w.stat.allTargetsProcessed()).To(BeTrue())
+			Expect(w.attempts).NotTo(HaveKey("http://test1.com"))
+		})
+
+		It("doesn't hold later results of ordered targets", func() {
+			var delivered []string
+			w.MaxRetries = 1
+			w.stat.Targets = 3
+			w.order = newOrderer([]string{"http://test1.com", "http://test2.com", "http://test3.com"}, 0, func(r Result) {
+				delivered = append(delivered, r.URL)
+			})
+
+			w.order.deliver(Result{URL: "http://test3.com"})
+			w.order.deliver(Result{URL: "http://test1.com"})
+			Expect(delivered).To(Equal([]string{"http://test1.com"}))
+
+			for range 2 {
+				w.attempt("http://test2.com")
+				w.retry("http://test2.com")
+			}
+			Expect(delivered).To(Equal([]string{"http://test1.com", "http://test3.com"}))
+		})
+
+		It("delays the retry by the backoff", func() {
+			w.BackoffBase = 200
+			w.attempt("http://test1.com")
+			w.retry("http://test1.com")
+
+			Expect(w.shift(1)).To(BeEmpty())
+			Eventually(func() []string { return w.shift(1) }).Should(Equal([]string{"http://test1.com"}))
+		})
+	})
+})
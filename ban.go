@@ -0,0 +1,162 @@
+package httptines
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"time"
+)
+
+// Ban temporarily removes a proxy from rotation. The ban survives pool refreshes.
+// Parameters:
+//   - proxy: Proxy URL, e.g. "http://1.2.3.4:8080"
+//   - ttl: Ban duration, BanTTL seconds if zero
+func (w *Worker) Ban(proxy string, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = time.Duration(w.BanTTL) * time.Second
+	}
+	w.ban(proxy, ttl, false)
+}
+
+// ban bans a proxy and records whether the ban was set by the blacklist.
+// Parameters:
+//   - proxy: Proxy URL
+//   - ttl: Ban duration
+//   - auto: True if the ban was set by the blacklist, so re-validation may lift it
+func (w *Worker) ban(proxy string, ttl time.Duration, auto bool) {
+	w.m.Lock()
+	if w.bans == nil {
+		w.bans = map[string]time.Time{}
+	}
+	if w.autoBans == nil {
+		w.autoBans = map[string]bool{}
+	}
+	w.bans[proxy] = time.Now().Add(ttl)
+	if auto {
+		w.autoBans[proxy] = true
+	} else {
+		delete(w.autoBans, proxy)
+	}
+	bans := maps.Clone(w.bans)
+	w.m.Unlock()
+
+	w.publishBans(bans)
+	w.wlog(fmt.Sprintf("proxy %s banned for %s", proxy, ttl))
+}
+
+// Unban restores a banned proxy to rotation.
+// Parameters:
+//   - proxy: Proxy URL
+func (w *Worker) Unban(proxy string) {
+	w.m.Lock()
+	delete(w.bans, proxy)
+	delete(w.autoBans, proxy)
+	bans := maps.Clone(w.bans)
+	w.m.Unlock()
+
+	w.publishBans(bans)
+	w.wlog(fmt.Sprintf("proxy %s restored", proxy))
+}
+
+// Bans returns the banned proxies with their expiration times.
+// Returns:
+//   - map[string]time.Time: Expiration times keyed by proxy URL
+func (w *Worker) Bans() map[string]time.Time {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.expireBans(time.Now())
+	return maps.Clone(w.bans)
+}
+
+// banned reports whether the proxy is currently banned. Gateway slots are
+// also banned along with their gateway.
+// Parameters:
+//   - proxy: Proxy URL
+//
+// Returns:
+//   - bool: True if the proxy is banned
+func (w *Worker) banned(proxy string) bool {
+	gateway, _, _ := strings.Cut(proxy, "#")
+
+	w.m.RLock()
+	until, ok := w.bans[proxy]
+	if !ok {
+		until, ok = w.bans[gateway]
+	}
+	w.m.RUnlock()
+
+	return ok && time.Now().Before(until)
+}
+
+// skipBans drops the banned proxies, so they aren't checked until the ban expires.
+// Parameters:
+//   - proxies: Proxies to check
+//
+// Returns:
+//   - proxyMap: Proxies that aren't banned
+func (w *Worker) skipBans(proxies proxyMap) proxyMap {
+	result := make(proxyMap, len(proxies))
+	for addr, u := range proxies {
+		if !w.banned(proxyName(u)) {
+			result[addr] = u
+		}
+	}
+
+	if n := len(proxies) - len(result); n > 0 {
+		w.wlog(fmt.Sprintf("%d banned proxies skipped", n))
+	}
+	return result
+}
+
+// blacklist bans a proxy disabled after failing repeatedly for BlacklistTTL
+// seconds, so the next check cycles don't add it again. Tor is never banned,
+// as a new circuit is used when it is added again, and neither are gateway slots,
+// as the gateway assigns another IP to the next request.
+// Parameters:
+//   - s: Disabled server
+func (w *Worker) blacklist(s *Server) {
+	if w.BlacklistTTL <= 0 || s.tor != nil || s.slot > 0 {
+		return
+	}
+	w.ban(s.name(), time.Duration(w.BlacklistTTL)*time.Second, true)
+}
+
+// liftBlacklist lifts the ban set by the blacklist once the proxy passed re-validation.
+// Bans set with Ban or the HTTP API are kept.
+// Parameters:
+//   - proxy: Proxy URL
+func (w *Worker) liftBlacklist(proxy string) {
+	w.m.RLock()
+	auto := w.autoBans[proxy]
+	w.m.RUnlock()
+
+	if auto {
+		w.Unban(proxy)
+	}
+}
+
+// expireBans removes expired bans. The caller must hold the lock.
+// Parameters:
+//   - now: Current time
+func (w *Worker) expireBans(now time.Time) {
+	for p, until := range w.bans {
+		if !now.Before(until) {
+			delete(w.bans, p)
+			delete(w.autoBans, p)
+		}
+	}
+}
+
+// publishBans updates the bans in the statistics.
+// Parameters:
+//   - bans: Expiration times keyed by proxy URL
+func (w *Worker) publishBans(bans map[string]time.Time) {
+	if w.stat == nil {
+		return
+	}
+
+	w.stat.m.Lock()
+	w.stat.Bans = bans
+	w.stat.m.Unlock()
+}
@@ -0,0 +1,115 @@
+package httptines
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Ban", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{BanTTL: 600, stat: &Stat{}}
+	})
+
+	Describe("Ban()", func() {
+		It("bans the proxy for the default TTL", func() {
+			w.Ban("http://1.2.3.4:8080", 0)
+
+			Expect(w.banned("http://1.2.3.4:8080")).To(BeTrue())
+			Expect(w.Bans()["http://1.2.3.4:8080"]).To(BeTemporally("~", time.Now().Add(10*time.Minute), time.Second))
+			Expect(w.stat.Bans).To(HaveKey("http://1.2.3.4:8080"))
+		})
+
+		It("expires the ban after the TTL", func() {
+			w.Ban("http://1.2.3.4:8080", 50*time.Millisecond)
+
+			Eventually(func() bool { return w.banned("http://1.2.3.4:8080") }).Should(BeFalse())
+			Expect(w.Bans()).To(BeEmpty())
+		})
+	})
+
+	Describe("Unban()", func() {
+		It("restores the proxy", func() {
+			w.Ban("http://1.2.3.4:8080", 0)
+			w.Unban("http://1.2.3.4:8080")
+
+			Expect(w.banned("http://1.2.3.4:8080")).To(BeFalse())
+			Expect(w.stat.Bans).To(BeEmpty())
+		})
+	})
+
+	Describe("skipBans()", func() {
+		It("drops the banned proxies", func() {
+			a := &url.URL{Scheme: "http", User: url.UserPassword("u", "p"), Host: "1.2.3.4:8080"}
+			b := &url.URL{Scheme: "http", Host: "5.6.7.8:8080"}
+			w.Ban("http://1.2.3.4:8080", 0)
+
+			Expect(w.skipBans(proxyMap{a.Host: a, b.Host: b})).To(Equal(proxyMap{b.Host: b}))
+		})
+	})
+
+	Describe("blacklist()", func() {
+		var s *Server
+
+		BeforeEach(func() {
+			w.BlacklistTTL = 1800
+			s = &Server{URL: &url.URL{Scheme: "http", Host: "1.2.3.4:8080"}}
+		})
+
+		It("bans the disabled proxy for the TTL", func() {
+			w.blacklist(s)
+
+			Expect(w.banned("http://1.2.3.4:8080")).To(BeTrue())
+			Expect(w.Bans()["http://1.2.3.4:8080"]).To(BeTemporally("~", time.Now().Add(30*time.Minute), time.Second))
+		})
+
+		It("is disabled with a negative TTL", func() {
+			w.BlacklistTTL = -1
+			w.blacklist(s)
+
+			Expect(w.Bans()).To(BeEmpty())
+		})
+
+		It("doesn't ban Tor", func() {
+			s.tor = &torCircuit{}
+			w.blacklist(s)
+
+			Expect(w.Bans()).To(BeEmpty())
+		})
+	})
+
+	Describe("banHandler()", func() {
+		It("bans the proxy", func() {
+			rec := httptest.NewRecorder()
+			banHandler(w)(rec, httptest.NewRequest(http.MethodPost, "/api/bans?url=http://1.2.3.4:8080&ttl=60", nil))
+
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(w.banned("http://1.2.3.4:8080")).To(BeTrue())
+		})
+
+		It("requires the url", func() {
+			rec := httptest.NewRecorder()
+			banHandler(w)(rec, httptest.NewRequest(http.MethodPost, "/api/bans", nil))
+
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Describe("unbanHandler()", func() {
+		It("restores the proxy", func() {
+			w.Ban("http://1.2.3.4:8080", 0)
+
+			rec := httptest.NewRecorder()
+			unbanHandler(w)(rec, httptest.NewRequest(http.MethodDelete, "/api/bans?url=http://1.2.3.4:8080", nil))
+
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(w.banned("http://1.2.3.4:8080")).To(BeFalse())
+		})
+	})
+})
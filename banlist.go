@@ -0,0 +1,178 @@
+package httptines
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"time"
+)
+
+// strike records the failed checks of a proxy across runs.
+type strike struct {
+	// Failures is the number of failed checks in a row
+	Failures int `json:"failures"`
+	// Last is the time of the last failed check
+	Last time.Time `json:"last"`
+}
+
+// banList is an on-disk list of proxies failing their checks repeatedly.
+// Every decay period forgives one failure, so proxies get another chance eventually.
+type banList struct {
+	path    string
+	after   int
+	decay   time.Duration
+	strikes map[string]strike
+}
+
+// loadBanList reads the ban list from a file. A missing file yields an empty list.
+// Parameters:
+//   - path: File path
+//   - after: Number of failures after which a proxy is skipped
+//   - decay: Period forgiving one failure
+//
+// Returns:
+//   - *banList: Ban list
+//   - error: Any error that occurred while reading
+func loadBanList(path string, after int, decay time.Duration) (*banList, error) {
+	l := &banList{path: path, after: after, decay: decay, strikes: map[string]strike{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return l, nil
+	}
+	if err != nil {
+		return l, err
+	}
+	return l, json.Unmarshal(data, &l.strikes)
+}
+
+// failures returns the number of failures of a proxy left after decay.
+// Parameters:
+//   - addr: Proxy host:port
+//   - now: Current time
+//
+// Returns:
+//   - int: Number of failures
+func (l *banList) failures(addr string, now time.Time) int {
+	s, ok := l.strikes[addr]
+	if !ok {
+		return 0
+	}
+	if l.decay > 0 {
+		s.Failures -= int(now.Sub(s.Last) / l.decay)
+	}
+	return max(s.Failures, 0)
+}
+
+// filter returns the proxies that aren't banned.
+// Parameters:
+//   - proxies: Proxies to check
+//   - now: Current time
+//
+// Returns:
+//   - proxyMap: Proxies with fewer failures than the threshold
+func (l *banList) filter(proxies proxyMap, now time.Time) proxyMap {
+	result := proxyMap{}
+	for key, u := range proxies {
+		if l.failures(u.Host, now) < l.after {
+			result[key] = u
+		}
+	}
+	return result
+}
+
+// record updates the failures of the checked proxies. Alive proxies are removed
+// from the list, the others get a strike.
+// Parameters:
+//   - checked: Checked proxies
+//   - alive: Proxies that passed the check
+//   - now: Current time
+func (l *banList) record(checked proxyMap, alive []*Server, now time.Time) {
+	passed := map[string]bool{}
+	for _, s := range alive {
+		passed[s.URL.Host] = true
+	}
+
+	for _, u := range checked {
+		addr := u.Host
+		if passed[addr] {
+			delete(l.strikes, addr)
+			continue
+		}
+		l.strikes[addr] = strike{Failures: l.failures(addr, now) + 1, Last: now}
+	}
+
+	for addr := range l.strikes {
+		if l.failures(addr, now) == 0 {
+			delete(l.strikes, addr)
+		}
+	}
+}
+
+// banned returns the number of proxies skipped by the list.
+// Parameters:
+//   - now: Current time
+//
+// Returns:
+//   - int: Number of banned proxies
+func (l *banList) banned(now time.Time) int {
+	var n int
+	for addr := range l.strikes {
+		if l.failures(addr, now) >= l.after {
+			n++
+		}
+	}
+	return n
+}
+
+// save writes the ban list to its file.
+// Returns:
+//   - error: Any error that occurred while writing
+func (l *banList) save() error {
+	data, err := json.Marshal(l.strikes)
+	if err != nil {
+		return err
+	}
+
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// skipBanned removes the proxies on the ban list from a check cycle.
+// Parameters:
+//   - proxies: Proxies to check
+//
+// Returns:
+//   - proxyMap: Proxies to check
+func (w *Worker) skipBanned(proxies proxyMap) proxyMap {
+	if w.banList == nil {
+		return proxies
+	}
+
+	now := time.Now()
+	result := w.banList.filter(proxies, now)
+	if n := len(proxies) - len(result); n > 0 {
+		w.wlog(fmt.Sprintf("%d proxies skipped by the ban list", n))
+	}
+	return result
+}
+
+// recordBanned updates and saves the ban list after a check cycle.
+// Parameters:
+//   - checked: Checked proxies
+//   - alive: Proxies that passed the check
+func (w *Worker) recordBanned(checked proxyMap, alive []*Server) {
+	if w.banList == nil {
+		return
+	}
+
+	w.banList.record(checked, alive, time.Now())
+	if err := w.banList.save(); err != nil {
+		w.werr(fmt.Sprintf("error saving ban list %s: %v", w.BanList, err))
+	}
+}
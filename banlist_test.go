@@ -0,0 +1,75 @@
+package httptines
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("banList", func() {
+	var (
+		l    *banList
+		path string
+		now  time.Time
+		a, b *url.URL
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "bans.json")
+		now = time.Now()
+		a = &url.URL{Scheme: "http", Host: "1.1.1.1:80"}
+		b = &url.URL{Scheme: "http", Host: "2.2.2.2:80"}
+
+		var err error
+		l, err = loadBanList(path, 2, 24*time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("skips proxies failing repeatedly", func() {
+		checked := proxyMap{a.Host: a, b.Host: b}
+		l.record(checked, []*Server{{URL: b}}, now)
+		Expect(l.filter(checked, now)).To(HaveLen(2))
+
+		l.record(checked, []*Server{{URL: b}}, now)
+		Expect(l.filter(checked, now)).To(Equal(proxyMap{b.Host: b}))
+		Expect(l.banned(now)).To(Equal(1))
+	})
+
+	It("resets the failures of alive proxies", func() {
+		checked := proxyMap{a.Host: a}
+		l.record(checked, nil, now)
+		l.record(checked, []*Server{{URL: a}}, now)
+
+		Expect(l.strikes).To(BeEmpty())
+	})
+
+	It("forgives a failure every decay period", func() {
+		checked := proxyMap{a.Host: a}
+		l.record(checked, nil, now)
+		l.record(checked, nil, now)
+
+		Expect(l.failures(a.Host, now.Add(25*time.Hour))).To(Equal(1))
+		Expect(l.filter(checked, now.Add(25*time.Hour))).To(HaveLen(1))
+		Expect(l.failures(a.Host, now.Add(72*time.Hour))).To(Equal(0))
+	})
+
+	It("persists across runs", func() {
+		l.record(proxyMap{a.Host: a}, nil, now)
+		Expect(l.save()).To(Succeed())
+
+		loaded, err := loadBanList(path, 2, 24*time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.failures(a.Host, now)).To(Equal(1))
+	})
+
+	It("fails on a malformed file", func() {
+		Expect(os.WriteFile(path, []byte("{"), 0o644)).To(Succeed())
+
+		_, err := loadBanList(path, 2, 24*time.Hour)
+		Expect(err).To(HaveOccurred())
+	})
+})
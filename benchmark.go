@@ -0,0 +1,172 @@
+package httptines
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// BenchmarkConfig describes a synthetic workload and a simulated proxy fleet.
+type BenchmarkConfig struct {
+	// Proxies is the number of simulated proxies
+	// Default: 10.
+	Proxies int
+	// ProxyCapacity is the number of concurrent requests a simulated proxy accepts
+	// Default: 4.
+	ProxyCapacity int
+	// Targets is the number of synthetic targets
+	// Default: 200.
+	Targets int
+	// Latency is the simulated response time of a proxy in milliseconds
+	// Default: 50.
+	Latency int
+	// FailureRate is the probability (0..1) that a simulated proxy fails a request
+	FailureRate float64
+	// Strategies lists the balancing strategies to compare
+	// Default: "minimal", "auto".
+	Strategies []string
+}
+
+// BenchmarkResult represents the performance of a strategy on the synthetic workload.
+type BenchmarkResult struct {
+	// Strategy is the balancing strategy
+	Strategy string `json:"strategy"`
+	// Elapsed is the time spent processing all targets
+	Elapsed time.Duration `json:"elapsed"`
+	// Throughput is the number of processed targets per second
+	Throughput float64 `json:"throughput"`
+	// Latency is the average last measured latency of the proxies
+	Latency time.Duration `json:"latency"`
+}
+
+// String formats the result as a single line.
+// Returns:
+//   - string: Formatted result
+func (r BenchmarkResult) String() string {
+	return fmt.Sprintf("%-8s elapsed %s, %.1f req/s, latency %s", r.Strategy, r.Elapsed.Round(time.Millisecond), r.Throughput, r.Latency)
+}
+
+// Default sets the fields left at their zero value to their defaults.
+func (c *BenchmarkConfig) Default() {
+	setDefault(&c.Proxies, 10)
+	setDefault(&c.ProxyCapacity, 4)
+	setDefault(&c.Targets, 200)
+	setDefault(&c.Latency, 50)
+	if len(c.Strategies) == 0 {
+		c.Strategies = []string{"minimal", "auto"}
+	}
+}
+
+// Benchmark replays a synthetic workload against a simulated proxy fleet for each strategy,
+// so a strategy can be chosen empirically.
+// Parameters:
+//   - ctx: Context for cancelling the benchmark
+//   - cfg: Workload and fleet description
+//
+// Returns:
+//   - []BenchmarkResult: Result of each strategy
+//   - error: Any error that occurred
+func Benchmark(ctx context.Context, cfg BenchmarkConfig) ([]BenchmarkResult, error) {
+	cfg.Default()
+
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+
+	var hosts []string
+	for range cfg.Proxies {
+		p := simulatedProxy(cfg)
+		defer p.Close()
+		hosts = append(hosts, strings.TrimPrefix(p.URL, "http://"))
+	}
+
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte(strings.Join(hosts, "\n")))
+	}))
+	defer source.Close()
+
+	targets := make([]string, cfg.Targets)
+	for i := range targets {
+		targets[i] = target.URL + "/" + strconv.Itoa(i)
+	}
+
+	var results []BenchmarkResult
+	for _, strategy := range cfg.Strategies {
+		port, err := benchmarkPort()
+		if err != nil {
+			return results, err
+		}
+
+		w := &Worker{
+			Port:         port,
+			Strategy:     strategy,
+			StatInterval: 1,
+			Timeout:      5,
+			TestTarget:   target.URL,
+			Sources:      proxySrc{"http": {source.URL}},
+		}
+
+		startedAt := time.Now()
+		if err := w.RunContext(ctx, targets, func([]byte) {}); err != nil {
+			return results, err
+		}
+
+		r := BenchmarkResult{Strategy: strategy, Elapsed: w.stat.processingTime(), Latency: w.stat.avgLatency()}
+		if r.Elapsed <= 0 {
+			r.Elapsed = time.Since(startedAt)
+		}
+		r.Throughput = float64(cfg.Targets) / r.Elapsed.Seconds()
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+// simulatedProxy starts a proxy answering requests itself with the configured latency,
+// failure rate and capacity.
+// Parameters:
+//   - cfg: Fleet description
+//
+// Returns:
+//   - *httptest.Server: Running proxy
+func simulatedProxy(cfg BenchmarkConfig) *httptest.Server {
+	var active int32
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if atomic.AddInt32(&active, 1) > int32(cfg.ProxyCapacity) {
+			atomic.AddInt32(&active, -1)
+			http.Error(w, "too many connections", http.StatusServiceUnavailable)
+			return
+		}
+		defer atomic.AddInt32(&active, -1)
+
+		time.Sleep(time.Duration(cfg.Latency) * time.Millisecond)
+
+		if rand.Float64() < cfg.FailureRate {
+			http.Error(w, "proxy error", http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+}
+
+// benchmarkPort returns a free port for the web interface of a benchmark run.
+// Returns:
+//   - int: Free port
+//   - error: Any error that occurred
+func benchmarkPort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,29 @@
+package httptines
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Benchmark", func() {
+	Describe("Benchmark()", func() {
+		It("reports the result of each strategy", func() {
+			results, err := Benchmark(context.Background(), BenchmarkConfig{
+				Proxies: 2,
+				Targets: 10,
+				Latency: 10,
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(results).To(HaveLen(2))
+			Expect(results[0].Strategy).To(Equal("minimal"))
+			Expect(results[1].Strategy).To(Equal("auto"))
+			for _, r := range results {
+				Expect(r.Throughput).To(BeNumerically(">", 0))
+				Expect(r.String()).To(ContainSubstring("req/s"))
+			}
+		})
+	})
+})
@@ -0,0 +1,128 @@
+package httptines
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrDecompressionBomb is returned when a response body exceeds the size or compression ratio limits.
+var ErrDecompressionBomb = errors.New("decompression bomb")
+
+// ratioCheckThreshold is the decompressed size after which the compression ratio is checked.
+const ratioCheckThreshold = 1 << 20
+
+// countingReader counts the bytes read from the underlying reader.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements the io.Reader interface.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// guardedReader fails when the decompressed data exceeds the size or ratio limits.
+type guardedReader struct {
+	r        io.Reader
+	raw      *countingReader
+	n        int64
+	maxSize  int64
+	maxRatio int64
+}
+
+// Read implements the io.Reader interface.
+func (g *guardedReader) Read(p []byte) (int, error) {
+	n, err := g.r.Read(p)
+	g.n += int64(n)
+
+	if g.maxSize > 0 && g.n > g.maxSize {
+		return n, ErrDecompressionBomb
+	}
+
+	if g.maxRatio > 0 && g.n > ratioCheckThreshold && g.raw.n > 0 && g.n/g.raw.n > g.maxRatio {
+		return n, ErrDecompressionBomb
+	}
+
+	return n, err
+}
+
+// readBody reads the response body, decompressing gzip content and enforcing the server's limits.
+// Parameters:
+//   - resp: HTTP response
+//   - s: Server holding the limits
+//
+// Returns:
+//   - []byte: Decompressed body
+//   - int64: Number of bytes read from the wire
+//   - error: ErrDecompressionBomb if a limit is exceeded or any read error
+func readBody(resp *http.Response, s *Server) ([]byte, int64, error) {
+	raw := &countingReader{r: resp.Body}
+	var r io.Reader = raw
+
+	if strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		gr, err := gzip.NewReader(raw)
+		if err != nil {
+			return nil, raw.n, err
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	body, err := io.ReadAll(&guardedReader{
+		r:        r,
+		raw:      raw,
+		maxSize:  int64(s.maxBody),
+		maxRatio: int64(s.maxRatio),
+	})
+	return body, raw.n, err
+}
+
+// requestSize estimates the number of bytes sent for the request.
+// Parameters:
+//   - req: HTTP request
+//
+// Returns:
+//   - int64: Total size of the request line, headers and body
+//   - int64: Size of the headers (overhead)
+func requestSize(req *http.Request) (int64, int64) {
+	c := &countingWriter{}
+	req.Header.Write(c)
+	headers := c.n + int64(len("Host: \r\n\r\n")+len(req.Host))
+	line := int64(len(req.Method) + len(req.URL.String()) + len("  HTTP/1.1\r\n"))
+
+	body := req.ContentLength
+	if body < 0 {
+		body = 0
+	}
+
+	return line + headers + body, headers
+}
+
+// responseSize estimates the number of bytes of the response status line and headers.
+// Parameters:
+//   - resp: HTTP response
+//
+// Returns:
+//   - int64: Size of the status line and headers
+func responseSize(resp *http.Response) int64 {
+	c := &countingWriter{}
+	resp.Header.Write(c)
+	return c.n + int64(len(resp.Proto)+len(resp.Status)+len(" \r\n\r\n"))
+}
+
+// countingWriter counts the bytes written to it.
+type countingWriter struct {
+	n int64
+}
+
+// Write implements the io.Writer interface.
+func (c *countingWriter) Write(p []byte) (int, error) {
+	c.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,92 @@
+package httptines
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Body", func() {
+	var s *Server
+
+	gzipped := func(data []byte) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write(data)
+		zw.Close()
+		return buf.Bytes()
+	}
+
+	response := func(body []byte, encoding string) *http.Response {
+		resp := &http.Response{Header: http.Header{}, Body: io.NopCloser(bytes.NewReader(body))}
+		if encoding != "" {
+			resp.Header.Set("Content-Encoding", encoding)
+		}
+		return resp
+	}
+
+	BeforeEach(func() {
+		s = &Server{maxBody: 4 << 20, maxRatio: 100}
+	})
+
+	Describe("readBody()", func() {
+		It("reads a plain body", func() {
+			body, _, err := readBody(response([]byte("plain"), ""), s)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(Equal("plain"))
+		})
+
+		It("decompresses a gzip body", func() {
+			data := gzipped([]byte("compressed"))
+
+			body, n, err := readBody(response(data, "gzip"), s)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(body)).To(Equal("compressed"))
+			Expect(n).To(Equal(int64(len(data))))
+		})
+
+		It("rejects a body exceeding the size limit", func() {
+			s.maxBody = 10
+			_, _, err := readBody(response([]byte("more than ten bytes"), ""), s)
+			Expect(errors.Is(err, ErrDecompressionBomb)).To(BeTrue())
+		})
+
+		It("rejects a body exceeding the compression ratio", func() {
+			s.maxBody = 0
+			bomb := gzipped(make([]byte, 8<<20))
+
+			_, _, err := readBody(response(bomb, "gzip"), s)
+			Expect(errors.Is(err, ErrDecompressionBomb)).To(BeTrue())
+		})
+
+		When("no limits are set", func() {
+			It("reads the whole body", func() {
+				body, _, err := readBody(response(gzipped(make([]byte, 8<<20)), "gzip"), &Server{})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(body).To(HaveLen(8 << 20))
+			})
+		})
+	})
+	Describe("requestSize()", func() {
+		It("counts the request line, headers and body", func() {
+			req, _ := http.NewRequest(http.MethodPost, "http://example.com/path", bytes.NewReader([]byte("12345")))
+			req.Header.Set("User-Agent", "agent")
+
+			total, headers := requestSize(req)
+			Expect(headers).To(Equal(int64(len("User-Agent: agent\r\n") + len("Host: example.com\r\n\r\n"))))
+			Expect(total).To(Equal(int64(len("POST http://example.com/path HTTP/1.1\r\n")) + headers + 5))
+		})
+	})
+
+	Describe("responseSize()", func() {
+		It("counts the status line and headers", func() {
+			resp := &http.Response{Proto: "HTTP/1.1", Status: "200 OK", Header: http.Header{"Via": {"proxy"}}}
+			Expect(responseSize(resp)).To(Equal(int64(len("HTTP/1.1 200 OK\r\nVia: proxy\r\n\r\n"))))
+		})
+	})
+})
@@ -0,0 +1,80 @@
+package httptines
+
+import (
+	"cmp"
+	"fmt"
+	"runtime/debug"
+	"sync"
+)
+
+// modulePath is the import path of this module.
+const modulePath = "github.com/grishkovelli/httptines"
+
+// BuildInfo describes the build of the running binary.
+type BuildInfo struct {
+	// Version is the version of httptines, "(devel)" for local builds
+	Version string `json:"version"`
+	// Commit is the VCS revision the binary was built from, empty if unknown
+	Commit string `json:"commit,omitempty"`
+	// Modified reports whether the working tree had uncommitted changes
+	Modified bool `json:"modified,omitempty"`
+	// GoVersion is the Go version the binary was built with
+	GoVersion string `json:"goVersion"`
+}
+
+// String returns the build as a single line.
+// Returns:
+//   - string: Version, commit and Go version
+func (b BuildInfo) String() string {
+	s := "httptines " + b.Version
+	if b.Commit != "" {
+		s += fmt.Sprintf(" (commit %s", b.Commit)
+		if b.Modified {
+			s += ", modified"
+		}
+		s += ")"
+	}
+	return s + ", " + b.GoVersion
+}
+
+// build returns the build info of the running binary, read once.
+var build = sync.OnceValue(func() BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return BuildInfo{Version: "unknown"}
+	}
+	return buildInfo(info)
+})
+
+// buildInfo extracts the version of httptines and the VCS settings from the build info.
+// Parameters:
+//   - info: Build info of the binary
+//
+// Returns:
+//   - BuildInfo: Build of the running binary
+func buildInfo(info *debug.BuildInfo) BuildInfo {
+	b := BuildInfo{Version: "unknown", GoVersion: info.GoVersion}
+
+	if info.Main.Path == modulePath {
+		b.Version = info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			b.Version = dep.Version
+			if dep.Replace != nil {
+				// Local replacements have no version
+				b.Version = cmp.Or(dep.Replace.Version, "(devel)")
+			}
+		}
+	}
+
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			b.Commit = s.Value
+		case "vcs.modified":
+			b.Modified = s.Value == "true"
+		}
+	}
+	return b
+}
@@ -0,0 +1,45 @@
+package httptines
+
+import (
+	"runtime/debug"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Build", func() {
+	Describe("buildInfo()", func() {
+		It("reads the version of the dependency and the VCS settings", func() {
+			b := buildInfo(&debug.BuildInfo{
+				GoVersion: "go1.24.1",
+				Main:      debug.Module{Path: "example.com/scraper", Version: "(devel)"},
+				Deps:      []*debug.Module{{Path: modulePath, Version: "v1.2.3"}},
+				Settings: []debug.BuildSetting{
+					{Key: "vcs.revision", Value: "abc123"},
+					{Key: "vcs.modified", Value: "true"},
+				},
+			})
+
+			Expect(b).To(Equal(BuildInfo{Version: "v1.2.3", Commit: "abc123", Modified: true, GoVersion: "go1.24.1"}))
+		})
+
+		It("reads the version of the main module", func() {
+			b := buildInfo(&debug.BuildInfo{Main: debug.Module{Path: modulePath, Version: "(devel)"}})
+			Expect(b.Version).To(Equal("(devel)"))
+		})
+
+		It("treats local replacements as development builds", func() {
+			b := buildInfo(&debug.BuildInfo{Deps: []*debug.Module{
+				{Path: modulePath, Version: "v1.2.3", Replace: &debug.Module{Path: "../httptines", Version: ""}},
+			}})
+			Expect(b.Version).To(Equal("(devel)"))
+		})
+	})
+
+	Describe("String()", func() {
+		It("describes the build", func() {
+			b := BuildInfo{Version: "v1.2.3", Commit: "abc123", Modified: true, GoVersion: "go1.24.1"}
+			Expect(b.String()).To(Equal("httptines v1.2.3 (commit abc123, modified), go1.24.1"))
+		})
+	})
+})
@@ -0,0 +1,174 @@
+package httptines
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// cacheTokenParam is the query parameter carrying the cache-busting token.
+const cacheTokenParam = "httptines_token"
+
+// cacheCheckAttempts is the number of times the cache check is requested before a
+// proxy whose check keeps failing is treated as serving cached content.
+const cacheCheckAttempts = 2
+
+// checkServer determines the server's capacity and runs the configured quality checks.
+// Parameters:
+//   - s: Server to check
+//
+// Returns:
+//   - bool: True if the server can be used for scraping
+func (w *Worker) checkServer(s *Server) bool {
+	target := w.TestTarget
+	if len(w.TestTargets) > 0 {
+		if target = w.passQuorum(s); target == "" {
+			return false
+		}
+	}
+
+	s.computeCapacity(w.Strategy, target, w.TestContent, w.MaxCapacity)
+	if s.Capacity == 0 {
+		return false
+	}
+
+	if w.CacheCheckTarget != "" && s.servesCache(w.CacheCheckTarget) {
+		if w.CachingProxies != "tag" {
+			return false
+		}
+		s.Cached = true
+	}
+
+	if w.AnonymityJudge != "" {
+		level, err := s.judgeAnonymity(w.AnonymityJudge, w.clientIP())
+		if err == nil {
+			s.Anonymity = level
+		}
+		if !anonymousEnough(s.Anonymity, w.MinAnonymity) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// testTargetUp fetches the test target directly, without a proxy, after no proxy
+// passed the check, so an outage of the test target isn't mistaken for a pool of dead proxies.
+// Returns:
+//   - bool: True if the test target responds or the control fetch is disabled
+func (w *Worker) testTargetUp() bool {
+	if w.TargetOutage == "ignore" {
+		return true
+	}
+
+	err := reachable(w.requestContext(), w.TestTarget, w.requestTimeout())
+	if err != nil {
+		w.werr(fmt.Sprintf("test target %s is down: %v, failed checks aren't counted", w.TestTarget, err))
+		return false
+	}
+	return true
+}
+
+// passQuorum checks the server against TestTarget and all TestTargets in parallel
+// and records the results.
+// Parameters:
+//   - s: Server to check
+//
+// Returns:
+//   - string: First passed test URL, TestTarget first, empty if the server didn't pass TestQuorum of them
+func (w *Worker) passQuorum(s *Server) string {
+	urls := append([]string{w.TestTarget}, w.TestTargets...)
+	passed := make([]bool, len(urls))
+
+	ctx, cancel := context.WithCancel(s.context())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i, t := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			passed[i] = s.probe(ctx, t, w.TestContent) == nil
+		}()
+	}
+	wg.Wait()
+
+	first, n := "", 0
+	tests := make(map[string]bool, len(urls))
+	for i, t := range urls {
+		tests[t] = passed[i]
+		if passed[i] {
+			n++
+			if first == "" {
+				first = t
+			}
+		}
+	}
+
+	s.m.Lock()
+	s.Tests = tests
+	s.m.Unlock()
+
+	if n < w.quorum() {
+		return ""
+	}
+	return first
+}
+
+// quorum returns the number of test URLs a proxy has to pass.
+// Returns:
+//   - int: TestQuorum, or the number of test URLs if it isn't set
+func (w *Worker) quorum() int {
+	if w.TestQuorum > 0 {
+		return w.TestQuorum
+	}
+	return len(w.TestTargets) + 1
+}
+
+// servesCache checks whether the server serves cached content. A failed check says
+// nothing about the proxy, so it's retried, and a proxy whose checks all fail is
+// treated as caching rather than trusted.
+// Parameters:
+//   - target: URL echoing its query string in the response body
+//
+// Returns:
+//   - bool: True if the proxy serves cached content or it couldn't be determined
+func (s *Server) servesCache(target string) bool {
+	for range cacheCheckAttempts {
+		if cached, err := s.detectCache(target); err == nil {
+			return cached
+		}
+	}
+	return true
+}
+
+// detectCache requests the target with a unique token and checks it is echoed back.
+// Parameters:
+//   - target: URL echoing its query string in the response body
+//
+// Returns:
+//   - bool: True if the response doesn't contain the token, i.e. it was served from a cache
+//   - error: Any error that occurred during the request
+func (s *Server) detectCache(target string) (bool, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return false, err
+	}
+
+	token := sessionID()
+	q := u.Query()
+	q.Set(cacheTokenParam, token)
+	u.RawQuery = q.Encode()
+
+	ctx, cancel := context.WithCancel(s.context())
+	defer cancel()
+
+	rep, err := request(ctx, u.String(), s, reqOpts{agent: s.agent})
+	if err != nil {
+		return false, err
+	}
+
+	return !bytes.Contains(rep.body, []byte(token)), nil
+}
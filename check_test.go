@@ -0,0 +1,346 @@
+package httptines
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Check", func() {
+	var (
+		w      *Worker
+		echo   *httptest.Server
+		target *httptest.Server
+	)
+
+	newServer := func(u *url.URL) *Server {
+		return &Server{URL: u, timeout: time.Second, agent: "checker/1.0", ctx: context.Background()}
+	}
+
+	BeforeEach(func() {
+		echo = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(r.URL.RawQuery))
+		}))
+		target = mockHTTPServer("ok")
+
+		w = &Worker{Strategy: "minimal", TestTarget: target.URL, CacheCheckTarget: echo.URL, CachingProxies: "exclude"}
+	})
+
+	AfterEach(func() {
+		echo.Close()
+		target.Close()
+	})
+
+	Describe("detectCache()", func() {
+		It("returns false for a transparent proxy", func() {
+			proxy, proxyURL := mockProxyServer(0)
+			defer proxy.Close()
+
+			cached, err := newServer(proxyURL).detectCache(echo.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cached).To(BeFalse())
+		})
+
+		It("returns true for a caching proxy", func() {
+			proxy, proxyURL := mockCachingProxy()
+			defer proxy.Close()
+
+			cached, err := newServer(proxyURL).detectCache(echo.URL)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(cached).To(BeTrue())
+		})
+	})
+
+	Describe("checkServer()", func() {
+		It("accepts a transparent proxy", func() {
+			proxy, proxyURL := mockProxyServer(0)
+			defer proxy.Close()
+
+			s := newServer(proxyURL)
+			Expect(w.checkServer(s)).To(BeTrue())
+			Expect(s.Cached).To(BeFalse())
+		})
+
+		It("records the check latency", func() {
+			proxy, proxyURL := mockProxyServer(0)
+			defer proxy.Close()
+
+			s := newServer(proxyURL)
+			Expect(w.checkServer(s)).To(BeTrue())
+			Expect(s.CheckLatency).To(BeNumerically(">=", 10))
+		})
+
+		It("stops probing a proxy that never fails at MaxCapacity", func() {
+			proxy, proxyURL := mockProxyServer(0)
+			defer proxy.Close()
+
+			w.Strategy, w.MaxCapacity = "auto", 3
+			s := newServer(proxyURL)
+			Expect(w.checkServer(s)).To(BeTrue())
+			Expect(s.Capacity).To(Equal(3))
+		})
+
+		It("excludes a caching proxy", func() {
+			proxy, proxyURL := mockCachingProxy()
+			defer proxy.Close()
+
+			Expect(w.checkServer(newServer(proxyURL))).To(BeFalse())
+		})
+
+		It("retries a failed cache check and excludes the proxy", func() {
+			checks := 0
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Has(cacheTokenParam) {
+					checks++
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				w.Write([]byte("ok"))
+			}))
+			defer proxy.Close()
+			proxyURL, _ := url.Parse(proxy.URL)
+
+			Expect(w.checkServer(newServer(proxyURL))).To(BeFalse())
+			Expect(checks).To(Equal(cacheCheckAttempts))
+		})
+
+		It("tags a proxy whose cache check failed", func() {
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Has(cacheTokenParam) {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				w.Write([]byte("ok"))
+			}))
+			defer proxy.Close()
+			proxyURL, _ := url.Parse(proxy.URL)
+
+			w.CachingProxies = "tag"
+			s := newServer(proxyURL)
+			Expect(w.checkServer(s)).To(BeTrue())
+			Expect(s.Cached).To(BeTrue())
+		})
+
+		It("accepts a proxy whose cache check succeeds on retry", func() {
+			checks := 0
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Has(cacheTokenParam) {
+					if checks++; checks == 1 {
+						w.WriteHeader(http.StatusBadGateway)
+						return
+					}
+					w.Write([]byte(r.URL.RawQuery))
+					return
+				}
+				w.Write([]byte("ok"))
+			}))
+			defer proxy.Close()
+			proxyURL, _ := url.Parse(proxy.URL)
+
+			s := newServer(proxyURL)
+			Expect(w.checkServer(s)).To(BeTrue())
+			Expect(s.Cached).To(BeFalse())
+		})
+
+		It("rejects a proxy altering the test target's content", func() {
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("<html>Please log in</html>"))
+			}))
+			defer proxy.Close()
+			proxyURL, _ := url.Parse(proxy.URL)
+
+			w.TestContent = &IntegrityRule{Contains: "ok"}
+			Expect(w.checkServer(newServer(proxyURL))).To(BeFalse())
+		})
+
+		It("checks the content of the capacity check without another request", func() {
+			requests := 0
+			proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				w.Write([]byte("ok"))
+			}))
+			defer proxy.Close()
+			proxyURL, _ := url.Parse(proxy.URL)
+
+			w.CacheCheckTarget = ""
+			w.TestContent = &IntegrityRule{Contains: "ok"}
+			Expect(w.checkServer(newServer(proxyURL))).To(BeTrue())
+			Expect(requests).To(Equal(1))
+		})
+
+		It("accepts a proxy serving the expected content", func() {
+			proxy, proxyURL := mockProxyServer(0)
+			defer proxy.Close()
+
+			w.TestContent = &IntegrityRule{Pattern: "^ok$"}
+			Expect(w.checkServer(newServer(proxyURL))).To(BeTrue())
+		})
+
+		Context("with several test URLs", func() {
+			var blocked *httptest.Server
+
+			BeforeEach(func() {
+				blocked = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(http.StatusForbidden)
+				}))
+				w.TestTargets = []string{echo.URL, blocked.URL}
+			})
+
+			AfterEach(func() {
+				blocked.Close()
+			})
+
+			It("requires all test URLs by default", func() {
+				proxy, proxyURL := mockProxyServer(0)
+				defer proxy.Close()
+
+				s := newServer(proxyURL)
+				Expect(w.checkServer(s)).To(BeFalse())
+				Expect(s.Tests).To(Equal(map[string]bool{target.URL: true, echo.URL: true, blocked.URL: false}))
+			})
+
+			It("checks the content of the test URLs", func() {
+				proxy, proxyURL := mockProxyServer(0)
+				defer proxy.Close()
+
+				w.TestTargets = []string{target.URL + "/other", echo.URL}
+				w.TestContent = &IntegrityRule{Contains: "ok"}
+				s := newServer(proxyURL)
+				Expect(w.checkServer(s)).To(BeFalse())
+				Expect(s.Tests).To(Equal(map[string]bool{target.URL: true, target.URL + "/other": true, echo.URL: false}))
+			})
+
+			It("accepts a proxy passing the quorum", func() {
+				proxy, proxyURL := mockProxyServer(0)
+				defer proxy.Close()
+
+				w.TestQuorum = 2
+				Expect(w.checkServer(newServer(proxyURL))).To(BeTrue())
+			})
+
+			It("counts every test URL if the test target fails", func() {
+				proxy, proxyURL := mockProxyServer(0)
+				defer proxy.Close()
+
+				w.TestTarget = blocked.URL
+				w.TestTargets = []string{echo.URL, target.URL}
+				w.TestQuorum = 2
+				s := newServer(proxyURL)
+				Expect(w.checkServer(s)).To(BeTrue())
+				Expect(s.Capacity).To(Equal(1))
+				Expect(s.Tests).To(Equal(map[string]bool{blocked.URL: false, echo.URL: true, target.URL: true}))
+			})
+
+			It("rejects a proxy failing all test URLs", func() {
+				proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+					w.WriteHeader(http.StatusBadGateway)
+				}))
+				defer proxy.Close()
+				proxyURL, _ := url.Parse(proxy.URL)
+
+				w.TestQuorum = 1
+				s := newServer(proxyURL)
+				Expect(w.checkServer(s)).To(BeFalse())
+				Expect(s.Tests).To(Equal(map[string]bool{target.URL: false, echo.URL: false, blocked.URL: false}))
+			})
+		})
+
+		It("tags a caching proxy", func() {
+			proxy, proxyURL := mockCachingProxy()
+			defer proxy.Close()
+
+			w.CachingProxies = "tag"
+			s := newServer(proxyURL)
+			Expect(w.checkServer(s)).To(BeTrue())
+			Expect(s.Cached).To(BeTrue())
+		})
+	})
+
+	Describe("checkFetched()", func() {
+		var down *httptest.Server
+
+		BeforeEach(func() {
+			down = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+
+			l, err := loadBanList(filepath.Join(GinkgoT().TempDir(), "bans.json"), 1, time.Hour)
+			Expect(err).NotTo(HaveOccurred())
+			w.banList = l
+			w.Timeout = 1
+			w.Workers = 1
+			w.TargetOutage = "skip"
+			w.Transport = func(*url.URL) http.RoundTripper {
+				return roundTripFunc(func(*http.Request) (*http.Response, error) {
+					return nil, errors.New("refused")
+				})
+			}
+		})
+
+		AfterEach(func() {
+			down.Close()
+		})
+
+		It("counts failed checks while the test target is up", func() {
+			u, _ := url.Parse("http://10.0.0.1:8080")
+			Expect(w.checkFetched(proxyMap{proxyKey(u): u}, true)).To(BeTrue())
+			Expect(w.banList.strikes).To(HaveKey(u.Host))
+		})
+
+		It("doesn't count failed checks during a test target outage", func() {
+			w.TestTarget = down.URL
+			u, _ := url.Parse("http://10.0.0.1:8080")
+			Expect(w.checkFetched(proxyMap{proxyKey(u): u}, true)).To(BeTrue())
+			Expect(w.banList.strikes).To(BeEmpty())
+		})
+
+		It("doesn't count failed checks without a download", func() {
+			u, _ := url.Parse("http://10.0.0.1:8080")
+			Expect(w.checkFetched(proxyMap{proxyKey(u): u}, false)).To(BeTrue())
+			Expect(w.banList.strikes).To(BeEmpty())
+		})
+	})
+
+	Describe("testTargetUp()", func() {
+		BeforeEach(func() {
+			w.Timeout = 1
+			w.TargetOutage = "skip"
+		})
+
+		It("reports a responding test target", func() {
+			Expect(w.testTargetUp()).To(BeTrue())
+		})
+
+		It("reports a test target outage", func() {
+			down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer down.Close()
+
+			w.TestTarget = down.URL
+			Expect(w.testTargetUp()).To(BeFalse())
+		})
+
+		It("skips the control fetch when outages are ignored", func() {
+			w.TestTarget = "http://127.0.0.1:1"
+			w.TargetOutage = "ignore"
+			Expect(w.testTargetUp()).To(BeTrue())
+		})
+	})
+})
+
+// mockCachingProxy returns a proxy answering every request with the same stale body.
+func mockCachingProxy() (*httptest.Server, *url.URL) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("stale"))
+	}))
+	u, _ := url.Parse(s.URL)
+	return s, u
+}
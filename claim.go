@@ -0,0 +1,275 @@
+package httptines
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// claimPoll is the longest wait before a target leased by another process is claimed again.
+const claimPoll = 5 * time.Second
+
+// Outcomes of a claim.
+const (
+	claimGranted = iota // The lease was granted to this process
+	claimHeld           // Another process holds an unexpired lease
+	claimDone           // Another process finished the target
+)
+
+// lease is the claim of a target, stored in a file named after the target's hash.
+type lease struct {
+	// Owner identifies the process holding the lease
+	Owner string `json:"owner"`
+	// Expires is the time the lease can be taken over by another process
+	Expires time.Time `json:"expires"`
+	// Done indicates that the owner finished the target
+	Done bool `json:"done"`
+}
+
+// claimStore hands out leases on targets to processes sharing a directory.
+// Changes are made under an advisory lock on the directory's lock file.
+type claimStore struct {
+	dir   string
+	owner string
+	ttl   time.Duration
+	m     sync.Mutex
+}
+
+// newClaimStore creates the directory and an owner ID for this process.
+// Parameters:
+//   - dir: Shared directory
+//   - ttl: Lease duration
+//
+// Returns:
+//   - *claimStore: Claim store
+//   - error: Any error that occurred while creating the directory
+func newClaimStore(dir string, ttl time.Duration) (*claimStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	host, _ := os.Hostname()
+	return &claimStore{
+		dir:   dir,
+		owner: fmt.Sprintf("%s-%d-%s", host, os.Getpid(), rand.Text()[:8]),
+		ttl:   ttl,
+	}, nil
+}
+
+// claim takes or renews the lease on the target. Expired leases of other
+// processes are taken over, so targets of a crashed process aren't lost.
+// Parameters:
+//   - t: Target URL
+//   - now: Current time
+//
+// Returns:
+//   - int: claimGranted, claimHeld or claimDone
+//   - time.Time: Expiration of the lease held by another process
+//   - error: Any error that occurred while accessing the directory
+func (c *claimStore) claim(t string, now time.Time) (int, time.Time, error) {
+	outcome, expires := claimGranted, time.Time{}
+
+	err := c.locked(func() error {
+		l, err := c.read(t)
+		if err != nil {
+			return err
+		}
+
+		if l != nil && l.Owner != c.owner {
+			if l.Done {
+				outcome = claimDone
+				return nil
+			}
+			if now.Before(l.Expires) {
+				outcome, expires = claimHeld, l.Expires
+				return nil
+			}
+		}
+		return c.write(t, lease{Owner: c.owner, Expires: now.Add(c.ttl)})
+	})
+	return outcome, expires, err
+}
+
+// finish marks the target as done, so other processes skip it.
+// Parameters:
+//   - t: Target URL
+//
+// Returns:
+//   - error: Any error that occurred while accessing the directory
+func (c *claimStore) finish(t string) error {
+	return c.locked(func() error {
+		return c.write(t, lease{Owner: c.owner, Done: true})
+	})
+}
+
+// unfinished drops the targets done by any process.
+// Parameters:
+//   - targets: Targets to filter
+//
+// Returns:
+//   - []string: Targets not done yet
+//   - error: Any error that occurred while accessing the directory
+func (c *claimStore) unfinished(targets []string) ([]string, error) {
+	var result []string
+	err := c.locked(func() error {
+		var err error
+		result, err = c.pending(targets)
+		return err
+	})
+	return result, err
+}
+
+// pending drops the targets done by any process. The caller must hold the directory lock.
+// Parameters:
+//   - targets: Targets to filter
+//
+// Returns:
+//   - []string: Targets not done yet
+//   - error: Any error that occurred while reading the leases
+func (c *claimStore) pending(targets []string) ([]string, error) {
+	var result []string
+	for _, t := range targets {
+		l, err := c.read(t)
+		if err != nil {
+			return nil, err
+		}
+		if l == nil || !l.Done {
+			result = append(result, t)
+		}
+	}
+	return result, nil
+}
+
+// locked runs fn holding the directory lock.
+// Parameters:
+//   - fn: Function to run
+//
+// Returns:
+//   - error: Error of fn or of locking
+func (c *claimStore) locked(fn func() error) error {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(c.dir, ".lock"), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := lockFile(f); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+
+	return fn()
+}
+
+// path returns the lease file of the target.
+// Parameters:
+//   - t: Target URL
+//
+// Returns:
+//   - string: File path
+func (c *claimStore) path(t string) string {
+	sum := sha256.Sum256([]byte(t))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// read reads the lease of the target.
+// Parameters:
+//   - t: Target URL
+//
+// Returns:
+//   - *lease: Lease, nil if the target wasn't claimed
+//   - error: Any error that occurred while reading
+func (c *claimStore) read(t string) (*lease, error) {
+	data, err := os.ReadFile(c.path(t))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var l lease
+	if err := json.Unmarshal(data, &l); err != nil {
+		return nil, fmt.Errorf("lease of %s: %w", t, err)
+	}
+	return &l, nil
+}
+
+// write writes the lease of the target.
+// Parameters:
+//   - t: Target URL
+//   - l: Lease
+//
+// Returns:
+//   - error: Any error that occurred while writing
+func (c *claimStore) write(t string, l lease) error {
+	data, err := json.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path(t), data, 0o644)
+}
+
+// claimTargets keeps the targets this process holds the lease on. Targets
+// leased by another process are put back until it finishes them or the lease
+// expires, and targets finished by another process are counted as claimed and dropped.
+// Parameters:
+//   - targets: Targets taken out of the queue
+//   - requeue: Puts a target back into the lane it was taken from
+//
+// Returns:
+//   - []string: Targets to process
+func (w *Worker) claimTargets(targets []string, requeue func(string)) []string {
+	if w.claims == nil || len(targets) == 0 {
+		return targets
+	}
+
+	now := time.Now()
+	granted := targets[:0:0]
+	for _, t := range targets {
+		outcome, expires, err := w.claims.claim(t, now)
+		if err != nil {
+			w.werr(fmt.Sprintf("error claiming %s: %v", t, err))
+			granted = append(granted, t)
+			continue
+		}
+
+		switch outcome {
+		case claimGranted:
+			granted = append(granted, t)
+		case claimDone:
+			w.stat.claim()
+			w.order.skip(t)
+		case claimHeld:
+			w.hold(t)
+			time.AfterFunc(min(expires.Sub(now), claimPoll), func() {
+				requeue(t)
+				w.unhold(t)
+			})
+		}
+	}
+	return granted
+}
+
+// finishClaim marks a finished target as done for the other processes.
+// Parameters:
+//   - t: Target URL
+func (w *Worker) finishClaim(t string) {
+	if w.claims == nil {
+		return
+	}
+	if err := w.claims.finish(t); err != nil {
+		w.werr(fmt.Sprintf("error finishing claim of %s: %v", t, err))
+	}
+}
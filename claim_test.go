@@ -0,0 +1,149 @@
+package httptines
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Claims", func() {
+	var (
+		dir  string
+		a, b *claimStore
+		now  time.Time
+	)
+
+	BeforeEach(func() {
+		dir = filepath.Join(GinkgoT().TempDir(), "claims")
+		now = time.Now()
+
+		var err error
+		a, err = newClaimStore(dir, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+		b, err = newClaimStore(dir, time.Minute)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	Describe("claimStore", func() {
+		It("grants a target to one process at a time", func() {
+			outcome, _, err := a.claim("http://test1.com", now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outcome).To(Equal(claimGranted))
+
+			outcome, expires, err := b.claim("http://test1.com", now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(outcome).To(Equal(claimHeld))
+			Expect(expires).To(BeTemporally("~", now.Add(time.Minute)))
+		})
+
+		It("renews the lease of the owner", func() {
+			a.claim("http://test1.com", now)
+
+			outcome, _, _ := a.claim("http://test1.com", now.Add(2*time.Minute))
+			Expect(outcome).To(Equal(claimGranted))
+		})
+
+		It("takes over an expired lease", func() {
+			a.claim("http://test1.com", now)
+
+			outcome, _, _ := b.claim("http://test1.com", now.Add(2*time.Minute))
+			Expect(outcome).To(Equal(claimGranted))
+
+			outcome, _, _ = a.claim("http://test1.com", now.Add(2*time.Minute))
+			Expect(outcome).To(Equal(claimHeld))
+		})
+
+		It("reports targets finished by another process", func() {
+			a.claim("http://test1.com", now)
+			Expect(a.finish("http://test1.com")).To(Succeed())
+
+			outcome, _, _ := b.claim("http://test1.com", now.Add(time.Hour))
+			Expect(outcome).To(Equal(claimDone))
+		})
+
+		It("filters out finished targets", func() {
+			a.finish("http://test1.com")
+			b.claim("http://test2.com", now)
+
+			targets, err := b.unfinished([]string{"http://test1.com", "http://test2.com", "http://test3.com"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(targets).To(Equal([]string{"http://test2.com", "http://test3.com"}))
+		})
+
+		It("fails on a malformed lease", func() {
+			Expect(os.WriteFile(a.path("http://test1.com"), []byte("{"), 0o644)).To(Succeed())
+
+			_, _, err := a.claim("http://test1.com", now)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("Worker", func() {
+		var w *Worker
+
+		BeforeEach(func() {
+			w = &Worker{claims: a, stat: &Stat{Targets: 3}}
+		})
+
+		It("processes only the targets it holds the lease on", func() {
+			b.claim("http://test2.com", now)
+			b.finish("http://test3.com")
+
+			Expect(w.claimTargets([]string{"http://test1.com", "http://test2.com", "http://test3.com"}, w.retrigger)).
+				To(Equal([]string{"http://test1.com"}))
+			Expect(w.stat.Claimed).To(Equal(1))
+			Expect(w.Unfinished()).To(ContainElement("http://test2.com"))
+		})
+
+		It("counts claimed targets as processed", func() {
+			w.stat.Targets = 1
+			b.finish("http://test1.com")
+			w.claimTargets([]string{"http://test1.com"}, w.retrigger)
+
+			Expect(w.stat.allTargetsProcessed()).To(BeTrue())
+		})
+
+		It("marks finished targets as done", func() {
+			w.claimTargets([]string{"http://test1.com"}, w.retrigger)
+			w.finishClaim("http://test1.com")
+
+			outcome, _, _ := b.claim("http://test1.com", now)
+			Expect(outcome).To(Equal(claimDone))
+		})
+
+		It("leaves finished targets out of a shared checkpoint", func() {
+			w.Checkpoint = filepath.Join(dir, "checkpoint.txt")
+			b.finish("http://test1.com")
+
+			Expect(w.writeCheckpoint([]string{"http://test1.com", "http://test2.com"})).To(Succeed())
+
+			data, err := os.ReadFile(w.Checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.Fields(string(data))).To(Equal([]string{"http://test2.com"}))
+		})
+
+		It("merges the checkpoint with the other processes' targets", func() {
+			w.Checkpoint = filepath.Join(dir, "checkpoint.txt")
+			Expect(os.WriteFile(w.Checkpoint, []byte("http://test3.com\nhttp://test1.com\n"), 0o644)).To(Succeed())
+			b.finish("http://test3.com")
+
+			Expect(w.writeCheckpoint([]string{"http://test1.com", "http://test2.com"})).To(Succeed())
+
+			data, err := os.ReadFile(w.Checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(strings.Fields(string(data))).To(Equal([]string{"http://test1.com", "http://test2.com"}))
+		})
+
+		It("fails the run if the claim directory can't be opened", func() {
+			file := filepath.Join(dir, "file")
+			Expect(os.WriteFile(file, nil, 0o644)).To(Succeed())
+
+			r := &Worker{Sources: proxySrc{"http": {"x"}}, TestTarget: "x", ClaimDir: filepath.Join(file, "claims")}
+			Expect(r.Run([]string{"http://test1.com"}, func([]byte) {})).To(MatchError(ContainSubstring("error opening claim directory")))
+		})
+	})
+})
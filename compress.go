@@ -0,0 +1,60 @@
+package httptines
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// compressBody compresses the result body with the worker's Compression algorithm.
+// Parameters:
+//   - r: Result to compress
+//
+// Returns:
+//   - Result: Result with the compressed body and its Encoding set
+//   - error: Any error that occurred while compressing
+func (w *Worker) compressBody(r Result) (Result, error) {
+	if w.Compression == "" || r.Encoding != "" {
+		return r, nil
+	}
+
+	newWriter := w.Compressors[w.Compression]
+	if newWriter == nil && w.Compression == "gzip" {
+		newWriter = newGzipWriter
+	}
+	if newWriter == nil {
+		return r, fmt.Errorf("unknown compression %q", w.Compression)
+	}
+
+	var b bytes.Buffer
+	zw, err := newWriter(&b, w.CompressionLevel)
+	if err != nil {
+		return r, err
+	}
+	if _, err := zw.Write(r.Body); err != nil {
+		return r, err
+	}
+	if err := zw.Close(); err != nil {
+		return r, err
+	}
+
+	r.Body = b.Bytes()
+	r.Encoding = w.Compression
+	return r, nil
+}
+
+// newGzipWriter creates a gzip writer. Level 0 means the default compression.
+// Parameters:
+//   - dst: Writer receiving the compressed data
+//   - level: Compression level
+//
+// Returns:
+//   - io.WriteCloser: Gzip writer
+//   - error: Error if the level is invalid
+func newGzipWriter(dst io.Writer, level int) (io.WriteCloser, error) {
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	return gzip.NewWriterLevel(dst, level)
+}
@@ -0,0 +1,96 @@
+package httptines
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Compression", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{}
+	})
+
+	Describe("compressBody()", func() {
+		It("leaves the body as is without compression", func() {
+			r, err := w.compressBody(Result{Body: []byte("good")})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Body).To(Equal([]byte("good")))
+			Expect(r.Encoding).To(BeEmpty())
+		})
+
+		It("compresses the body with gzip", func() {
+			w.Compression = "gzip"
+			w.CompressionLevel = gzip.BestCompression
+
+			r, err := w.compressBody(Result{Body: []byte("good")})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Encoding).To(Equal("gzip"))
+
+			zr, err := gzip.NewReader(bytes.NewReader(r.Body))
+			Expect(err).NotTo(HaveOccurred())
+			body, _ := io.ReadAll(zr)
+			Expect(string(body)).To(Equal("good"))
+		})
+
+		It("uses custom compressors", func() {
+			w.Compression = "zlib"
+			w.Compressors = map[string]func(io.Writer, int) (io.WriteCloser, error){
+				"zlib": func(dst io.Writer, _ int) (io.WriteCloser, error) { return zlib.NewWriter(dst), nil },
+			}
+
+			r, err := w.compressBody(Result{Body: []byte("good")})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(r.Encoding).To(Equal("zlib"))
+
+			zr, err := zlib.NewReader(bytes.NewReader(r.Body))
+			Expect(err).NotTo(HaveOccurred())
+			body, _ := io.ReadAll(zr)
+			Expect(string(body)).To(Equal("good"))
+		})
+
+		It("fails on unknown algorithms", func() {
+			w.Compression = "zstd"
+
+			r, err := w.compressBody(Result{Body: []byte("good")})
+			Expect(err).To(MatchError(`unknown compression "zstd"`))
+			Expect(r.Body).To(Equal([]byte("good")))
+		})
+
+		It("fails on invalid levels", func() {
+			w.Compression = "gzip"
+			w.CompressionLevel = 42
+
+			_, err := w.compressBody(Result{Body: []byte("good")})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("toStreamed()", func() {
+		It("encodes compressed bodies in base64", func() {
+			s := toStreamed(Result{Body: []byte{0x1f, 0x8b}, Encoding: "gzip"})
+			Expect(s.Body).To(Equal("H4s="))
+			Expect(s.Base64).To(BeTrue())
+			Expect(s.Encoding).To(Equal("gzip"))
+		})
+
+		It("encodes binary bodies in base64", func() {
+			s := toStreamed(Result{Body: []byte{0xff, 0xd8, 0xff}})
+			Expect(s.Body).To(Equal("/9j/"))
+			Expect(s.Base64).To(BeTrue())
+			Expect(s.Encoding).To(BeEmpty())
+		})
+
+		It("keeps text bodies as they are", func() {
+			s := toStreamed(Result{Body: []byte("<p>héllo</p>")})
+			Expect(s.Body).To(Equal("<p>héllo</p>"))
+			Expect(s.Base64).To(BeFalse())
+		})
+	})
+})
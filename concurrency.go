@@ -0,0 +1,271 @@
+package httptines
+
+import (
+	"fmt"
+	"sync"
+)
+
+// limiter is a resizable semaphore limiting the number of in-flight requests.
+// The zero value has no limit.
+type limiter struct {
+	m      sync.Mutex
+	limit  int
+	share  int // Percentage of the limit reserved for priority requests
+	active int
+	wait   chan struct{}
+}
+
+// acquire blocks until a slot is available. Regular requests can't take
+// the slots reserved for priority requests.
+// Parameters:
+//   - priority: Whether the request belongs to the priority lane
+func (l *limiter) acquire(priority bool) {
+	for {
+		l.m.Lock()
+		bound := l.limit
+		if !priority {
+			bound -= reserved(l.limit, l.share)
+		}
+		if l.limit == 0 || l.active < bound {
+			l.active++
+			l.m.Unlock()
+			return
+		}
+		if l.wait == nil {
+			l.wait = make(chan struct{})
+		}
+		ch := l.wait
+		l.m.Unlock()
+		<-ch
+	}
+}
+
+// release frees a slot and wakes up waiting goroutines.
+func (l *limiter) release() {
+	l.m.Lock()
+	l.active--
+	l.wake()
+	l.m.Unlock()
+}
+
+// resize changes the limit by delta. An unlimited limiter shrinks relative
+// to the current number of in-flight requests and ignores growth.
+// Parameters:
+//   - delta: Change of the limit
+//
+// Returns:
+//   - int: New limit, 0 means unlimited
+func (l *limiter) resize(delta int) int {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.limit == 0 {
+		if delta > 0 {
+			return 0
+		}
+		l.limit = l.active
+	}
+
+	l.limit = max(l.limit+delta, 1)
+	l.wake()
+	return l.limit
+}
+
+// wake releases all goroutines waiting for a slot. The caller must hold the lock.
+func (l *limiter) wake() {
+	if l.wait != nil {
+		close(l.wait)
+		l.wait = nil
+	}
+}
+
+// scale changes the concurrency limit by the given number of steps.
+// Parameters:
+//   - steps: Number of ConcurrencyStep increments, negative to decrease
+//
+// Returns:
+//   - int: New concurrency limit, 0 means unlimited
+func (w *Worker) scale(steps int) int {
+	n := w.limiter.resize(steps * w.ConcurrencyStep)
+	if n == 0 {
+		w.wlog("concurrency is unlimited")
+	} else {
+		w.wlog(fmt.Sprintf("concurrency set to %d", n))
+	}
+	return n
+}
+
+// workerSlots limits the number of proxy servers processing targets at the same time.
+// The zero value has no limit.
+type workerSlots struct {
+	m       sync.Mutex
+	limit   int
+	base    int // Number of workers the run started with
+	active  int
+	resized chan struct{}
+}
+
+// inflight returns the number of concurrent requests a server may have. Servers keep
+// their capacity up to the number of workers the run started with, and get a
+// proportional share of the workers added beyond it.
+// Parameters:
+//   - capacity: Capacity of the server
+//
+// Returns:
+//   - int: Concurrency limit of the server
+func (ws *workerSlots) inflight(capacity int) int {
+	ws.m.Lock()
+	defer ws.m.Unlock()
+
+	if ws.base == 0 || ws.limit <= ws.base {
+		return capacity
+	}
+	return capacity * ws.limit / ws.base
+}
+
+// acquire takes a slot if one is free.
+// Returns:
+//   - bool: True if the slot was taken
+func (ws *workerSlots) acquire() bool {
+	ws.m.Lock()
+	defer ws.m.Unlock()
+
+	if ws.limit > 0 && ws.active >= ws.limit {
+		return false
+	}
+	ws.active++
+	return true
+}
+
+// release frees a slot.
+func (ws *workerSlots) release() {
+	ws.m.Lock()
+	ws.active--
+	ws.m.Unlock()
+}
+
+// shed frees a slot if more slots are taken than the limit allows.
+// Returns:
+//   - bool: True if the slot was freed
+func (ws *workerSlots) shed() bool {
+	ws.m.Lock()
+	defer ws.m.Unlock()
+
+	if ws.limit == 0 || ws.active <= ws.limit {
+		return false
+	}
+	ws.active--
+	return true
+}
+
+// SetWorkers changes the number of proxy servers processing targets at the same time
+// while a run is active. When shrinking, the servers over the limit finish their
+// in-flight requests and wait. Beyond the number of workers the run started with,
+// every server takes a proportional share of the added requests. The buffer of
+// servers waiting to be processed is resized, and proxy checks of the next cycle
+// use the new value too.
+// Parameters:
+//   - n: Number of workers, at least 1
+func (w *Worker) SetWorkers(n int) {
+	n = max(n, 1)
+
+	w.m.Lock()
+	w.Workers = n
+	w.m.Unlock()
+
+	w.slots.m.Lock()
+	w.slots.limit = n
+	var leftovers []*Server
+	if w.srvCh != nil {
+		old := w.srvCh
+		w.srvCh = make(chan *Server, n)
+		leftovers = moveServers(old, w.srvCh)
+	}
+	if w.slots.resized != nil {
+		close(w.slots.resized)
+		w.slots.resized = nil
+	}
+	w.slots.m.Unlock()
+
+	for _, s := range leftovers {
+		go w.handOff(s)
+	}
+
+	w.wlog(fmt.Sprintf("workers set to %d", n))
+}
+
+// moveServers moves the buffered servers to the resized channel.
+// Parameters:
+//   - from: Previous channel
+//   - to: Resized channel
+//
+// Returns:
+//   - []*Server: Servers that didn't fit into the resized channel
+func moveServers(from, to chan *Server) []*Server {
+	var leftovers []*Server
+	for {
+		select {
+		case s := <-from:
+			select {
+			case to <- s:
+			default:
+				leftovers = append(leftovers, s)
+			}
+		default:
+			return leftovers
+		}
+	}
+}
+
+// serverQueue returns the channel servers are handed over on, along with a channel
+// closed once SetWorkers replaces it.
+// Returns:
+//   - chan *Server: Current channel of servers
+//   - <-chan struct{}: Channel closed when the channel of servers is resized
+func (w *Worker) serverQueue() (chan *Server, <-chan struct{}) {
+	w.slots.m.Lock()
+	defer w.slots.m.Unlock()
+
+	if w.slots.resized == nil {
+		w.slots.resized = make(chan struct{})
+	}
+	return w.srvCh, w.slots.resized
+}
+
+// handOff passes the server to the run loop, following the channel when it's resized.
+// Parameters:
+//   - s: Server to process
+//
+// Returns:
+//   - bool: False if the worker stopped meanwhile
+func (w *Worker) handOff(s *Server) bool {
+	for {
+		ch, resized := w.serverQueue()
+		select {
+		case ch <- s:
+			return true
+		case <-resized:
+		case <-w.ctx.Done():
+			return false
+		}
+	}
+}
+
+// workers returns the current number of workers.
+// Returns:
+//   - int: Number of workers
+func (w *Worker) workers() int {
+	w.m.RLock()
+	defer w.m.RUnlock()
+	return w.Workers
+}
+
+// checkers returns the number of proxies checked at once.
+// Returns:
+//   - int: CheckConcurrency, or the current number of workers if it isn't set
+func (w *Worker) checkers() int {
+	if w.CheckConcurrency > 0 {
+		return w.CheckConcurrency
+	}
+	return w.workers()
+}
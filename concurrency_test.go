@@ -0,0 +1,202 @@
+package httptines
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Concurrency", func() {
+	var l *limiter
+
+	BeforeEach(func() {
+		l = &limiter{}
+	})
+
+	Describe("acquire()", func() {
+		When("no limit is set", func() {
+			It("never blocks", func() {
+				for range 100 {
+					l.acquire(false)
+				}
+				Expect(l.active).To(Equal(100))
+			})
+		})
+
+		It("blocks until a slot is released", func() {
+			l.limit = 1
+			l.acquire(false)
+
+			done := make(chan struct{})
+			go func() {
+				l.acquire(false)
+				close(done)
+			}()
+
+			Consistently(done, 100*time.Millisecond).ShouldNot(BeClosed())
+			l.release()
+			Eventually(done).Should(BeClosed())
+		})
+
+		It("keeps reserved slots for priority requests", func() {
+			l.limit = 2
+			l.share = 50
+			l.acquire(false)
+
+			done := make(chan struct{})
+			go func() {
+				l.acquire(false)
+				close(done)
+			}()
+
+			Consistently(done, 100*time.Millisecond).ShouldNot(BeClosed())
+			l.acquire(true)
+			Expect(l.active).To(Equal(2))
+		})
+	})
+
+	Describe("resize()", func() {
+		It("changes the limit", func() {
+			l.limit = 10
+			Expect(l.resize(5)).To(Equal(15))
+			Expect(l.resize(-20)).To(Equal(1))
+		})
+
+		When("no limit is set", func() {
+			It("ignores growth", func() {
+				Expect(l.resize(10)).To(Equal(0))
+			})
+
+			It("shrinks relative to in-flight requests", func() {
+				l.active = 30
+				Expect(l.resize(-10)).To(Equal(20))
+			})
+		})
+
+		It("wakes up waiting goroutines", func() {
+			l.limit = 1
+			l.acquire(false)
+
+			done := make(chan struct{})
+			go func() {
+				l.acquire(false)
+				close(done)
+			}()
+
+			Consistently(done, 100*time.Millisecond).ShouldNot(BeClosed())
+			l.resize(1)
+			Eventually(done).Should(BeClosed())
+		})
+	})
+
+	Describe("concurrencyHandler()", func() {
+		It("adjusts the limit", func() {
+			w := &Worker{ConcurrencyStep: 5}
+			w.limiter.limit = 10
+
+			r := httptest.NewRequest(http.MethodPost, "/api/concurrency/up", nil)
+			r.SetPathValue("direction", "up")
+			rec := httptest.NewRecorder()
+			concurrencyHandler(w)(rec, r)
+
+			Expect(rec.Body.String()).To(MatchJSON(`{"concurrency":15}`))
+		})
+
+		It("rejects unknown directions", func() {
+			r := httptest.NewRequest(http.MethodPost, "/api/concurrency/sideways", nil)
+			r.SetPathValue("direction", "sideways")
+			rec := httptest.NewRecorder()
+			concurrencyHandler(&Worker{})(rec, r)
+
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+
+	Describe("SetWorkers()", func() {
+		var w *Worker
+
+		BeforeEach(func() {
+			w = &Worker{Workers: 2}
+			w.slots.limit = 2
+		})
+
+		It("limits the number of active servers", func() {
+			Expect(w.slots.acquire()).To(BeTrue())
+			Expect(w.slots.acquire()).To(BeTrue())
+			Expect(w.slots.acquire()).To(BeFalse())
+
+			w.SetWorkers(3)
+			Expect(w.workers()).To(Equal(3))
+			Expect(w.slots.acquire()).To(BeTrue())
+		})
+
+		It("sheds slots over the new limit", func() {
+			w.slots.acquire()
+			w.slots.acquire()
+
+			w.SetWorkers(1)
+			Expect(w.slots.shed()).To(BeTrue())
+			Expect(w.slots.shed()).To(BeFalse())
+			Expect(w.slots.acquire()).To(BeFalse())
+		})
+
+		It("keeps at least one worker", func() {
+			w.SetWorkers(0)
+			Expect(w.workers()).To(Equal(1))
+		})
+
+		It("raises the in-flight requests of servers beyond the starting workers", func() {
+			w.slots.base = 2
+			Expect(w.slots.inflight(3)).To(Equal(3))
+
+			w.SetWorkers(4)
+			Expect(w.slots.inflight(3)).To(Equal(6))
+
+			w.SetWorkers(1)
+			Expect(w.slots.inflight(3)).To(Equal(3))
+		})
+
+		It("resizes the buffer of servers", func() {
+			var cancel context.CancelFunc
+			w.ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+
+			a := &Server{URL: &url.URL{Scheme: "http", Host: "1.1.1.1:80"}}
+			b := &Server{URL: &url.URL{Scheme: "http", Host: "2.2.2.2:80"}}
+			w.srvCh = make(chan *Server, 2)
+			w.srvCh <- a
+			w.srvCh <- b
+
+			w.SetWorkers(1)
+			servers, _ := w.serverQueue()
+			Expect(cap(servers)).To(Equal(1))
+			Expect(servers).To(Receive(Equal(a)))
+			Eventually(servers).Should(Receive(Equal(b)))
+
+			w.SetWorkers(3)
+			servers, _ = w.serverQueue()
+			Expect(cap(servers)).To(Equal(3))
+		})
+
+		It("wakes up servers waiting for the resized buffer", func() {
+			var cancel context.CancelFunc
+			w.ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+
+			w.srvCh = make(chan *Server)
+			s := &Server{URL: &url.URL{Scheme: "http", Host: "1.1.1.1:80"}}
+			done := make(chan bool)
+			go func() { done <- w.handOff(s) }()
+
+			Consistently(done).ShouldNot(Receive())
+			w.SetWorkers(1)
+			Eventually(done).Should(Receive(BeTrue()))
+			Expect(w.srvCh).To(Receive(Equal(s)))
+		})
+	})
+})
@@ -0,0 +1,238 @@
+package httptines
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// prewarmTargets is the number of queued targets inspected for hosts to pre-warm.
+const prewarmTargets = 100
+
+// ConnStat reports how many connections were reused instead of established.
+type ConnStat struct {
+	// New is the number of established connections
+	New int `json:"new"`
+	// Reused is the number of requests sent over an existing connection
+	Reused int `json:"reused"`
+	// Handshake is the average time (in milliseconds) to establish a connection,
+	// including the proxy CONNECT and the TLS handshake
+	Handshake int `json:"handshake"`
+	// Saved is the estimated time (in milliseconds) saved by reusing connections
+	Saved int `json:"saved"`
+}
+
+// connMeter measures connection reuse across all servers.
+type connMeter struct {
+	m         sync.Mutex
+	fresh     int
+	reused    int
+	handshake time.Duration
+}
+
+// observe records how a request got its connection.
+// Parameters:
+//   - reused: Whether an existing connection was used
+//   - d: Time taken to get the connection
+func (c *connMeter) observe(reused bool, d time.Duration) {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	if reused {
+		c.reused++
+		return
+	}
+	c.fresh++
+	c.handshake += d
+}
+
+// snapshot returns the connection statistics.
+// Returns:
+//   - ConnStat: Connection statistics
+func (c *connMeter) snapshot() ConnStat {
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	st := ConnStat{New: c.fresh, Reused: c.reused}
+	if c.fresh > 0 {
+		avg := c.handshake / time.Duration(c.fresh)
+		st.Handshake = int(avg.Milliseconds())
+		st.Saved = int((avg * time.Duration(c.reused)).Milliseconds())
+	}
+	return st
+}
+
+// connPool keeps the transports of a server, one per proxy URL and target host,
+// so connections are reused and TLS sessions are resumed. TLS sessions aren't shared
+// with other servers, so target servers can't link requests from different proxy IPs.
+type connPool struct {
+	m          sync.Mutex
+	size       int
+	cache      tls.ClientSessionCache
+	meter      *connMeter
+	transports map[string]*http.Transport
+}
+
+// transport returns the transport for requests to the target through the proxy,
+// creating it on first use.
+// Parameters:
+//   - proxy: Proxy URL including credentials
+//   - header: Headers sent on CONNECT requests
+//   - host: TLS server name override, empty if none
+//
+// Returns:
+//   - *http.Transport: Transport keeping up to size idle connections per host
+func (p *connPool) transport(proxy *url.URL, header http.Header, host string) *http.Transport {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	key := proxy.String() + " " + host
+	if t, ok := p.transports[key]; ok {
+		return t
+	}
+
+	t := newTransport(proxy, header, host)
+	t.MaxIdleConnsPerHost = p.size
+	t.IdleConnTimeout = 90 * time.Second
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.ClientSessionCache = p.cache
+
+	if p.transports == nil {
+		p.transports = map[string]*http.Transport{}
+	}
+	p.transports[key] = t
+	return t
+}
+
+// trace returns a client trace recording how the request got its connection.
+// Returns:
+//   - *httptrace.ClientTrace: Trace reporting to the meter
+func (p *connPool) trace() *httptrace.ClientTrace {
+	var start time.Time
+	return &httptrace.ClientTrace{
+		GetConn: func(string) { start = time.Now() },
+		GotConn: func(info httptrace.GotConnInfo) {
+			p.meter.observe(info.Reused, time.Since(start))
+		},
+	}
+}
+
+// close closes the idle connections of all transports.
+func (p *connPool) close() {
+	p.m.Lock()
+	defer p.m.Unlock()
+
+	for _, t := range p.transports {
+		t.CloseIdleConnections()
+	}
+	p.transports = nil
+}
+
+// newTransport creates a transport sending requests through the proxy.
+// Parameters:
+//   - proxy: Proxy URL including credentials
+//   - header: Headers sent on CONNECT requests
+//   - host: TLS server name override, empty if none
+//
+// Returns:
+//   - *http.Transport: Transport
+func newTransport(proxy *url.URL, header http.Header, host string) *http.Transport {
+	t := &http.Transport{
+		Proxy:              http.ProxyURL(proxy),
+		ProxyConnectHeader: header,
+		DisableCompression: true,
+	}
+	if host != "" {
+		t.TLSClientConfig = &tls.Config{ServerName: host}
+	}
+	return t
+}
+
+// connPool returns a new connection pool for a server, nil if pre-warming is disabled.
+// Returns:
+//   - *connPool: Connection pool with its own TLS session cache
+func (w *Worker) connPool() *connPool {
+	if w.Prewarm <= 0 {
+		return nil
+	}
+	return &connPool{size: w.Prewarm, cache: tls.NewLRUClientSessionCache(0), meter: &w.conns}
+}
+
+// prewarmHosts returns the distinct scheme://host origins at the head of the queue
+// that are permitted by AllowedHosts.
+// Returns:
+//   - []string: Origins of queued targets
+func (w *Worker) prewarmHosts() []string {
+	w.m.RLock()
+	defer w.m.RUnlock()
+
+	seen := map[string]bool{}
+	var origins []string
+	for _, t := range w.targets[:min(len(w.targets), prewarmTargets)] {
+		u, err := url.Parse(t)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		origin := u.Scheme + "://" + u.Host
+		if !seen[origin] && w.allowed(origin) {
+			seen[origin] = true
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// prewarm establishes Prewarm connections through the server to every queued host,
+// so the first requests don't pay for the proxy CONNECT and TLS handshake. The
+// requests count against HostRate and MaxConcurrency like target requests, and
+// connections a host has no token left for aren't established.
+// Parameters:
+//   - s: Server to pre-warm
+func (w *Worker) prewarm(s *Server) {
+	if s.conns == nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, origin := range w.prewarmHosts() {
+		u, _ := url.Parse(origin)
+		host := w.hostOverride(origin)
+		t := s.conns.transport(s.proxy(origin), s.header, host)
+		client := &http.Client{Transport: t, Timeout: s.requestTimeout()}
+
+		for range w.Prewarm {
+			if w.throttle(origin, time.Now()) > 0 {
+				break
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				w.limiter.acquire(false)
+				defer w.limiter.release()
+
+				req, err := http.NewRequestWithContext(s.context(), http.MethodHead, u.String()+"/", nil)
+				if err != nil {
+					return
+				}
+				req.Header.Set("User-Agent", w.scrapeAgent())
+				if host != "" {
+					req.Host = host
+				}
+				req = req.WithContext(httptrace.WithClientTrace(req.Context(), s.conns.trace()))
+
+				resp, err := client.Do(req)
+				if err == nil {
+					resp.Body.Close()
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
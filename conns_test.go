@@ -0,0 +1,111 @@
+package httptines
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Connections", func() {
+	Describe("connMeter", func() {
+		It("estimates the time saved by reused connections", func() {
+			var c connMeter
+			c.observe(false, 100*time.Millisecond)
+			c.observe(false, 300*time.Millisecond)
+			c.observe(true, time.Millisecond)
+			c.observe(true, time.Millisecond)
+			c.observe(true, time.Millisecond)
+
+			Expect(c.snapshot()).To(Equal(ConnStat{New: 2, Reused: 3, Handshake: 200, Saved: 600}))
+		})
+	})
+
+	Describe("connPool", func() {
+		var p *connPool
+
+		BeforeEach(func() {
+			p = (&Worker{Prewarm: 4}).connPool()
+		})
+
+		It("is disabled without Prewarm", func() {
+			Expect((&Worker{}).connPool()).To(BeNil())
+		})
+
+		It("keeps a transport per proxy and host", func() {
+			proxy := &url.URL{Scheme: "http", Host: "1.2.3.4:8080"}
+
+			t := p.transport(proxy, nil, "")
+			Expect(p.transport(proxy, nil, "")).To(BeIdenticalTo(t))
+			Expect(p.transport(proxy, nil, "example.com")).NotTo(BeIdenticalTo(t))
+			Expect(t.MaxIdleConnsPerHost).To(Equal(4))
+			Expect(t.TLSClientConfig.ClientSessionCache).NotTo(BeNil())
+		})
+
+		It("doesn't share TLS sessions between proxies", func() {
+			w := &Worker{Prewarm: 4}
+			a := w.connPool().transport(&url.URL{Scheme: "http", Host: "1.2.3.4:8080"}, nil, "")
+			b := w.connPool().transport(&url.URL{Scheme: "http", Host: "5.6.7.8:8080"}, nil, "")
+			Expect(a.TLSClientConfig.ClientSessionCache).NotTo(BeIdenticalTo(b.TLSClientConfig.ClientSessionCache))
+		})
+
+		It("drops the transports on close", func() {
+			p.transport(&url.URL{Scheme: "http", Host: "1.2.3.4:8080"}, nil, "")
+			p.close()
+			Expect(p.transports).To(BeEmpty())
+		})
+	})
+
+	Describe("prewarm()", func() {
+		var (
+			w      *Worker
+			proxy  *httptest.Server
+			target *httptest.Server
+			s      *Server
+		)
+
+		BeforeEach(func() {
+			target = mockHTTPServer("good")
+			var proxyURL *url.URL
+			proxy, proxyURL = mockProxyServer(0)
+
+			w = &Worker{Prewarm: 2, targets: []string{target.URL + "/a", target.URL + "/b"}}
+			s = &Server{URL: proxyURL, timeout: time.Second, conns: w.connPool()}
+			s.ctx, s.cancel = context.WithCancel(context.Background())
+		})
+
+		AfterEach(func() {
+			s.conns.close()
+			target.Close()
+			proxy.Close()
+		})
+
+		It("opens connections reused by the requests", func() {
+			w.prewarm(s)
+			Expect(w.conns.snapshot().New).To(Equal(2))
+
+			rep, err := request(context.Background(), target.URL+"/a", s, reqOpts{agent: "test"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(rep.body)).To(Equal("good"))
+
+			st := w.conns.snapshot()
+			Expect(st.New).To(Equal(2))
+			Expect(st.Reused).To(Equal(1))
+		})
+
+		It("skips hosts outside AllowedHosts", func() {
+			w.AllowedHosts = []string{"example.com"}
+			w.prewarm(s)
+			Expect(w.conns.snapshot().New).To(BeZero())
+		})
+
+		It("doesn't exceed the host rate", func() {
+			w.HostRate = 1
+			w.prewarm(s)
+			Expect(w.conns.snapshot().New).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,83 @@
+package httptines
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Worker states reported in the statistics.
+const (
+	StateRunning  = "running"
+	StatePaused   = "paused"
+	StateStopped  = "stopped"
+	StateFinished = "finished"
+)
+
+// Pause stops dequeuing new targets while letting in-flight requests finish.
+func (w *Worker) Pause() {
+	if atomic.CompareAndSwapUint32(&w.paused, 0, 1) {
+		w.setState(StatePaused)
+		w.wlog("worker paused")
+	}
+}
+
+// Resume continues dequeuing targets after Pause.
+func (w *Worker) Resume() {
+	if atomic.CompareAndSwapUint32(&w.paused, 1, 0) {
+		w.setState(StateRunning)
+		w.wlog("worker resumed")
+	}
+}
+
+// Stop shuts the worker down, cancelling in-flight requests. Run returns shortly after.
+func (w *Worker) Stop() {
+	w.setReason("stopped")
+	w.setState(StateStopped)
+	w.wlog("worker stopped")
+
+	if w.abort != nil {
+		w.abort()
+	}
+	w.stop()
+}
+
+// isPaused reports whether the worker is paused.
+// Returns:
+//   - bool: True if the worker is paused
+func (w *Worker) isPaused() bool {
+	return atomic.LoadUint32(&w.paused) == 1
+}
+
+// setState updates the state reported in the statistics.
+// Parameters:
+//   - state: New state
+func (w *Worker) setState(state string) {
+	if w.stat == nil {
+		return
+	}
+
+	w.stat.m.Lock()
+	if w.stat.State != StateStopped {
+		w.stat.State = state
+	}
+	w.stat.m.Unlock()
+}
+
+// state returns the current worker state.
+// Returns:
+//   - string: Worker state
+func (w *Worker) state() string {
+	w.stat.m.RLock()
+	defer w.stat.m.RUnlock()
+	return w.stat.State
+}
+
+// requestContext returns the context server contexts are derived from.
+// Returns:
+//   - context.Context: Context cancelled by Stop
+func (w *Worker) requestContext() context.Context {
+	if w.reqCtx != nil {
+		return w.reqCtx
+	}
+	return w.baseContext()
+}
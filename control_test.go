@@ -0,0 +1,92 @@
+package httptines
+
+import (
+	"context"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Control", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{
+			stat:  &Stat{State: StateRunning, Targets: 100, Servers: map[string]srvMap{}},
+			stsCh: make(chan srvMap),
+			timCh: make(chan time.Time),
+		}
+	})
+
+	Describe("Pause()", func() {
+		It("pauses the worker", func() {
+			w.Pause()
+			Expect(w.isPaused()).To(BeTrue())
+			Expect(w.stat.State).To(Equal(StatePaused))
+		})
+	})
+
+	Describe("Resume()", func() {
+		It("resumes the worker", func() {
+			w.Pause()
+			w.Resume()
+			Expect(w.isPaused()).To(BeFalse())
+			Expect(w.stat.State).To(Equal(StateRunning))
+		})
+	})
+
+	Describe("Stop()", func() {
+		It("stops the worker and cancels requests", func() {
+			w.ctx, w.cancel = context.WithCancel(context.Background())
+			w.reqCtx, w.abort = context.WithCancel(context.Background())
+
+			w.Stop()
+			Expect(w.stopped()).To(BeTrue())
+			Expect(w.requestContext().Err()).To(HaveOccurred())
+			Expect(w.stat.State).To(Equal(StateStopped))
+		})
+
+		It("keeps the stopped state", func() {
+			w.Stop()
+			w.Resume()
+			w.setState(StateFinished)
+			Expect(w.stat.State).To(Equal(StateStopped))
+		})
+	})
+
+	Describe("handleServer()", func() {
+		var (
+			proxy    *httptest.Server
+			proxyURL *url.URL
+			target   *httptest.Server
+		)
+
+		BeforeEach(func() {
+			proxy, proxyURL = mockProxyServer(0)
+			target = mockHTTPServer("good")
+		})
+
+		AfterEach(func() {
+			target.Close()
+			proxy.Close()
+		})
+
+		It("doesn't dequeue targets while paused", func() {
+			w.targets = []string{target.URL}
+			srv := &Server{URL: proxyURL, Capacity: 1}
+			srv.ctx, srv.cancel = context.WithCancel(context.Background())
+
+			w.Pause()
+			go w.updateStat()
+			go w.handleServer(srv, func(Result) {})
+
+			Consistently(func() int { return len(w.queueChanges(0).Removed) }, 300*time.Millisecond).Should(BeZero())
+
+			w.Resume()
+			Eventually(func() int { return len(w.queueChanges(0).Removed) }, 2*time.Second).Should(Equal(1))
+		})
+	})
+})
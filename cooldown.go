@@ -0,0 +1,59 @@
+package httptines
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// cooldown schedules the re-validation of a disabled server, so a proxy
+// disabled by a transient failure returns to rotation.
+// Parameters:
+//   - s: Disabled server
+func (w *Worker) cooldown(s *Server) {
+	if w.Cooldown <= 0 || s.tor != nil || s.slot > 0 {
+		return
+	}
+	go w.revalidate(s)
+}
+
+// revalidate checks the server again every Cooldown seconds, up to
+// CooldownAttempts times. A server passing the check is enabled and handed
+// over to be processed with its statistics preserved.
+// Parameters:
+//   - s: Disabled server
+func (w *Worker) revalidate(s *Server) {
+	for attempt := 1; attempt <= w.CooldownAttempts; attempt++ {
+		select {
+		case <-time.After(time.Duration(w.Cooldown) * time.Second):
+		case <-w.ctx.Done():
+			return
+		}
+		if w.stopped() {
+			return
+		}
+
+		s.m.Lock()
+		s.Capacity = 0
+		s.m.Unlock()
+		s.renew(w.requestContext())
+
+		if !w.checkServer(s) {
+			s.stop()
+			continue
+		}
+
+		s.m.Lock()
+		s.l5 = [5]bool{true, true, true, true, true}
+		s.l5i = 0
+		atomic.StoreUint32(&s.Disabled, 0)
+		s.m.Unlock()
+
+		w.liftBlacklist(s.name())
+		w.wlog(fmt.Sprintf("proxy %s passed re-validation (attempt %d)", s.name(), attempt))
+		w.enlist([]*Server{s})
+		return
+	}
+
+	w.wlog(fmt.Sprintf("proxy %s failed re-validation %d times", s.name(), w.CooldownAttempts))
+}
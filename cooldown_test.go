@@ -0,0 +1,105 @@
+package httptines
+
+import (
+	"context"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cooldown", func() {
+	var (
+		w      *Worker
+		s      *Server
+		target *httptest.Server
+		proxy  *httptest.Server
+		cancel context.CancelFunc
+	)
+
+	BeforeEach(func() {
+		target = mockHTTPServer("good")
+		p, u := mockProxyServer(0)
+		proxy = p
+
+		w = &Worker{
+			TestTarget:       target.URL,
+			Strategy:         "minimal",
+			Timeout:          1,
+			Cooldown:         1,
+			CooldownAttempts: 2,
+			srvCh:            make(chan *Server, 1),
+			stat:             &Stat{},
+		}
+		w.ctx, cancel = context.WithCancel(context.Background())
+
+		s = w.newServer(u)
+		s.Positive = 7
+		s.Negative = 5
+		s.l5 = [5]bool{}
+		s.disable()
+	})
+
+	AfterEach(func() {
+		cancel()
+		target.Close()
+		proxy.Close()
+	})
+
+	It("returns a server passing the check to rotation", func() {
+		w.BlacklistTTL = 1800
+		w.blacklist(s)
+
+		w.revalidate(s)
+
+		Expect(w.srvCh).To(Receive(Equal(s)))
+		Expect(s.Disabled).To(BeZero())
+		Expect(s.Capacity).To(Equal(1))
+		Expect(s.fiveFailInRow()).To(BeFalse())
+		Expect(s.context().Err()).NotTo(HaveOccurred())
+		Expect(s.Positive).To(Equal(7))
+		Expect(s.Negative).To(Equal(5))
+		Expect(w.banned(s.name())).To(BeFalse())
+	})
+
+	It("keeps a manual ban after re-validation", func() {
+		w.BlacklistTTL = 1800
+		w.blacklist(s)
+		w.Ban(s.name(), time.Hour)
+
+		w.revalidate(s)
+
+		Expect(w.srvCh).To(Receive(Equal(s)))
+		Expect(w.banned(s.name())).To(BeTrue())
+	})
+
+	It("drops a server failing every check", func() {
+		proxy.Close()
+
+		w.revalidate(s)
+
+		Expect(w.srvCh).NotTo(Receive())
+		Expect(s.Disabled).NotTo(BeZero())
+		Expect(s.Capacity).To(BeZero())
+	})
+
+	It("stops waiting when the worker stops", func() {
+		done := make(chan struct{})
+		go func() {
+			w.revalidate(s)
+			close(done)
+		}()
+
+		cancel()
+		Eventually(done, 500*time.Millisecond).Should(BeClosed())
+		Expect(w.srvCh).NotTo(Receive())
+	})
+
+	It("isn't scheduled without a cooldown", func() {
+		w.Cooldown = 0
+		w.cooldown(s)
+
+		Consistently(w.srvCh, 1500*time.Millisecond).ShouldNot(Receive())
+	})
+})
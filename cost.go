@@ -0,0 +1,101 @@
+package httptines
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// Rates defines the prices used to compute the cost report.
+type Rates struct {
+	// PerGB is the price of one gigabyte of traffic (sent and received)
+	PerGB float64
+	// PerThousandRequests is the price of one thousand requests
+	PerThousandRequests float64
+}
+
+// Usage represents the requests and traffic attributed to a target host.
+type Usage struct {
+	// Requests is the number of requests, including failed attempts
+	Requests int `json:"requests"`
+	// Bytes is the number of bytes sent and received
+	Bytes int64 `json:"bytes"`
+	// Cost is the price of the usage according to the configured Rates
+	Cost float64 `json:"cost"`
+}
+
+// CostReport represents the cost of a job broken down by target host.
+type CostReport struct {
+	// Job is the run label (Worker.Namespace)
+	Job string `json:"job"`
+	// Hosts contains the usage keyed by target host
+	Hosts map[string]Usage `json:"hosts"`
+	// Total is the usage of the whole job
+	Total Usage `json:"total"`
+}
+
+// usageMeter aggregates requests and traffic per target host.
+type usageMeter struct {
+	m     sync.Mutex
+	hosts map[string]*Usage
+}
+
+// add records a request to the target.
+// Parameters:
+//   - t: Target URL
+//   - bytes: Bytes sent and received
+func (u *usageMeter) add(t string, bytes int64) {
+	host := t
+	if p, err := url.Parse(t); err == nil {
+		host = p.Host
+	}
+
+	u.m.Lock()
+	defer u.m.Unlock()
+
+	if u.hosts == nil {
+		u.hosts = map[string]*Usage{}
+	}
+	if u.hosts[host] == nil {
+		u.hosts[host] = &Usage{}
+	}
+	u.hosts[host].Requests++
+	u.hosts[host].Bytes += bytes
+}
+
+// cost computes the price of the usage.
+// Parameters:
+//   - r: Rates
+//
+// Returns:
+//   - float64: Price of the usage
+func (u Usage) cost(r Rates) float64 {
+	return float64(u.Bytes)/(1<<30)*r.PerGB + float64(u.Requests)/1000*r.PerThousandRequests
+}
+
+// CostReport returns the requests, traffic and cost of the job per target host.
+// Returns:
+//   - CostReport: Cost report based on the configured Rates
+func (w *Worker) CostReport() CostReport {
+	w.usage.m.Lock()
+	defer w.usage.m.Unlock()
+
+	r := CostReport{Job: w.Namespace, Hosts: map[string]Usage{}}
+	for host, u := range w.usage.hosts {
+		hu := *u
+		hu.Cost = hu.cost(w.Rates)
+		r.Hosts[host] = hu
+
+		r.Total.Requests += hu.Requests
+		r.Total.Bytes += hu.Bytes
+	}
+	r.Total.Cost = r.Total.cost(w.Rates)
+
+	return r
+}
+
+// logCostReport writes the cost report totals to the log.
+func (w *Worker) logCostReport() {
+	r := w.CostReport()
+	w.wlog(fmt.Sprintf("cost report: %d requests, %.2f MB, cost %.4f", r.Total.Requests, float64(r.Total.Bytes)/(1<<20), r.Total.Cost))
+}
@@ -0,0 +1,38 @@
+package httptines
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cost", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{Namespace: "job-1", Rates: Rates{PerGB: 2, PerThousandRequests: 0.5}}
+	})
+
+	Describe("CostReport()", func() {
+		It("aggregates usage per host", func() {
+			w.usage.add("http://a.com/1", 1<<29)
+			w.usage.add("http://a.com/2", 1<<29)
+			w.usage.add("http://b.com/1", 0)
+
+			r := w.CostReport()
+			Expect(r.Job).To(Equal("job-1"))
+			Expect(r.Hosts["a.com"]).To(Equal(Usage{Requests: 2, Bytes: 1 << 30, Cost: 2.001}))
+			Expect(r.Hosts["b.com"]).To(Equal(Usage{Requests: 1, Bytes: 0, Cost: 0.0005}))
+			Expect(r.Total.Requests).To(Equal(3))
+			Expect(r.Total.Bytes).To(Equal(int64(1 << 30)))
+			Expect(r.Total.Cost).To(BeNumerically("~", 2.0015, 1e-9))
+		})
+
+		When("nothing was requested", func() {
+			It("returns an empty report", func() {
+				r := w.CostReport()
+				Expect(r.Hosts).To(BeEmpty())
+				Expect(r.Total).To(Equal(Usage{}))
+			})
+		})
+	})
+})
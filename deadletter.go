@@ -0,0 +1,45 @@
+package httptines
+
+import (
+	"slices"
+	"time"
+)
+
+// FailedTarget represents a target abandoned after exhausting its retries.
+type FailedTarget struct {
+	// URL is the abandoned target
+	URL string `json:"url"`
+	// Error is the error of the last attempt
+	Error string `json:"error"`
+	// Attempts is the number of attempts made
+	Attempts int `json:"attempts"`
+	// AttemptID identifies the last attempt
+	AttemptID string `json:"attemptId,omitempty"`
+	// FailedAt is the time the target was abandoned
+	FailedAt time.Time `json:"failedAt"`
+}
+
+// Failed returns the dead-letter list of targets abandoned after MaxRetries.
+// Returns:
+//   - []FailedTarget: Abandoned targets in the order they were abandoned
+func (w *Worker) Failed() []FailedTarget {
+	w.m.RLock()
+	defer w.m.RUnlock()
+	return slices.Clone(w.failed)
+}
+
+// bury moves a target to the dead-letter list.
+// Parameters:
+//   - u: Target URL
+//   - n: Number of attempts made
+func (w *Worker) bury(u string, n int) {
+	w.m.Lock()
+	w.failed = append(w.failed, FailedTarget{
+		URL:       u,
+		Error:     w.statuses[u].Error,
+		Attempts:  n,
+		AttemptID: w.statuses[u].AttemptID,
+		FailedAt:  time.Now(),
+	})
+	w.m.Unlock()
+}
@@ -0,0 +1,54 @@
+package httptines
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Dead letter", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{MaxRetries: 1, stat: &Stat{Targets: 1}}
+	})
+
+	fail := func(t string) {
+		w.attempt(t)
+		w.track(t, "A1", errors.New("unexpected status code: 404"))
+		w.retry(t)
+	}
+
+	Describe("Failed()", func() {
+		It("lists targets abandoned after the retry limit", func() {
+			fail("http://test1.com")
+			Expect(w.Failed()).To(BeEmpty())
+
+			fail("http://test1.com")
+			failed := w.Failed()
+			Expect(failed).To(HaveLen(1))
+			Expect(failed[0].URL).To(Equal("http://test1.com"))
+			Expect(failed[0].Error).To(Equal("unexpected status code: 404"))
+			Expect(failed[0].Attempts).To(Equal(2))
+			Expect(failed[0].AttemptID).To(Equal("A1"))
+			Expect(failed[0].FailedAt).NotTo(BeZero())
+			Expect(w.stat.allTargetsProcessed()).To(BeTrue())
+		})
+	})
+
+	Describe("failedHandler()", func() {
+		It("returns the dead-letter list", func() {
+			fail("http://test1.com")
+			fail("http://test1.com")
+
+			rec := httptest.NewRecorder()
+			failedHandler(w)(rec, httptest.NewRequest(http.MethodGet, "/api/failed", nil))
+
+			Expect(rec.Body.String()).To(ContainSubstring(`"url":"http://test1.com"`))
+			Expect(rec.Body.String()).To(ContainSubstring(`"attempts":2`))
+		})
+	})
+})
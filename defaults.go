@@ -0,0 +1,68 @@
+package httptines
+
+// setDefault sets the field to the default value if it is zero.
+// Parameters:
+//   - field: Pointer to the field
+//   - value: Default value
+func setDefault[T comparable](field *T, value T) {
+	var zero T
+	if *field == zero {
+		*field = value
+	}
+}
+
+// DefaultWorker returns a worker with the default configuration. Sources (or Proxies,
+// APISources or Tor) and TestTarget still have to be set before it is run.
+// Returns:
+//   - *Worker: Worker with default values
+func DefaultWorker() *Worker {
+	w := &Worker{}
+	w.Default()
+	return w
+}
+
+// Default sets the fields left at their zero value to their defaults, including those of Tor.
+// It is called by Run, New and Doctor.
+func (w *Worker) Default() {
+	setDefault(&w.Interval, 300)
+	setDefault(&w.RefreshSpacing, 60)
+	setDefault(&w.Port, 8080)
+	setDefault(&w.Workers, 100)
+	setDefault(&w.StatInterval, 2)
+	setDefault(&w.Rotation, rotationPull)
+	setDefault(&w.Strategy, "minimal")
+	setDefault(&w.MaxCapacity, 20)
+	setDefault(&w.Timeout, 10)
+	setDefault(&w.TimeoutFactor, 3)
+	setDefault(&w.MinTimeout, 1)
+	setDefault(&w.MaxTimeout, 60)
+	setDefault(&w.TargetOutage, "skip")
+	setDefault(&w.BareRedirect, "failure")
+	setDefault(&w.RevisitOrder, revisitFIFO)
+	setDefault(&w.ConcurrencyStep, 10)
+	setDefault(&w.ProgressInterval, 5)
+	setDefault(&w.MirrorBaseline, "proxy")
+	setDefault(&w.BackoffMax, 60000)
+	setDefault(&w.RetryBudgetWindow, 300)
+	setDefault(&w.RetryBudgetMin, 10)
+	setDefault(&w.StormQuarantine, 5)
+	setDefault(&w.BanTTL, 600)
+	setDefault(&w.BlacklistTTL, 1800)
+	setDefault(&w.CooldownAttempts, 3)
+	setDefault(&w.BanAfter, 3)
+	setDefault(&w.BanDecay, 24)
+	setDefault(&w.ProxyHistoryTTL, 720)
+	setDefault(&w.ClaimLease, 300)
+	setDefault(&w.AliveCacheTTL, 24)
+	setDefault(&w.CachingProxies, "exclude")
+	setDefault(&w.MaxRedirects, 10)
+	setDefault(&w.MaxBodySize, 64<<20)
+	setDefault(&w.MaxCompressionRatio, 100)
+	setDefault(&w.MinFreeSpace, 100)
+	setDefault(&w.OrderBuffer, 1000)
+	setDefault(&w.LanguageFilter, "skip")
+
+	if w.Tor != nil {
+		w.Tor.Default()
+	}
+}
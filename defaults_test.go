@@ -0,0 +1,109 @@
+package httptines
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// documentedDefaults returns the "Default:" lines of the struct's field comments.
+func documentedDefaults(file, name string) map[string]string {
+	f, err := parser.ParseFile(token.NewFileSet(), file, nil, parser.ParseComments)
+	Expect(err).NotTo(HaveOccurred())
+
+	defaults := map[string]string{}
+	ast.Inspect(f, func(n ast.Node) bool {
+		ts, ok := n.(*ast.TypeSpec)
+		if !ok || ts.Name.Name != name {
+			return true
+		}
+		for _, field := range ts.Type.(*ast.StructType).Fields.List {
+			if field.Doc == nil {
+				continue
+			}
+			for _, c := range field.Doc.List {
+				if v, ok := strings.CutPrefix(c.Text, "// Default: "); ok {
+					defaults[field.Names[0].Name] = strings.TrimSuffix(v, ".")
+				}
+			}
+		}
+		return false
+	})
+	return defaults
+}
+
+// formatDefault formats a field value the way it is documented.
+func formatDefault(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return fmt.Sprintf("%q", v.String())
+	case reflect.Slice:
+		var items []string
+		for i := range v.Len() {
+			items = append(items, formatDefault(v.Index(i)))
+		}
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+var _ = Describe("Defaults", func() {
+	Describe("DefaultWorker()", func() {
+		It("returns the default configuration", func() {
+			w := DefaultWorker()
+			Expect(w.Interval).To(Equal(300))
+			Expect(w.Strategy).To(Equal("minimal"))
+			Expect(w.MaxBodySize).To(Equal(64 << 20))
+			Expect(w.Sources).To(BeNil())
+		})
+	})
+
+	Describe("Default()", func() {
+		It("keeps the values that are set", func() {
+			w := &Worker{Timeout: 3, Strategy: "auto", BlacklistTTL: -1, Tor: &Tor{SOCKS: "127.0.0.1:9150"}}
+			w.Default()
+
+			Expect(w.Timeout).To(Equal(3))
+			Expect(w.Strategy).To(Equal("auto"))
+			Expect(w.BlacklistTTL).To(Equal(-1))
+			Expect(w.Port).To(Equal(8080))
+			Expect(*w.Tor).To(Equal(Tor{SOCKS: "127.0.0.1:9150", Control: "127.0.0.1:9051"}))
+		})
+
+		It("sets the benchmark defaults", func() {
+			cfg := BenchmarkConfig{Targets: 50}
+			cfg.Default()
+			Expect(cfg).To(Equal(BenchmarkConfig{Proxies: 10, ProxyCapacity: 4, Targets: 50, Latency: 50, Strategies: []string{"minimal", "auto"}}))
+		})
+	})
+
+	DescribeTable("documented defaults",
+		func(file, name string, v any) {
+			docs := documentedDefaults(file, name)
+			Expect(docs).NotTo(BeEmpty())
+
+			rv := reflect.ValueOf(v).Elem()
+			for i := range rv.NumField() {
+				f := rv.Type().Field(i)
+				if !f.IsExported() {
+					continue
+				}
+				doc, ok := docs[f.Name]
+				if rv.Field(i).IsZero() {
+					Expect(ok).To(BeFalse(), "%s.%s has no default", name, f.Name)
+					continue
+				}
+				Expect(doc).To(Equal(formatDefault(rv.Field(i))), "%s.%s", name, f.Name)
+			}
+		},
+		Entry("Worker", "worker.go", "Worker", DefaultWorker()),
+		Entry("Tor", "tor.go", "Tor", func() *Tor { t := &Tor{}; t.Default(); return t }()),
+		Entry("BenchmarkConfig", "benchmark.go", "BenchmarkConfig", func() *BenchmarkConfig { c := &BenchmarkConfig{}; c.Default(); return c }()),
+	)
+})
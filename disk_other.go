@@ -0,0 +1,8 @@
+//go:build !unix
+
+package httptines
+
+import "errors"
+
+// freeSpace isn't supported on platforms without statfs, the disk space check is skipped.
+func freeSpace(string) (uint64, error) { return 0, errors.ErrUnsupported }
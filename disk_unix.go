@@ -0,0 +1,20 @@
+//go:build unix
+
+package httptines
+
+import "syscall"
+
+// freeSpace returns the space available to unprivileged users on the file system of the directory.
+// Parameters:
+//   - dir: Directory on the file system
+//
+// Returns:
+//   - uint64: Available space in bytes
+//   - error: Any error that occurred while querying the file system
+func freeSpace(dir string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return st.Bavail * uint64(st.Bsize), nil
+}
@@ -0,0 +1,282 @@
+package httptines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Check represents the outcome of a single pre-flight check.
+type Check struct {
+	// Name describes what was checked
+	Name string `json:"name"`
+	// OK indicates whether the check passed
+	OK bool `json:"ok"`
+	// Message contains details of the outcome
+	Message string `json:"message"`
+}
+
+// DoctorReport represents the readiness of a worker to start a run.
+type DoctorReport struct {
+	Checks []Check `json:"checks"`
+}
+
+// Ready reports whether all checks passed.
+// Returns:
+//   - bool: True if the worker is ready to run
+func (r DoctorReport) Ready() bool {
+	for _, c := range r.Checks {
+		if !c.OK {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the report as a human readable list.
+// Returns:
+//   - string: Formatted report
+func (r DoctorReport) String() string {
+	var b strings.Builder
+	for _, c := range r.Checks {
+		status := "OK  "
+		if !c.OK {
+			status = "FAIL"
+		}
+		fmt.Fprintf(&b, "[%s] %s: %s\n", status, c.Name, c.Message)
+	}
+
+	if r.Ready() {
+		b.WriteString("ready\n")
+	} else {
+		b.WriteString("not ready\n")
+	}
+	return b.String()
+}
+
+// add appends a check to the report.
+// Parameters:
+//   - name: What was checked
+//   - err: Error describing the failure, nil if the check passed
+//   - ok: Message used when the check passed
+func (r *DoctorReport) add(name string, err error, ok string) {
+	if err != nil {
+		r.Checks = append(r.Checks, Check{Name: name, Message: err.Error()})
+		return
+	}
+	r.Checks = append(r.Checks, Check{Name: name, OK: true, Message: ok})
+}
+
+// Doctor runs pre-flight checks of the configuration, proxy sources, test target, port and
+// disk space for the files of Sink, and returns the readiness report. Printing it is left
+// to the caller. Default values are applied to the worker.
+// Parameters:
+//   - ctx: Context for the network checks
+//
+// Returns:
+//   - DoctorReport: Outcome of every check
+func (w *Worker) Doctor(ctx context.Context) DoctorReport {
+	w.Default()
+
+	var r DoctorReport
+
+	for _, err := range w.configErrors() {
+		r.add("config", err, "")
+	}
+	if len(r.Checks) == 0 {
+		r.add("config", nil, "valid")
+	}
+
+	timeout := time.Duration(w.Timeout) * time.Second
+
+	for _, links := range w.Sources {
+		for _, link := range links {
+			r.add("source "+link, reachable(ctx, link, timeout), "reachable")
+		}
+	}
+
+	for _, s := range w.APISources {
+		// The first page tells whether the source is reachable
+		s.MaxPages = 1
+		_, err := s.fetch(ctx, timeout, proxyMap{}, nil)
+		r.add("source "+s.URL, err, "reachable")
+	}
+
+	if w.TestTarget != "" {
+		r.add("test target "+w.TestTarget, reachable(ctx, w.TestTarget, timeout), "reachable")
+	}
+	for _, t := range w.TestTargets {
+		r.add("test target "+t, reachable(ctx, t, timeout), "reachable")
+	}
+
+	r.add("port "+strconv.Itoa(w.Port), portAvailable(w.Port), "available")
+
+	for _, path := range sinkPaths(w.Sink) {
+		free, err := diskSpace(filepath.Dir(path), w.MinFreeSpace)
+		r.add("disk space "+path, err, free)
+	}
+
+	return r
+}
+
+// configErrors checks the configuration for missing or invalid values.
+// Returns:
+//   - []error: Configuration problems
+func (w *Worker) configErrors() []error {
+	var errs []error
+
+	if err := w.Validate(); err != nil {
+		errs = append(errs, err)
+	}
+	if w.Strategy != "minimal" && w.Strategy != "auto" {
+		errs = append(errs, fmt.Errorf("unknown Strategy %q", w.Strategy))
+	}
+	if !slices.Contains(rotationStrategies, w.Rotation) {
+		errs = append(errs, fmt.Errorf("unknown Rotation %q", w.Rotation))
+	}
+	if w.RevisitOrder != revisitFIFO && w.RevisitOrder != revisitCost {
+		errs = append(errs, fmt.Errorf("unknown RevisitOrder %q", w.RevisitOrder))
+	}
+	if w.TargetOutage != "skip" && w.TargetOutage != "ignore" {
+		errs = append(errs, fmt.Errorf("unknown TargetOutage %q", w.TargetOutage))
+	}
+	if w.BareRedirect != "failure" && w.BareRedirect != "success" {
+		errs = append(errs, fmt.Errorf("unknown BareRedirect %q", w.BareRedirect))
+	}
+	if w.CachingProxies != "exclude" && w.CachingProxies != "tag" {
+		errs = append(errs, fmt.Errorf("unknown CachingProxies %q", w.CachingProxies))
+	}
+	if w.LanguageFilter != "skip" && w.LanguageFilter != "tag" {
+		errs = append(errs, fmt.Errorf("unknown LanguageFilter %q", w.LanguageFilter))
+	}
+	if w.MinAnonymity != "" && !slices.Contains(anonymityLevels, w.MinAnonymity) {
+		errs = append(errs, fmt.Errorf("unknown MinAnonymity %q", w.MinAnonymity))
+	}
+	if w.MinAnonymity != "" && w.AnonymityJudge == "" {
+		errs = append(errs, errors.New("MinAnonymity requires AnonymityJudge"))
+	}
+	for i, s := range w.APISources {
+		if s.URL == "" {
+			errs = append(errs, fmt.Errorf("APISources[%d] has no URL", i))
+		}
+	}
+	if n := len(w.TestTargets) + 1; w.TestQuorum > n {
+		errs = append(errs, fmt.Errorf("TestQuorum %d exceeds the number of test URLs %d", w.TestQuorum, n))
+	}
+	if w.TestContent != nil && w.TestContent.Pattern != "" {
+		if _, err := regexp.Compile(w.TestContent.Pattern); err != nil {
+			errs = append(errs, fmt.Errorf("invalid TestContent pattern %q", w.TestContent.Pattern))
+		}
+	}
+	for _, g := range w.Gateways {
+		if _, err := parseGateway(g); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, p := range w.ExcludeProxies {
+		if _, err := parseProxyRule(p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for _, rule := range w.Alerts {
+		if _, err := parseAlertRule(rule); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if st := w.parseStaticProxies(w.Proxies, proxyMap{}); st.Rejected > 0 {
+		errs = append(errs, fmt.Errorf("%d invalid entries in Proxies", st.Rejected))
+	}
+
+	return errs
+}
+
+// reachable checks that the URL responds with a non-error status when requested directly.
+// Parameters:
+//   - ctx: Context for the request
+//   - u: URL to check
+//   - timeout: Request timeout
+//
+// Returns:
+//   - error: Any error that occurred or an unexpected status
+func reachable(ctx context.Context, u string, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// sinkPaths returns the files the sink writes to, following the sinks of a FailoverSink.
+// Parameters:
+//   - s: Sink to inspect
+//
+// Returns:
+//   - []string: Paths of the files written by the sink
+func sinkPaths(s Sink) []string {
+	switch s := s.(type) {
+	case *JSONLSink:
+		return []string{s.Path}
+	case *FailoverSink:
+		return append(sinkPaths(s.Primary), sinkPaths(s.Fallback)...)
+	}
+	return nil
+}
+
+// diskSpace checks that the file system of the directory has at least the required megabytes available.
+// Parameters:
+//   - dir: Directory the files are written to
+//   - required: Required space in megabytes
+//
+// Returns:
+//   - string: Available space, or a note that it wasn't checked
+//   - error: Any error that occurred or the lack of space
+func diskSpace(dir string, required int) (string, error) {
+	free, err := freeSpace(dir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return "not checked on this platform", nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	mb := free >> 20
+	if mb < uint64(required) {
+		return "", fmt.Errorf("%d MB available, MinFreeSpace is %d MB", mb, required)
+	}
+	return fmt.Sprintf("%d MB available", mb), nil
+}
+
+// portAvailable checks that the web interface port can be bound.
+// Parameters:
+//   - port: Port number
+//
+// Returns:
+//   - error: Any error that occurred while binding
+func portAvailable(port int) error {
+	l, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return err
+	}
+	return l.Close()
+}
@@ -0,0 +1,115 @@
+package httptines
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Doctor", func() {
+	var (
+		w      *Worker
+		target *httptest.Server
+	)
+
+	BeforeEach(func() {
+		target = mockHTTPServer("ok")
+		w = &Worker{
+			Port:       freePort(),
+			Timeout:    1,
+			TestTarget: target.URL,
+			Sources:    proxySrc{"http": {target.URL}},
+		}
+	})
+
+	AfterEach(func() {
+		target.Close()
+	})
+
+	Describe("Doctor()", func() {
+		It("reports a ready worker", func() {
+			r := w.Doctor(context.Background())
+			Expect(r.Ready()).To(BeTrue())
+			Expect(r.Checks).To(HaveLen(4))
+		})
+
+		It("reports an unreachable source", func() {
+			w.Sources = proxySrc{"http": {"http://127.0.0.1:1/list.txt"}}
+
+			r := w.Doctor(context.Background())
+			Expect(r.Ready()).To(BeFalse())
+			Expect(r.String()).To(ContainSubstring("[FAIL] source http://127.0.0.1:1/list.txt"))
+		})
+
+		It("requests only the first page of an API source", func() {
+			pages := 0
+			api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				pages++
+				fmt.Fprintf(w, `{"data": [], "next": "?page=%d"}`, pages+1)
+			}))
+			defer api.Close()
+
+			w.APISources = []APISource{{URL: api.URL, Items: "data", Next: "next"}}
+			r := w.Doctor(context.Background())
+			Expect(r.Ready()).To(BeTrue())
+			Expect(pages).To(Equal(1))
+		})
+
+		It("checks the disk space of the sink files", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "results.jsonl")
+			w.Sink = &FailoverSink{Primary: &JSONLSink{Path: path}, Fallback: &JSONLSink{Path: path}}
+
+			r := w.Doctor(context.Background())
+			Expect(r.Ready()).To(BeTrue())
+			Expect(r.String()).To(ContainSubstring("[OK  ] disk space " + path))
+
+			w.MinFreeSpace = math.MaxInt
+			r = w.Doctor(context.Background())
+			Expect(r.Ready()).To(BeFalse())
+			Expect(r.String()).To(ContainSubstring("[FAIL] disk space " + path))
+		})
+
+		It("reports a busy port", func() {
+			l, _ := net.Listen("tcp", ":"+strconv.Itoa(w.Port))
+			defer l.Close()
+
+			r := w.Doctor(context.Background())
+			Expect(r.Ready()).To(BeFalse())
+		})
+	})
+
+	Describe("configErrors()", func() {
+		It("reports missing and invalid values", func() {
+			w = &Worker{Strategy: "fastest", Rotation: "pull", RevisitOrder: "fifo", TargetOutage: "skip", BareRedirect: "failure", CachingProxies: "tag", LanguageFilter: "skip", Alerts: []string{"latency > 1"}}
+			Expect(w.configErrors()).To(HaveLen(3))
+		})
+
+		It("reports an unknown anonymity level", func() {
+			w = &Worker{Strategy: "minimal", Rotation: "pull", RevisitOrder: "fifo", TargetOutage: "skip", BareRedirect: "failure", CachingProxies: "tag", LanguageFilter: "skip", Sources: proxySrc{"http": {"x"}}, TestTarget: "x", MinAnonymity: "secret", AnonymityJudge: "http://judge"}
+			Expect(w.configErrors()).To(ConsistOf(MatchError(`unknown MinAnonymity "secret"`)))
+		})
+
+		It("reports invalid excluded proxies", func() {
+			w = &Worker{Strategy: "minimal", Rotation: "pull", RevisitOrder: "fifo", TargetOutage: "skip", BareRedirect: "failure", CachingProxies: "tag", LanguageFilter: "skip", Sources: proxySrc{"http": {"x"}}, TestTarget: "x", ExcludeProxies: []string{"10.0.0.0/8", "10.0.0.0/99"}}
+			Expect(w.configErrors()).To(ConsistOf(MatchError(`invalid ExcludeProxies range "10.0.0.0/99"`)))
+		})
+
+		It("reports an unknown test target outage handling", func() {
+			w = &Worker{Strategy: "minimal", Rotation: "pull", RevisitOrder: "fifo", TargetOutage: "retry", BareRedirect: "failure", CachingProxies: "tag", LanguageFilter: "skip", Sources: proxySrc{"http": {"x"}}, TestTarget: "x"}
+			Expect(w.configErrors()).To(ConsistOf(MatchError(`unknown TargetOutage "retry"`)))
+		})
+
+		It("reports an unreachable test quorum", func() {
+			w = &Worker{Strategy: "minimal", Rotation: "pull", RevisitOrder: "fifo", TargetOutage: "skip", BareRedirect: "failure", CachingProxies: "tag", LanguageFilter: "skip", Sources: proxySrc{"http": {"x"}}, TestTarget: "x", TestTargets: []string{"y"}, TestQuorum: 3}
+			Expect(w.configErrors()).To(ConsistOf(MatchError("TestQuorum 3 exceeds the number of test URLs 2")))
+		})
+	})
+})
@@ -0,0 +1,493 @@
+package httptines
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log"
+	"maps"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Payload encodings offered to every client.
+const (
+	encodingJSON    = "json"
+	encodingMsgpack = "msgpack"
+)
+
+// PayloadEncoder encodes a WebSocket payload. It receives the payload decoded from
+// its JSON form: nil, bool, json.Number, string, []any or map[string]any.
+type PayloadEncoder func(v any) ([]byte, error)
+
+// payloadEncodings returns the names of the payload encodings offered to the worker's clients.
+// Returns:
+//   - []string: MessagePack and the PayloadEncoders
+func (w *Worker) payloadEncodings() []string {
+	return append([]string{encodingMsgpack}, slices.Collect(maps.Keys(w.PayloadEncoders))...)
+}
+
+// negotiateEncoding picks the first encoding requested by the client that is supported.
+// Parameters:
+//   - requested: Subprotocols requested by the client in order of preference
+//   - supported: Names of the supported encodings besides JSON
+//
+// Returns:
+//   - string: Negotiated encoding, empty if none is supported and JSON is used
+func negotiateEncoding(requested, supported []string) string {
+	for _, name := range requested {
+		if name == encodingJSON || slices.Contains(supported, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// newMessage encodes a payload once for all clients, in JSON, in MessagePack directly
+// from the typed payload, and with the custom encoders. It is called while the payload
+// is safe to read, so it can be sent later without holding any lock.
+// Parameters:
+//   - p: Payload
+//   - level: Message level, empty if the message isn't a log line
+//   - encoders: Custom payload encoders keyed by subprotocol name, overriding MessagePack
+//
+// Returns:
+//   - message: Encoded message
+func newMessage(p Payload, level string, encoders map[string]PayloadEncoder) message {
+	m := message{level: level, encoded: map[string][]byte{}}
+	data, err := json.Marshal(p)
+	if err != nil {
+		log.Printf("encoding %s payload: %v", p.Kind, err)
+		return m
+	}
+	m.data = data
+
+	if _, ok := encoders[encodingMsgpack]; !ok {
+		if m.encoded[encodingMsgpack], err = encodeMsgpack(p); err != nil {
+			log.Printf("encoding %s payload as %s: %v", p.Kind, encodingMsgpack, err)
+		}
+	}
+	if len(encoders) == 0 {
+		return m
+	}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v any
+	if err := d.Decode(&v); err != nil {
+		return m
+	}
+	for name, enc := range encoders {
+		if m.encoded[name], err = enc(v); err != nil {
+			log.Printf("encoding %s payload as %s: %v", p.Kind, name, err)
+		}
+	}
+	return m
+}
+
+// in returns the message in the given encoding.
+// Parameters:
+//   - encoding: Negotiated encoding, empty for JSON
+//
+// Returns:
+//   - []byte: Encoded message, nil if it isn't available in the encoding
+func (m message) in(encoding string) []byte {
+	if encoding == "" || encoding == encodingJSON {
+		return m.data
+	}
+	return m.encoded[encoding]
+}
+
+// Types encoded specially by encodeMsgpack.
+var (
+	marshalerType = reflect.TypeFor[json.Marshaler]()
+	numberType    = reflect.TypeFor[json.Number]()
+	timeType      = reflect.TypeFor[time.Time]()
+)
+
+// encodeMsgpack encodes a payload as MessagePack the way encoding/json would encode
+// it as JSON: struct fields are named by their json tags, byte slices are base64
+// encoded and times are RFC 3339 strings. Map keys and struct fields are sorted, so
+// a payload and its decoded JSON form produce equal output. Values implementing
+// json.Marshaler go through JSON.
+// Parameters:
+//   - v: Payload
+//
+// Returns:
+//   - []byte: MessagePack data
+//   - error: Error if the payload contains an unsupported type
+func encodeMsgpack(v any) ([]byte, error) {
+	return appendMsgpack(nil, reflect.ValueOf(v))
+}
+
+// appendMsgpack appends the MessagePack encoding of the value.
+// Parameters:
+//   - b: Buffer
+//   - v: Value
+//
+// Returns:
+//   - []byte: Extended buffer
+//   - error: Error if the value has an unsupported type
+func appendMsgpack(b []byte, v reflect.Value) ([]byte, error) {
+	for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) {
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		if v.Kind() == reflect.Pointer && v.Type().Implements(marshalerType) && !v.Type().Elem().Implements(marshalerType) {
+			break
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return append(b, 0xc0), nil
+	}
+
+	switch t := v.Type(); {
+	case t == numberType:
+		return appendMsgpackNumber(b, json.Number(v.String()))
+	case t == timeType:
+		return appendMsgpackString(b, v.Interface().(time.Time).Format(time.RFC3339Nano)), nil
+	case t.Implements(marshalerType):
+		return appendMsgpackJSON(b, v)
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		if v.Bool() {
+			return append(b, 0xc3), nil
+		}
+		return append(b, 0xc2), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return appendMsgpackInt(b, v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := v.Uint(); u > math.MaxInt64 {
+			return binary.BigEndian.AppendUint64(append(b, 0xcf), u), nil
+		}
+		return appendMsgpackInt(b, int64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return appendMsgpackFloat(b, v.Float()), nil
+	case reflect.String:
+		return appendMsgpackString(b, v.String()), nil
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return appendMsgpackString(b, base64.StdEncoding.EncodeToString(v.Bytes())), nil
+		}
+		return appendMsgpackArray(b, v)
+	case reflect.Array:
+		return appendMsgpackArray(b, v)
+	case reflect.Map:
+		if v.IsNil() {
+			return append(b, 0xc0), nil
+		}
+		return appendMsgpackMap(b, v)
+	case reflect.Struct:
+		return appendMsgpackStruct(b, v)
+	}
+	return nil, fmt.Errorf("unsupported payload type %s", v.Type())
+}
+
+// appendMsgpackNumber appends a JSON number, an integer if it has no fraction.
+// Parameters:
+//   - b: Buffer
+//   - n: Number
+//
+// Returns:
+//   - []byte: Extended buffer
+//   - error: Error if the number is malformed
+func appendMsgpackNumber(b []byte, n json.Number) ([]byte, error) {
+	if i, err := n.Int64(); err == nil {
+		return appendMsgpackInt(b, i), nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil, err
+	}
+	return appendMsgpackFloat(b, f), nil
+}
+
+// appendMsgpackFloat appends a float, in the integer form if it is integral, as
+// JSON doesn't tell integral floats from integers either.
+// Parameters:
+//   - b: Buffer
+//   - f: Float
+//
+// Returns:
+//   - []byte: Extended buffer
+func appendMsgpackFloat(b []byte, f float64) []byte {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return appendMsgpackInt(b, int64(f))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xcb), math.Float64bits(f))
+}
+
+// appendMsgpackString appends a string.
+// Parameters:
+//   - b: Buffer
+//   - s: String
+//
+// Returns:
+//   - []byte: Extended buffer
+func appendMsgpackString(b []byte, s string) []byte {
+	b = appendMsgpackHeader(b, len(s), 0xa0, 31, 0xd9, 0xda, 0xdb)
+	return append(b, s...)
+}
+
+// appendMsgpackArray appends the elements of a slice or array.
+// Parameters:
+//   - b: Buffer
+//   - v: Slice or array
+//
+// Returns:
+//   - []byte: Extended buffer
+//   - error: Error if an element has an unsupported type
+func appendMsgpackArray(b []byte, v reflect.Value) ([]byte, error) {
+	b = appendMsgpackHeader(b, v.Len(), 0x90, 15, 0, 0xdc, 0xdd)
+	for i := range v.Len() {
+		var err error
+		if b, err = appendMsgpack(b, v.Index(i)); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// appendMsgpackMap appends a map with string or integer keys, sorted.
+// Parameters:
+//   - b: Buffer
+//   - v: Map
+//
+// Returns:
+//   - []byte: Extended buffer
+//   - error: Error if a key or value has an unsupported type
+func appendMsgpackMap(b []byte, v reflect.Value) ([]byte, error) {
+	keys := make(map[string]reflect.Value, v.Len())
+	for it := v.MapRange(); it.Next(); {
+		k := it.Key()
+		switch k.Kind() {
+		case reflect.String:
+			keys[k.String()] = it.Value()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			keys[strconv.FormatInt(k.Int(), 10)] = it.Value()
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			keys[strconv.FormatUint(k.Uint(), 10)] = it.Value()
+		default:
+			return nil, fmt.Errorf("unsupported map key type %s", k.Type())
+		}
+	}
+
+	b = appendMsgpackHeader(b, len(keys), 0x80, 15, 0, 0xde, 0xdf)
+	for _, k := range slices.Sorted(maps.Keys(keys)) {
+		b = appendMsgpackString(b, k)
+		var err error
+		if b, err = appendMsgpack(b, keys[k]); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// msgpackField is a struct field encoded by encodeMsgpack.
+type msgpackField struct {
+	name      string // Name from the json tag, the field name if there is none
+	index     []int  // Index sequence of the field, through embedded structs
+	omitEmpty bool   // Whether the field is left out if empty
+}
+
+// msgpackFieldCache keeps the encoded fields of struct types.
+var msgpackFieldCache sync.Map
+
+// msgpackFields returns the fields of a struct type the way encoding/json encodes them:
+// exported, named by their json tags, and with the fields of embedded structs promoted
+// unless a shallower field has the same name.
+// Parameters:
+//   - t: Struct type
+//
+// Returns:
+//   - []msgpackField: Encoded fields sorted by name
+func msgpackFields(t reflect.Type) []msgpackField {
+	if f, ok := msgpackFieldCache.Load(t); ok {
+		return f.([]msgpackField)
+	}
+
+	type embedded struct {
+		t     reflect.Type
+		index []int
+	}
+
+	var fields []msgpackField
+	seen := map[string]bool{}
+	for next := []embedded{{t: t}}; len(next) > 0; {
+		current := next
+		next = nil
+		var found []msgpackField
+		for _, e := range current {
+			for i := range e.t.NumField() {
+				sf := e.t.Field(i)
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+				name, opts, _ := strings.Cut(tag, ",")
+				index := append(slices.Clone(e.index), i)
+
+				if ft := sf.Type; sf.Anonymous && name == "" {
+					if ft.Kind() == reflect.Pointer {
+						ft = ft.Elem()
+					}
+					if ft.Kind() == reflect.Struct {
+						next = append(next, embedded{ft, index})
+						continue
+					}
+				}
+				if !sf.IsExported() {
+					continue
+				}
+				if name == "" {
+					name = sf.Name
+				}
+				found = append(found, msgpackField{name, index, slices.Contains(strings.Split(opts, ","), "omitempty")})
+			}
+		}
+		for _, f := range found {
+			if !seen[f.name] {
+				seen[f.name] = true
+				fields = append(fields, f)
+			}
+		}
+	}
+
+	slices.SortFunc(fields, func(a, b msgpackField) int { return strings.Compare(a.name, b.name) })
+	msgpackFieldCache.Store(t, fields)
+	return fields
+}
+
+// appendMsgpackStruct appends a struct as a map of its fields.
+// Parameters:
+//   - b: Buffer
+//   - v: Struct
+//
+// Returns:
+//   - []byte: Extended buffer
+//   - error: Error if a field has an unsupported type
+func appendMsgpackStruct(b []byte, v reflect.Value) ([]byte, error) {
+	var (
+		names  []string
+		values []reflect.Value
+	)
+	for _, f := range msgpackFields(v.Type()) {
+		fv, err := v.FieldByIndexErr(f.index)
+		if err != nil || (f.omitEmpty && emptyValue(fv)) {
+			continue
+		}
+		names = append(names, f.name)
+		values = append(values, fv)
+	}
+
+	b = appendMsgpackHeader(b, len(names), 0x80, 15, 0, 0xde, 0xdf)
+	for i, name := range names {
+		b = appendMsgpackString(b, name)
+		var err error
+		if b, err = appendMsgpack(b, values[i]); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// emptyValue reports whether a field tagged omitempty is left out, as encoding/json does.
+// Parameters:
+//   - v: Field value
+//
+// Returns:
+//   - bool: True for false, zero numbers, nil pointers and interfaces, and empty strings, slices and maps
+func emptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// appendMsgpackJSON appends a value implementing json.Marshaler, decoded from its JSON form.
+// Parameters:
+//   - b: Buffer
+//   - v: Value
+//
+// Returns:
+//   - []byte: Extended buffer
+//   - error: Any error that occurred while marshaling the value
+func appendMsgpackJSON(b []byte, v reflect.Value) ([]byte, error) {
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var x any
+	if err := d.Decode(&x); err != nil {
+		return nil, err
+	}
+	return appendMsgpack(b, reflect.ValueOf(x))
+}
+
+// appendMsgpackInt appends an integer in its shortest MessagePack form.
+// Parameters:
+//   - b: Buffer
+//   - i: Integer
+//
+// Returns:
+//   - []byte: Extended buffer
+func appendMsgpackInt(b []byte, i int64) []byte {
+	switch {
+	case i >= 0 && i <= 127:
+		return append(b, byte(i))
+	case i >= -32 && i < 0:
+		return append(b, byte(0xe0|(i+32)))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		return append(b, 0xd0, byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		return binary.BigEndian.AppendUint16(append(b, 0xd1), uint16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		return binary.BigEndian.AppendUint32(append(b, 0xd2), uint32(i))
+	}
+	return binary.BigEndian.AppendUint64(append(b, 0xd3), uint64(i))
+}
+
+// appendMsgpackHeader appends the header of a string, array or map.
+// Parameters:
+//   - b: Buffer
+//   - n: Length
+//   - fix: Type byte of the fix format
+//   - fixMax: Largest length of the fix format
+//   - code8: Type byte of the 8-bit length format, zero if there is none
+//   - code16: Type byte of the 16-bit length format
+//   - code32: Type byte of the 32-bit length format
+//
+// Returns:
+//   - []byte: Extended buffer
+func appendMsgpackHeader(b []byte, n int, fix byte, fixMax int, code8, code16, code32 byte) []byte {
+	switch {
+	case n <= fixMax:
+		return append(b, fix|byte(n))
+	case code8 != 0 && n <= math.MaxUint8:
+		return append(b, code8, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, code16), uint16(n))
+	}
+	return binary.BigEndian.AppendUint32(append(b, code32), uint32(n))
+}
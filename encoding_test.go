@@ -0,0 +1,144 @@
+package httptines
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Encoding", func() {
+	Describe("encodeMsgpack()", func() {
+		It("encodes scalars in their shortest form", func() {
+			for _, c := range []struct {
+				v    any
+				want []byte
+			}{
+				{nil, []byte{0xc0}},
+				{true, []byte{0xc3}},
+				{false, []byte{0xc2}},
+				{json.Number("5"), []byte{0x05}},
+				{json.Number("-3"), []byte{0xfd}},
+				{json.Number("-100"), []byte{0xd0, 0x9c}},
+				{json.Number("300"), []byte{0xd1, 0x01, 0x2c}},
+				{json.Number("70000"), []byte{0xd2, 0x00, 0x01, 0x11, 0x70}},
+				{json.Number("1.5"), []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}},
+				{"ok", []byte{0xa2, 'o', 'k'}},
+			} {
+				Expect(encodeMsgpack(c.v)).To(Equal(c.want), "%v", c.v)
+			}
+		})
+
+		It("encodes arrays and maps with sorted keys", func() {
+			v := map[string]any{"b": []any{json.Number("1")}, "a": nil}
+			Expect(encodeMsgpack(v)).To(Equal([]byte{0x82, 0xa1, 'a', 0xc0, 0xa1, 'b', 0x91, 0x01}))
+		})
+
+		It("uses longer headers for long strings", func() {
+			data, err := encodeMsgpack(strings.Repeat("x", 40))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(data[:2]).To(Equal([]byte{0xd9, 40}))
+		})
+
+		It("encodes typed values like their decoded JSON form", func() {
+			at := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+			s := &Stat{
+				Namespace: "shop",
+				Targets:   3,
+				Servers:   map[string]srvMap{"http://1.1.1.1:80": {"url": "http://1.1.1.1:80", "efficiency": 97.5, "disabled": uint32(0), "tests": map[string]bool{"a": true}}},
+				Bans:      map[string]time.Time{"http://2.2.2.2:80": at},
+				Sources:   map[string]SourceStat{"src": {Accepted: 2}},
+				processed: 1,
+			}
+			for _, v := range []any{
+				Payload{"stat", s.view()},
+				Payload{"histogram", histogram([]int{120, 900})},
+				Payload{"alert", Alert{Rule: "rpm < 1", Value: 0.5, At: at}},
+				Payload{"log", logLine{Level: levelInfo, Message: "hi"}},
+				[]byte("raw"),
+			} {
+				data, err := json.Marshal(v)
+				Expect(err).NotTo(HaveOccurred())
+				d := json.NewDecoder(bytes.NewReader(data))
+				d.UseNumber()
+				var decoded any
+				Expect(d.Decode(&decoded)).To(Succeed())
+
+				want, err := encodeMsgpack(decoded)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(encodeMsgpack(v)).To(Equal(want), "%s", data)
+			}
+		})
+
+		It("rejects unsupported types", func() {
+			_, err := encodeMsgpack(make(chan int))
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Describe("negotiateEncoding()", func() {
+		It("picks the first supported encoding", func() {
+			supported := (&Worker{}).payloadEncodings()
+			Expect(negotiateEncoding([]string{"cbor", "msgpack", "json"}, supported)).To(Equal("msgpack"))
+			Expect(negotiateEncoding([]string{"json", "msgpack"}, supported)).To(Equal("json"))
+			Expect(negotiateEncoding([]string{"cbor"}, supported)).To(BeEmpty())
+			Expect(negotiateEncoding(nil, supported)).To(BeEmpty())
+		})
+
+		It("offers the worker's encoders to its clients only", func() {
+			w := &Worker{PayloadEncoders: map[string]PayloadEncoder{"test": nil}}
+			Expect(negotiateEncoding([]string{"test"}, w.payloadEncodings())).To(Equal("test"))
+			Expect(negotiateEncoding([]string{"test"}, (&Worker{}).payloadEncodings())).To(BeEmpty())
+		})
+	})
+
+	Describe("newMessage()", func() {
+		It("encodes the payload in every encoding", func() {
+			p := Payload{"log", logLine{Level: levelInfo, Message: "hi"}}
+			data, _ := json.Marshal(p)
+			encoders := map[string]PayloadEncoder{"test": func(v any) ([]byte, error) {
+				return []byte(v.(map[string]any)["kind"].(string)), nil
+			}}
+
+			msg := newMessage(p, levelInfo, encoders)
+			Expect(msg.in("")).To(Equal(data))
+			Expect(msg.in("json")).To(Equal(data))
+			Expect(msg.in("test")).To(Equal([]byte("log")))
+			Expect(msg.in("cbor")).To(BeNil())
+
+			want, _ := encodeMsgpack(map[string]any{
+				"kind": "log",
+				"body": map[string]any{"level": "info", "message": "hi"},
+			})
+			Expect(msg.in("msgpack")).To(Equal(want))
+		})
+	})
+
+	Describe("wsHandler()", func() {
+		It("negotiates msgpack with the client", func() {
+			srv := httptest.NewServer(wsHandler(&Worker{}))
+			defer srv.Close()
+
+			d := websocket.Dialer{Subprotocols: []string{"msgpack", "json"}}
+			conn, _, err := d.Dial("ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+			Expect(err).NotTo(HaveOccurred())
+			defer conn.Close()
+			Expect(conn.Subprotocol()).To(Equal("msgpack"))
+
+			wsm.Lock()
+			defer wsm.Unlock()
+			var encodings []string
+			for sc, c := range clients {
+				encodings = append(encodings, c.encoding)
+				sc.Close()
+				delete(clients, sc)
+			}
+			Expect(encodings).To(ContainElement("msgpack"))
+		})
+	})
+})
@@ -0,0 +1,57 @@
+// Command demo runs an example scenario against a local demo environment, or
+// serves the environment for experiments.
+//
+//	go run ./examples/cmd/demo basic
+//	go run ./examples/cmd/demo serve
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"maps"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/grishkovelli/httptines/examples"
+)
+
+func main() {
+	proxies := flag.Int("proxies", 3, "number of demo proxies")
+	latency := flag.Duration("latency", 50*time.Millisecond, "response time of the demo pages")
+	port := flag.Int("port", 8080, "dashboard port")
+	flag.Usage = func() {
+		names := slices.Sorted(maps.Keys(examples.Scenarios))
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: demo [flags] %s|serve\n", strings.Join(names, "|"))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	d := examples.NewDemo(*proxies, *latency)
+	defer d.Close()
+	d.Port = *port
+
+	name := flag.Arg(0)
+	if name == "serve" {
+		fmt.Printf("target:  %s\nsources: %s\nproxies: %s\n",
+			d.Target.URL, d.Sources.URL, strings.Join(d.ProxyURLs(), " "))
+		<-ctx.Done()
+		return
+	}
+
+	scenario, ok := examples.Scenarios[name]
+	if !ok {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := scenario(ctx, d, os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
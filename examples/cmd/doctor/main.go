@@ -0,0 +1,59 @@
+// Command doctor runs the pre-flight checks of a worker and prints the readiness report.
+// It exits with status 1 if the worker isn't ready to run.
+//
+//	go run ./examples/cmd/doctor -source http=https://example.com/proxies.txt -test https://example.com -sink results.jsonl
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/grishkovelli/httptines"
+)
+
+// sources collects repeated -source flags of the form schema=url.
+type sources map[string][]string
+
+func (s sources) String() string { return fmt.Sprint(map[string][]string(s)) }
+
+func (s sources) Set(v string) error {
+	schema, link, ok := strings.Cut(v, "=")
+	if !ok {
+		return fmt.Errorf("expected schema=url, got %q", v)
+	}
+	s[schema] = append(s[schema], link)
+	return nil
+}
+
+func main() {
+	src := sources{}
+	flag.Var(src, "source", "proxy source as schema=url, may be repeated")
+	test := flag.String("test", "", "test target URL")
+	port := flag.Int("port", 8080, "web interface port")
+	sink := flag.String("sink", "", "JSONL file results are written to")
+	minFree := flag.Int("min-free", 100, "disk space (in megabytes) required for the sink")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	w := &httptines.Worker{
+		Sources:      map[string][]string(src),
+		TestTarget:   *test,
+		Port:         *port,
+		MinFreeSpace: *minFree,
+	}
+	if *sink != "" {
+		w.Sink = &httptines.JSONLSink{Path: *sink}
+	}
+
+	r := w.Doctor(ctx)
+	fmt.Print(r.String())
+	if !r.Ready() {
+		os.Exit(1)
+	}
+}
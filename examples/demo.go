@@ -0,0 +1,191 @@
+// Package examples contains runnable scenarios that double as integration tests.
+// They run against Demo, a local environment of a target site, forwarding proxies
+// and a proxy list source, so no network access is needed.
+package examples
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// catalogPages is the number of catalog pages of the target
+	catalogPages = 3
+	// catalogItems is the number of items linked from a catalog page
+	catalogItems = 5
+)
+
+// Demo is a local environment of a target site, proxies and a proxy list source.
+type Demo struct {
+	// Target serves the pages to scrape at /items/{id}, catalog pages linking to
+	// them at /catalog/{page} and "ok" at /
+	Target *httptest.Server
+	// Proxies forward requests to the target
+	Proxies []*httptest.Server
+	// SOCKS are SOCKS5 proxies tunneling connections to the target
+	SOCKS []net.Listener
+	// Sources lists the proxies, one host:port per line
+	Sources *httptest.Server
+	// Port is the port of the worker's dashboard
+	Port int
+	// Logger receives the worker's log lines, stdout if nil
+	Logger io.Writer
+}
+
+// NewDemo starts a demo environment.
+// Parameters:
+//   - proxies: Number of proxies to start
+//   - latency: Response time of the target pages
+//
+// Returns:
+//   - *Demo: Running environment, to be closed with Close
+func NewDemo(proxies int, latency time.Duration) *Demo {
+	d := &Demo{Port: 8080, Target: newTarget(latency)}
+
+	hosts := make([]string, proxies)
+	for i := range proxies {
+		p := newProxy(fmt.Sprintf("demo-proxy-%d", i+1))
+		d.Proxies = append(d.Proxies, p)
+		hosts[i] = strings.TrimPrefix(p.URL, "http://")
+		d.SOCKS = append(d.SOCKS, newSOCKSProxy())
+	}
+
+	list := strings.Join(hosts, "\n")
+	d.Sources = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, list)
+	}))
+
+	return d
+}
+
+// Pages returns the URLs of the first n target pages.
+// Parameters:
+//   - n: Number of pages
+//
+// Returns:
+//   - []string: Page URLs
+func (d *Demo) Pages(n int) []string {
+	pages := make([]string, n)
+	for i := range n {
+		pages[i] = fmt.Sprintf("%s/items/%d", d.Target.URL, i+1)
+	}
+	return pages
+}
+
+// ProxyURLs returns the URLs of the proxies.
+// Returns:
+//   - []string: Proxy URLs
+func (d *Demo) ProxyURLs() []string {
+	urls := make([]string, len(d.Proxies))
+	for i, p := range d.Proxies {
+		urls[i] = p.URL
+	}
+	return urls
+}
+
+// SOCKSURLs returns the URLs of the SOCKS5 proxies.
+// Returns:
+//   - []string: Proxy URLs
+func (d *Demo) SOCKSURLs() []string {
+	urls := make([]string, len(d.SOCKS))
+	for i, l := range d.SOCKS {
+		urls[i] = "socks5://" + l.Addr().String()
+	}
+	return urls
+}
+
+// Close stops the servers of the environment.
+func (d *Demo) Close() {
+	d.Sources.Close()
+	for _, p := range d.Proxies {
+		p.Close()
+	}
+	for _, l := range d.SOCKS {
+		l.Close()
+	}
+	d.Target.Close()
+}
+
+// newTarget starts the target site.
+// Parameters:
+//   - latency: Response time of the pages
+//
+// Returns:
+//   - *httptest.Server: Target server
+func newTarget(latency time.Duration) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, _ *http.Request) {
+		io.WriteString(w, "ok")
+	})
+	mux.HandleFunc("GET /items/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		time.Sleep(latency)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"id":    id,
+			"name":  fmt.Sprintf("Item %d", id),
+			"price": float64(id*100+99) / 100,
+			"via":   r.Header.Get("Via"),
+		})
+	})
+	mux.HandleFunc("GET /catalog/{page}", func(w http.ResponseWriter, r *http.Request) {
+		page, err := strconv.Atoi(r.PathValue("page"))
+		if err != nil || page < 1 || page > catalogPages {
+			http.NotFound(w, r)
+			return
+		}
+
+		time.Sleep(latency)
+		w.Header().Set("Content-Type", "text/html")
+		for i := range catalogItems {
+			fmt.Fprintf(w, "<a href=\"http://%s/items/%d\">Item</a>\n", r.Host, (page-1)*catalogItems+i+1)
+		}
+		if page < catalogPages {
+			fmt.Fprintf(w, "<a href=\"http://%s/catalog/%d\">Next</a>\n", r.Host, page+1)
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+// newProxy starts a forwarding HTTP proxy that adds a Via header with its name.
+// Parameters:
+//   - name: Proxy name
+//
+// Returns:
+//   - *httptest.Server: Proxy server
+func newProxy(name string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req, err := http.NewRequestWithContext(r.Context(), r.Method, r.URL.String(), r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Header = r.Header.Clone()
+		req.Header.Set("Via", name)
+
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+}
@@ -0,0 +1,100 @@
+package examples
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func TestExamples(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "examples")
+}
+
+var _ = Describe("Scenarios", func() {
+	var (
+		d   *Demo
+		out strings.Builder
+	)
+
+	BeforeEach(func() {
+		d = NewDemo(3, 10*time.Millisecond)
+		d.Port = freePort()
+		d.Logger = io.Discard
+		out.Reset()
+	})
+
+	AfterEach(func() {
+		d.Close()
+	})
+
+	It("scrapes the pages through the listed proxies", func() {
+		Expect(Basic(context.Background(), d, &out)).To(Succeed())
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(lines).To(HaveLen(10))
+		for _, l := range lines {
+			Expect(l).To(MatchRegexp(`/items/\d+ 200 \{"id":\d+,.*"via":"demo-proxy-\d"\}$`))
+		}
+	})
+
+	It("follows the catalog links", func() {
+		Expect(Crawl(context.Background(), d, &out)).To(Succeed())
+
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(lines).To(HaveLen(catalogPages + catalogPages*catalogItems))
+		Expect(out.String()).To(ContainSubstring("/catalog/3 200"))
+		Expect(out.String()).To(ContainSubstring("/items/15 200"))
+	})
+
+	It("processes the pages through the proxy pool", func() {
+		Expect(Pool(context.Background(), d, &out)).To(Succeed())
+		Expect(countedBy(out.String(), d.ProxyURLs())).To(Equal(50))
+	})
+
+	It("processes the pages through the SOCKS5 proxies", func() {
+		Expect(SOCKSPool(context.Background(), d, &out)).To(Succeed())
+		Expect(countedBy(out.String(), d.SOCKSURLs())).To(Equal(20))
+	})
+
+	It("resumes a stopped run from the checkpoint", func() {
+		Expect(Resume(context.Background(), d, &out)).To(Succeed())
+
+		Expect(out.String()).To(MatchRegexp(`resuming \d+ targets`))
+		seen := map[string]bool{}
+		for l := range strings.Lines(out.String()) {
+			if strings.HasSuffix(l, " 200\n") {
+				seen[strings.Fields(l)[0]] = true
+			}
+		}
+		Expect(seen).To(HaveLen(20))
+	})
+})
+
+// countedBy sums the "proxy count" lines of a scenario output, expecting known proxies.
+func countedBy(out string, proxies []string) int {
+	total := 0
+	for l := range strings.Lines(out) {
+		fields := strings.Fields(l)
+		Expect(fields).To(HaveLen(2))
+		Expect(proxies).To(ContainElement(fields[0]))
+
+		n, err := strconv.Atoi(fields[1])
+		Expect(err).NotTo(HaveOccurred())
+		total += n
+	}
+	return total
+}
+
+func freePort() int {
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port
+}
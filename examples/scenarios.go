@@ -0,0 +1,229 @@
+package examples
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/grishkovelli/httptines"
+)
+
+// Scenario runs an example against a demo environment and writes its output.
+type Scenario func(ctx context.Context, d *Demo, out io.Writer) error
+
+// Scenarios contains the examples by name.
+var Scenarios = map[string]Scenario{
+	"basic":  Basic,
+	"crawl":  Crawl,
+	"pool":   Pool,
+	"resume": Resume,
+	"socks":  SOCKSPool,
+}
+
+// links matches the link targets of an HTML page.
+var links = regexp.MustCompile(`href="([^"]+)"`)
+
+// Basic scrapes ten pages through the proxies listed by the demo source.
+// Parameters:
+//   - ctx: Context controlling the run
+//   - d: Demo environment
+//   - out: Writer receiving one line per result
+//
+// Returns:
+//   - error: Any error that occurred
+func Basic(ctx context.Context, d *Demo, out io.Writer) error {
+	w, err := d.worker(httptines.WithSources(map[string][]string{"http": {d.Sources.URL}}))
+	if err != nil {
+		return err
+	}
+
+	var m sync.Mutex
+	return w.RunResults(ctx, d.Pages(10), func(r httptines.Result) {
+		m.Lock()
+		defer m.Unlock()
+		fmt.Fprintf(out, "%s %d %s\n", r.URL, r.Status, bytes.TrimSpace(r.Body))
+	})
+}
+
+// Crawl starts at the first catalog page and follows its links to the items
+// and the next catalog pages, enqueueing them with Worker.Add.
+// Parameters:
+//   - ctx: Context controlling the run
+//   - d: Demo environment
+//   - out: Writer receiving one line per result
+//
+// Returns:
+//   - error: Any error that occurred
+func Crawl(ctx context.Context, d *Demo, out io.Writer) error {
+	w, err := d.worker(httptines.WithProxies(d.ProxyURLs()...))
+	if err != nil {
+		return err
+	}
+
+	var m sync.Mutex
+	return w.RunResults(ctx, []string{d.Target.URL + "/catalog/1"}, func(r httptines.Result) {
+		for _, l := range links.FindAllSubmatch(r.Body, -1) {
+			w.Add(string(l[1]))
+		}
+
+		m.Lock()
+		defer m.Unlock()
+		fmt.Fprintf(out, "%s %d\n", r.URL, r.Status)
+	})
+}
+
+// Pool scrapes fifty pages through a static list of proxies in auto mode and
+// reports how many pages each proxy processed.
+// Parameters:
+//   - ctx: Context controlling the run
+//   - d: Demo environment
+//   - out: Writer receiving the number of results per proxy
+//
+// Returns:
+//   - error: Any error that occurred
+func Pool(ctx context.Context, d *Demo, out io.Writer) error {
+	w, err := d.worker(
+		httptines.WithProxies(d.ProxyURLs()...),
+		httptines.WithStrategy("auto"),
+	)
+	if err != nil {
+		return err
+	}
+	return countByProxy(ctx, w, d.Pages(50), out)
+}
+
+// SOCKSPool scrapes twenty pages through the SOCKS5 proxies of the demo and
+// reports how many pages each proxy processed.
+// Parameters:
+//   - ctx: Context controlling the run
+//   - d: Demo environment
+//   - out: Writer receiving the number of results per proxy
+//
+// Returns:
+//   - error: Any error that occurred
+func SOCKSPool(ctx context.Context, d *Demo, out io.Writer) error {
+	w, err := d.worker(httptines.WithProxies(d.SOCKSURLs()...))
+	if err != nil {
+		return err
+	}
+	return countByProxy(ctx, w, d.Pages(20), out)
+}
+
+// countByProxy processes the targets and writes the number of results per proxy.
+// Parameters:
+//   - ctx: Context controlling the run
+//   - w: Worker processing the targets
+//   - targets: Target URLs
+//   - out: Writer receiving one "proxy count" line per proxy
+//
+// Returns:
+//   - error: Any error that occurred
+func countByProxy(ctx context.Context, w *httptines.Worker, targets []string, out io.Writer) error {
+	results, err := w.Collect(ctx, targets)
+	if err != nil {
+		return err
+	}
+
+	counts := map[string]int{}
+	for _, r := range results {
+		counts[r.Proxy]++
+	}
+	for _, p := range slices.Sorted(maps.Keys(counts)) {
+		fmt.Fprintf(out, "%s %d\n", p, counts[p])
+	}
+	return nil
+}
+
+// Resume stops a run after three results, writing the unfinished targets to
+// the checkpoint file, and processes them in a second run.
+// Parameters:
+//   - ctx: Context controlling the runs
+//   - d: Demo environment
+//   - out: Writer receiving one line per result and the resumed targets count
+//
+// Returns:
+//   - error: Any error that occurred
+func Resume(ctx context.Context, d *Demo, out io.Writer) error {
+	f, err := os.CreateTemp("", "httptines-checkpoint-*.txt")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+
+	var (
+		m    sync.Mutex
+		done int
+	)
+	report := func(r httptines.Result) {
+		m.Lock()
+		defer m.Unlock()
+		done++
+		fmt.Fprintf(out, "%s %d\n", r.URL, r.Status)
+	}
+
+	w, err := d.worker(httptines.WithSources(map[string][]string{"http": {d.Sources.URL}}))
+	if err != nil {
+		return err
+	}
+	w.Checkpoint = f.Name()
+
+	first, stop := context.WithCancel(ctx)
+	defer stop()
+	err = w.RunResults(first, d.Pages(20), func(r httptines.Result) {
+		report(r)
+		m.Lock()
+		defer m.Unlock()
+		if done == 3 {
+			stop()
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(f.Name())
+	if err != nil {
+		return err
+	}
+	targets := strings.Fields(string(data))
+	fmt.Fprintf(out, "resuming %d targets\n", len(targets))
+
+	w, err = d.worker(httptines.WithSources(map[string][]string{"http": {d.Sources.URL}}))
+	if err != nil {
+		return err
+	}
+	return w.RunResults(ctx, targets, report)
+}
+
+// worker creates a worker for the demo environment.
+// Parameters:
+//   - opts: Options applied after the demo defaults
+//
+// Returns:
+//   - *httptines.Worker: Configured worker
+//   - error: Configuration errors
+func (d *Demo) worker(opts ...httptines.Option) (*httptines.Worker, error) {
+	defaults := []httptines.Option{
+		httptines.WithTestTarget(d.Target.URL),
+		httptines.WithPort(d.Port),
+		httptines.WithTimeout(2),
+	}
+	if d.Logger != nil {
+		defaults = append(defaults, httptines.WithLogger(d.Logger))
+	}
+
+	w, err := httptines.New(append(defaults, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	w.StatInterval = 1
+	return w, nil
+}
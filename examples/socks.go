@@ -0,0 +1,122 @@
+package examples
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+)
+
+// newSOCKSProxy starts a SOCKS5 proxy without authentication that only
+// supports CONNECT, enough to tunnel the worker's requests to the target.
+// Returns:
+//   - net.Listener: Listener of the proxy, to be closed with Close
+func newSOCKSProxy() net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic(err)
+	}
+
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS(c)
+		}
+	}()
+	return l
+}
+
+// serveSOCKS handles a SOCKS5 connection and relays it to the requested address.
+// Parameters:
+//   - c: Client connection
+func serveSOCKS(c net.Conn) {
+	defer c.Close()
+
+	addr, err := socksHandshake(c)
+	if err != nil {
+		return
+	}
+
+	upstream, err := net.Dial("tcp", addr)
+	if err != nil {
+		c.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer upstream.Close()
+
+	if _, err := c.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		return
+	}
+
+	go func() {
+		io.Copy(upstream, c)
+		upstream.Close()
+	}()
+	io.Copy(c, upstream)
+}
+
+// socksHandshake negotiates no authentication and reads a CONNECT request.
+// Parameters:
+//   - c: Client connection
+//
+// Returns:
+//   - string: Requested host:port
+//   - error: Any error that occurred, including unsupported requests
+func socksHandshake(c net.Conn) (string, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(c, head); err != nil {
+		return "", err
+	}
+	if head[0] != 5 {
+		return "", errors.New("unsupported SOCKS version")
+	}
+	if _, err := io.ReadFull(c, make([]byte, head[1])); err != nil {
+		return "", err
+	}
+	if _, err := c.Write([]byte{5, 0}); err != nil {
+		return "", err
+	}
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(c, req); err != nil {
+		return "", err
+	}
+	if req[1] != 1 {
+		return "", errors.New("unsupported SOCKS command")
+	}
+
+	var host string
+	switch req[3] {
+	case 1, 4:
+		ip := make([]byte, net.IPv4len)
+		if req[3] == 4 {
+			ip = make([]byte, net.IPv6len)
+		}
+		if _, err := io.ReadFull(c, ip); err != nil {
+			return "", err
+		}
+		host = net.IP(ip).String()
+	case 3:
+		n := make([]byte, 1)
+		if _, err := io.ReadFull(c, n); err != nil {
+			return "", err
+		}
+		name := make([]byte, n[0])
+		if _, err := io.ReadFull(c, name); err != nil {
+			return "", err
+		}
+		host = string(name)
+	default:
+		return "", errors.New("unsupported SOCKS address type")
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(c, port); err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port)))), nil
+}
@@ -0,0 +1,114 @@
+package httptines
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// proxyRule is a parsed ExcludeProxies pattern.
+type proxyRule struct {
+	prefix netip.Prefix // Excluded address range, invalid for hostname globs
+	glob   string       // Lowercase hostname glob
+}
+
+// parseProxyRule parses a pattern: a CIDR range, an IP address or a hostname glob.
+// Parameters:
+//   - s: Pattern, e.g. "10.0.0.0/8", "192.0.2.1" or "*.example.com"
+//
+// Returns:
+//   - proxyRule: Parsed rule
+//   - error: Error describing an invalid pattern
+func parseProxyRule(s string) (proxyRule, error) {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, "/") {
+		p, err := netip.ParsePrefix(s)
+		if err != nil {
+			return proxyRule{}, fmt.Errorf("invalid ExcludeProxies range %q", s)
+		}
+		return proxyRule{prefix: p.Masked()}, nil
+	}
+	if a, err := netip.ParseAddr(s); err == nil {
+		return proxyRule{prefix: netip.PrefixFrom(a, a.BitLen())}, nil
+	}
+	if _, err := path.Match(s, ""); err != nil || s == "" {
+		return proxyRule{}, fmt.Errorf("invalid ExcludeProxies pattern %q", s)
+	}
+	return proxyRule{glob: strings.ToLower(s)}, nil
+}
+
+// parseProxyRules parses all patterns, logging and skipping invalid ones.
+// Parameters:
+//   - patterns: ExcludeProxies patterns
+//
+// Returns:
+//   - []proxyRule: Parsed rules
+func (w *Worker) parseProxyRules(patterns []string) []proxyRule {
+	var rules []proxyRule
+	for _, s := range patterns {
+		r, err := parseProxyRule(s)
+		if err != nil {
+			w.werr(err.Error())
+			continue
+		}
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// matches reports whether the proxy host is excluded by the rule.
+// Parameters:
+//   - host: Proxy hostname or IP address
+//
+// Returns:
+//   - bool: True if the rule matches the host
+func (r proxyRule) matches(host string) bool {
+	if r.prefix.IsValid() {
+		a, err := netip.ParseAddr(host)
+		return err == nil && r.prefix.Contains(a.Unmap())
+	}
+	ok, _ := path.Match(r.glob, strings.ToLower(host))
+	return ok
+}
+
+// excluded reports whether the proxy matches any ExcludeProxies pattern.
+// Parameters:
+//   - u: Proxy URL
+//
+// Returns:
+//   - bool: True if the proxy must not be used
+func (w *Worker) excluded(u *url.URL) bool {
+	host := u.Hostname()
+	for _, r := range w.excludes {
+		if r.matches(host) {
+			return true
+		}
+	}
+	return false
+}
+
+// skipExcluded filters out proxies matching ExcludeProxies.
+// Parameters:
+//   - proxies: Proxies to filter
+//
+// Returns:
+//   - proxyMap: Proxies that may be used
+func (w *Worker) skipExcluded(proxies proxyMap) proxyMap {
+	if len(w.excludes) == 0 {
+		return proxies
+	}
+
+	result := make(proxyMap, len(proxies))
+	for addr, u := range proxies {
+		if !w.excluded(u) {
+			result[addr] = u
+		}
+	}
+
+	if n := len(proxies) - len(result); n > 0 {
+		w.wlog(fmt.Sprintf("%d excluded proxies skipped", n))
+	}
+	return result
+}
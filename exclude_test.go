@@ -0,0 +1,64 @@
+package httptines
+
+import (
+	"io"
+	"net/url"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Exclude proxies", func() {
+	Describe("parseProxyRule()", func() {
+		It("matches CIDR ranges, addresses and hostname globs", func() {
+			for _, c := range []struct {
+				pattern, host string
+				want          bool
+			}{
+				{"10.0.0.0/8", "10.20.30.40", true},
+				{"10.0.0.0/8", "11.0.0.1", false},
+				{"10.1.2.3/8", "10.9.9.9", true},
+				{"10.0.0.0/8", "::ffff:10.0.0.1", true},
+				{"2001:db8::/32", "2001:db8::1", true},
+				{"203.0.113.7", "203.0.113.7", true},
+				{"203.0.113.7", "203.0.113.8", false},
+				{"*.Example.com", "proxy.example.COM", true},
+				{"*.example.com", "example.com", false},
+				{"proxy?.example.com", "proxy1.example.com", true},
+				{"*.example.com", "10.0.0.1", false},
+			} {
+				r, err := parseProxyRule(c.pattern)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(r.matches(c.host)).To(Equal(c.want), "%s %s", c.pattern, c.host)
+			}
+		})
+
+		It("rejects invalid patterns", func() {
+			for _, p := range []string{"10.0.0.0/99", "bad/range", "[a-", ""} {
+				_, err := parseProxyRule(p)
+				Expect(err).To(HaveOccurred(), p)
+			}
+		})
+	})
+
+	Describe("skipExcluded()", func() {
+		It("drops the matching proxies", func() {
+			w := &Worker{excludes: (&Worker{Logger: io.Discard}).parseProxyRules([]string{"10.0.0.0/8", "*.honeypot.test", "[a-"})}
+			proxies := proxyMap{
+				"10.0.0.1:80":         &url.URL{Scheme: "http", Host: "10.0.0.1:80"},
+				"1.1.1.1:80":          &url.URL{Scheme: "http", Host: "1.1.1.1:80"},
+				"a.honeypot.test:80":  &url.URL{Scheme: "http", Host: "a.honeypot.test:80"},
+				"good.example.com:80": &url.URL{Scheme: "socks5", Host: "good.example.com:80"},
+			}
+
+			Expect(w.skipExcluded(proxies)).To(HaveLen(2))
+			Expect(w.skipExcluded(proxies)).To(HaveKey("1.1.1.1:80"))
+			Expect(w.skipExcluded(proxies)).To(HaveKey("good.example.com:80"))
+		})
+
+		It("keeps all proxies without patterns", func() {
+			proxies := proxyMap{"10.0.0.1:80": &url.URL{Scheme: "http", Host: "10.0.0.1:80"}}
+			Expect((&Worker{}).skipExcluded(proxies)).To(Equal(proxies))
+		})
+	})
+})
@@ -1,70 +1,117 @@
 package httptines
 
 import (
+	"bytes"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"os"
 	"reflect"
-	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
-// wlog writes a log message to stdout and broadcasts it to connected clients.
+// ErrBareRedirect is returned when a proxy responds with a 3xx status without a Location header.
+// Some proxies answer this way instead of forwarding the request when they block it.
+var ErrBareRedirect = errors.New("redirect without location")
+
+// ErrRedirectLoop is returned when a redirect leads to a URL visited before.
+var ErrRedirectLoop = errors.New("redirect loop")
+
+// ErrTooManyRedirects is returned when a request exceeds MaxRedirects.
+var ErrTooManyRedirects = errors.New("too many redirects")
+
+// defaultMaxRedirects is the number of redirects followed if no limit is set.
+const defaultMaxRedirects = 10
+
+// wlog writes an informational log message to stdout and broadcasts it to connected clients.
+// It is used where no worker is at hand, e.g. by sinks.
 // Parameters:
 //   - s: Log message to write
 func wlog(s string) {
-	m := fmt.Sprintf("%s %s", time.Now().Format(time.DateTime), s)
-	fmt.Println(m)
-	p, _ := json.Marshal(Payload{"log", m})
+	logAt(os.Stdout, "", nil, levelInfo, s)
+}
 
-	select {
-	case broadcast <- p:
-	default:
-	}
+// werr writes an error log message to stdout and broadcasts it to connected clients.
+// It is used where no worker is at hand, e.g. by sinks.
+// Parameters:
+//   - s: Log message to write
+func werr(s string) {
+	logAt(os.Stdout, "", nil, levelError, s)
 }
 
-// setDefaultValues sets default values for struct fields based on their "default" tags.
+// wlog writes an informational log message to the worker's Logger and broadcasts it to connected clients.
 // Parameters:
-//   - obj: Pointer to the struct to initialize
-func setDefaultValues(obj interface{}) {
-	tof := reflect.TypeOf(obj).Elem()
-	vof := reflect.ValueOf(obj).Elem()
+//   - s: Log message to write
+func (w *Worker) wlog(s string) {
+	logAt(w.logOutput(), w.Namespace, w.PayloadEncoders, levelInfo, s)
+}
 
-	for i := range vof.NumField() {
-		vf := vof.Field(i)
-		v := tof.Field(i).Tag.Get("default")
+// werr writes an error log message to the worker's Logger and broadcasts it to connected clients.
+// Parameters:
+//   - s: Log message to write
+func (w *Worker) werr(s string) {
+	logAt(w.logOutput(), w.Namespace, w.PayloadEncoders, levelError, s)
+}
 
-		if v == "" || !vf.IsZero() {
-			continue
-		}
+// logOutput returns the writer receiving the worker's log lines.
+// Returns:
+//   - io.Writer: Logger, os.Stdout if it isn't set
+func (w *Worker) logOutput() io.Writer {
+	if w.Logger != nil {
+		return w.Logger
+	}
+	return os.Stdout
+}
 
-		switch vf.Kind() {
-		case reflect.String:
-			vf.SetString(v)
-		case reflect.Int:
-			if intv, err := strconv.ParseInt(v, 10, 64); err == nil {
-				vf.SetInt(intv)
-			}
-		case reflect.Slice:
-			if vf.Type().Elem().Kind() == reflect.String {
-				values := strings.Split(v, ",")
-				vf.Set(reflect.ValueOf(values))
-			}
-		}
+// logAt writes a log message, keeps it in the history and broadcasts it to connected clients.
+// Parameters:
+//   - out: Writer receiving the log line
+//   - namespace: Run label prefixed to the message, none if empty
+//   - encoders: Custom payload encoders of the worker
+//   - level: Message level
+//   - s: Log message to write
+func logAt(out io.Writer, namespace string, encoders map[string]PayloadEncoder, level, s string) {
+	if namespace != "" {
+		s = fmt.Sprintf("[%s] %s", namespace, s)
+	}
+	m := fmt.Sprintf("%s %s", time.Now().Format(time.DateTime), s)
+	fmt.Fprintln(out, m)
+	msg := newMessage(Payload{"log", logLine{Level: level, Message: m}}, level, encoders)
+	history.add(msg)
+
+	select {
+	case broadcast <- msg:
+	default:
 	}
 }
 
+// ValidationError is returned when required fields are not set.
+type ValidationError struct {
+	// Fields contains the names of the missing fields
+	Fields []string
+}
+
+// Error implements the error interface.
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("required fields are missing: %s", strings.Join(e.Fields, ", "))
+}
+
 // validate checks if required fields in a struct are set based on their "validate" tags.
 // Parameters:
 //   - obj: Pointer to the struct to validate
-func validate(obj interface{}) {
+//
+// Returns:
+//   - error: *ValidationError listing the missing fields, nil if all are set
+func validate(obj interface{}) error {
 	tof := reflect.TypeOf(obj).Elem()
 	vof := reflect.ValueOf(obj).Elem()
 
+	var missing []string
 	for i := range vof.NumField() {
 		tf := tof.Field(i)
 		vf := vof.Field(i)
@@ -75,43 +122,178 @@ func validate(obj interface{}) {
 		}
 
 		if strings.Contains(v, "required") && vf.IsZero() {
-			wlog(fmt.Sprintf("Field \"%s\" is required", tf.Name))
-			os.Exit(0)
+			missing = append(missing, tf.Name)
 		}
 	}
+
+	if len(missing) > 0 {
+		return &ValidationError{Fields: missing}
+	}
+	return nil
+}
+
+// reqOpts contains per-request options.
+type reqOpts struct {
+	agent    string                    // User-Agent header value
+	host     string                    // Host header override
+	sni      string                    // TLS server name override
+	language string                    // Accept-Language header value
+	method   string                    // HTTP method, GET if empty
+	header   http.Header               // Additional request headers
+	body     []byte                    // Request body
+	prepare  func(*http.Request) error // Hook called before the request is sent
+	validate func(int, []byte) error   // Hook deciding whether the response is a success instead of its status
+	maxHops  int                       // Maximum number of redirects followed, defaultMaxRedirects if zero
 }
 
-// request makes an HTTP GET request to the target URL using the provided proxy server.
+// reply contains the response body and transfer statistics of a request.
+type reply struct {
+	body     []byte      // Response body
+	status   int         // Response status code
+	header   http.Header // Response headers
+	hops     []Redirect  // Redirects followed
+	sent     int64       // Bytes sent
+	received int64       // Bytes received
+	written  atomic.Bool // Whether the request was written to the connection
+}
+
+// request makes an HTTP request to the target URL using the provided proxy server.
+// Any 2xx status is a success, unless the validate option decides instead.
 // Parameters:
 //   - ctx: Context for the request
 //   - target: URL to request
 //   - s: Server to use for the request
+//   - o: Request options
 //
 // Returns:
-//   - []byte: Response body
+//   - *reply: Response body and transfer statistics, never nil
 //   - error: Any error that occurred
-func request(ctx context.Context, target string, s *Server) ([]byte, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+func request(ctx context.Context, target string, s *Server, o reqOpts) (*reply, error) {
+	rep := &reply{}
+
+	method := o.method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var payload io.Reader
+	if o.body != nil {
+		payload = bytes.NewReader(o.body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, payload)
 	if err != nil {
-		return nil, err
+		return rep, err
+	}
+
+	req.Header.Set("User-Agent", o.agent)
+	req.Header.Set("Accept-Encoding", "gzip")
+	if o.language != "" {
+		req.Header.Set("Accept-Language", o.language)
+	}
+	for k, v := range o.header {
+		req.Header[http.CanonicalHeaderKey(k)] = v
 	}
 
-	req.Header.Set("User-Agent", ua.get())
+	var transport http.RoundTripper
+	switch {
+	case s.transport != nil:
+		transport = s.transport(s.proxy(target))
+	case s.conns != nil:
+		transport = s.conns.transport(s.proxy(target), s.header, o.sni)
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), s.conns.trace()))
+	default:
+		t := newTransport(s.proxy(target), s.header, o.sni)
+		defer t.CloseIdleConnections()
+		transport = t
+	}
 
+	if o.host != "" {
+		req.Host = o.host
+	}
+
+	if o.prepare != nil {
+		if err := o.prepare(req); err != nil {
+			return rep, err
+		}
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		WroteRequest: func(httptrace.WroteRequestInfo) { rep.written.Store(true) },
+	}))
+
+	hopStart := time.Now()
 	client := &http.Client{
-		Transport: &http.Transport{Proxy: http.ProxyURL(s.URL)},
-		Timeout:   s.timeout,
+		Transport: transport,
+		Timeout:   s.requestTimeout(),
+		CheckRedirect: func(next *http.Request, via []*http.Request) error {
+			rep.hops = append(rep.hops, Redirect{
+				URL:      via[len(via)-1].URL.String(),
+				Status:   next.Response.StatusCode,
+				Location: next.URL.String(),
+				Latency:  time.Since(hopStart),
+			})
+			hopStart = time.Now()
+
+			for _, r := range via {
+				if r.URL.String() == next.URL.String() {
+					return ErrRedirectLoop
+				}
+			}
+			maxHops := o.maxHops
+			if maxHops <= 0 {
+				maxHops = defaultMaxRedirects
+			}
+			if len(via) > maxHops {
+				return ErrTooManyRedirects
+			}
+			return nil
+		},
 	}
 
+	sent, overhead := requestSize(req)
+	rep.sent = sent
+
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		s.traffic(sent, overhead, 0)
+		return rep, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	s.capture(resp.Header)
+	rep.status = resp.StatusCode
+	rep.header = resp.Header
+
+	body, n, err := readBody(resp, s)
+	rep.received = responseSize(resp) + n
+	s.traffic(sent, overhead, rep.received)
+
+	if isBareRedirect(resp) {
+		return rep, ErrBareRedirect
 	}
 
-	return io.ReadAll(resp.Body)
+	if o.validate != nil && err == nil {
+		if err := o.validate(resp.StatusCode, body); err != nil {
+			return rep, fmt.Errorf("%w: %v", ErrInvalidResponse, err)
+		}
+		rep.body = body
+		return rep, nil
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return rep, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	rep.body = body
+	return rep, err
+}
+
+// isBareRedirect reports whether the response is a redirect without a Location header.
+// Parameters:
+//   - resp: HTTP response to inspect
+//
+// Returns:
+//   - bool: True if the status is 3xx and Location is missing
+func isBareRedirect(resp *http.Response) bool {
+	return resp.StatusCode >= 300 && resp.StatusCode < 400 && resp.Header.Get("Location") == ""
 }
@@ -0,0 +1,68 @@
+package httptines
+
+import (
+	"fmt"
+	"time"
+)
+
+// latencyBounds are the upper bounds of the latency histogram buckets.
+var latencyBounds = []time.Duration{
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2 * time.Second,
+	5 * time.Second,
+}
+
+// HistogramBucket is a bucket of the proxy latency histogram.
+type HistogramBucket struct {
+	// Label describes the latency range, e.g. "<500ms" or ">=5s"
+	Label string `json:"label"`
+	// Count is the number of proxies in the range
+	Count int `json:"count"`
+}
+
+// histogram buckets the latencies by latencyBounds.
+// Parameters:
+//   - latencies: Latencies in milliseconds
+//
+// Returns:
+//   - []HistogramBucket: Buckets from fastest to slowest
+func histogram(latencies []int) []HistogramBucket {
+	buckets := make([]HistogramBucket, len(latencyBounds)+1)
+	for i, b := range latencyBounds {
+		buckets[i].Label = fmt.Sprintf("<%s", b)
+	}
+	buckets[len(latencyBounds)].Label = fmt.Sprintf(">=%s", latencyBounds[len(latencyBounds)-1])
+
+	for _, ms := range latencies {
+		d := time.Duration(ms) * time.Millisecond
+		i := 0
+		for i < len(latencyBounds) && d >= latencyBounds[i] {
+			i++
+		}
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+// latencies returns the latency of every registered server, measured by the
+// last request or by the check if no request completed yet.
+// Returns:
+//   - []int: Latencies in milliseconds
+func (r *registry) latencies() []int {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	latencies := make([]int, 0, len(r.servers))
+	for _, s := range r.servers {
+		s.m.RLock()
+		l := s.Latency
+		if l == 0 {
+			l = s.CheckLatency
+		}
+		s.m.RUnlock()
+		latencies = append(latencies, l)
+	}
+	return latencies
+}
@@ -0,0 +1,37 @@
+package httptines
+
+import (
+	"net/url"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Histogram", func() {
+	Describe("histogram()", func() {
+		It("buckets latencies", func() {
+			buckets := histogram([]int{100, 249, 250, 900, 1500, 4000, 7000, 30000})
+
+			Expect(buckets).To(Equal([]HistogramBucket{
+				{Label: "<250ms", Count: 2},
+				{Label: "<500ms", Count: 1},
+				{Label: "<1s", Count: 1},
+				{Label: "<2s", Count: 1},
+				{Label: "<5s", Count: 1},
+				{Label: ">=5s", Count: 2},
+			}))
+		})
+	})
+
+	Describe("latencies()", func() {
+		It("prefers the last request latency over the check latency", func() {
+			r := &registry{}
+			u1, _ := url.Parse("http://1.2.3.4:80")
+			u2, _ := url.Parse("http://5.6.7.8:80")
+			r.add(&Server{URL: u1, Latency: 300, CheckLatency: 100})
+			r.add(&Server{URL: u2, CheckLatency: 2000})
+
+			Expect(r.latencies()).To(ConsistOf(300, 2000))
+		})
+	})
+})
@@ -0,0 +1,169 @@
+package httptines
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+// proxyRecord holds the statistics of a proxy across runs.
+type proxyRecord struct {
+	// Positive is the number of successful requests
+	Positive int `json:"positive"`
+	// Negative is the number of failed requests
+	Negative int `json:"negative"`
+	// Latency is the exponentially weighted average latency of successful requests in milliseconds
+	Latency float64 `json:"latency"`
+	// Seen is the time the proxy was last used
+	Seen time.Time `json:"seen"`
+}
+
+// proxyHistory is an on-disk store of per-proxy statistics, so a restarted worker
+// favors the proxies that performed well before instead of starting from scratch.
+type proxyHistory struct {
+	m       sync.Mutex
+	path    string
+	ttl     time.Duration
+	records map[string]proxyRecord
+}
+
+// loadProxyHistory reads the proxy history from a file. A missing file yields an empty history.
+// Parameters:
+//   - path: File path
+//   - ttl: Period after which the record of an unused proxy is dropped
+//
+// Returns:
+//   - *proxyHistory: Proxy history
+//   - error: Any error that occurred while reading
+func loadProxyHistory(path string, ttl time.Duration) (*proxyHistory, error) {
+	h := &proxyHistory{path: path, ttl: ttl, records: map[string]proxyRecord{}}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return h, err
+	}
+	return h, json.Unmarshal(data, &h.records)
+}
+
+// get returns the record of a proxy.
+// Parameters:
+//   - key: Proxy key in the scheme://host:port format
+//
+// Returns:
+//   - proxyRecord: Record of the proxy, zero if the proxy is unknown or the history is disabled
+func (h *proxyHistory) get(key string) proxyRecord {
+	if h == nil {
+		return proxyRecord{}
+	}
+
+	h.m.Lock()
+	defer h.m.Unlock()
+	return h.records[key]
+}
+
+// record merges the statistics of the current run into the record the server started with.
+// Recording a server again replaces its previous update. Gateway slots aren't recorded.
+// Parameters:
+//   - s: Server to record
+//   - now: Current time
+func (h *proxyHistory) record(s *Server, now time.Time) {
+	if h == nil || s.slot > 0 {
+		return
+	}
+
+	s.m.RLock()
+	r := proxyRecord{
+		Positive: s.past.Positive + s.Positive,
+		Negative: s.past.Negative + s.Negative,
+		Latency:  cmp.Or(s.avgLatency, s.past.Latency),
+		Seen:     now,
+	}
+	s.m.RUnlock()
+
+	h.m.Lock()
+	h.records[proxyKey(s.URL)] = r
+	h.m.Unlock()
+}
+
+// save drops the expired records and writes the history to its file.
+// Parameters:
+//   - now: Current time
+//
+// Returns:
+//   - error: Any error that occurred while writing
+func (h *proxyHistory) save(now time.Time) error {
+	h.m.Lock()
+	for key, r := range h.records {
+		if h.ttl > 0 && now.Sub(r.Seen) >= h.ttl {
+			delete(h.records, key)
+		}
+	}
+	data, err := json.Marshal(h.records)
+	h.m.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp := h.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.path)
+}
+
+// score estimates the success rate of the server from its statistics of all runs,
+// smoothed for servers with few requests.
+// Returns:
+//   - float64: Score between 0 and 1
+func (s *Server) score() float64 {
+	return successRate(s.past.Positive+s.Positive, s.past.Negative+s.Negative)
+}
+
+// successRate estimates the success rate from request counts, smoothed for few requests.
+// Parameters:
+//   - positive: Number of successful requests
+//   - negative: Number of failed requests
+//
+// Returns:
+//   - float64: Rate between 0 and 1
+func successRate(positive, negative int) float64 {
+	return float64(positive+1) / float64(positive+negative+2)
+}
+
+// byScore sorts the servers by score, best first, so historically good proxies
+// start processing targets before the others.
+// Parameters:
+//   - servers: Servers to sort
+func byScore(servers []*Server) {
+	scores := make(map[*Server]float64, len(servers))
+	for _, s := range servers {
+		s.m.RLock()
+		scores[s] = s.score()
+		s.m.RUnlock()
+	}
+	slices.SortStableFunc(servers, func(a, b *Server) int { return cmp.Compare(scores[b], scores[a]) })
+}
+
+// saveHistory records the enabled servers and saves the proxy history.
+func (w *Worker) saveHistory() {
+	if w.history == nil {
+		return
+	}
+
+	now := time.Now()
+	for _, s := range w.servers.enabled() {
+		w.history.record(s, now)
+	}
+	if err := w.history.save(now); err != nil {
+		w.werr(fmt.Sprintf("error saving proxy history %s: %v", w.ProxyHistory, err))
+	}
+}
@@ -0,0 +1,122 @@
+package httptines
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("proxyHistory", func() {
+	var (
+		h    *proxyHistory
+		path string
+		now  time.Time
+		u    *url.URL
+	)
+
+	BeforeEach(func() {
+		path = filepath.Join(GinkgoT().TempDir(), "history.json")
+		now = time.Now()
+		u = &url.URL{Scheme: "http", Host: "1.1.1.1:80"}
+
+		var err error
+		h, err = loadProxyHistory(path, 24*time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("merges the current run into the record the server started with", func() {
+		s := &Server{URL: u, Positive: 3, Negative: 1, avgLatency: 120}
+		s.past = proxyRecord{Positive: 10, Negative: 2, Latency: 200}
+
+		h.record(s, now)
+		h.record(s, now)
+		Expect(h.get(proxyKey(u))).To(Equal(proxyRecord{Positive: 13, Negative: 3, Latency: 120, Seen: now}))
+	})
+
+	It("keeps the latency of previous runs until a request succeeds", func() {
+		s := &Server{URL: u, Negative: 1}
+		s.past = proxyRecord{Latency: 200}
+
+		h.record(s, now)
+		Expect(h.get(proxyKey(u)).Latency).To(Equal(200.0))
+	})
+
+	It("skips gateway slots", func() {
+		h.record(&Server{URL: u, slot: 1, Positive: 1}, now)
+		Expect(h.records).To(BeEmpty())
+	})
+
+	It("persists across runs and drops expired records", func() {
+		other := &url.URL{Scheme: "http", Host: "2.2.2.2:80"}
+		h.record(&Server{URL: u, Positive: 5}, now)
+		h.record(&Server{URL: other, Positive: 1}, now.Add(-25*time.Hour))
+		Expect(h.save(now)).To(Succeed())
+
+		info, err := os.Stat(path)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(info.Mode().Perm()).To(Equal(os.FileMode(0o644)))
+
+		loaded, err := loadProxyHistory(path, 24*time.Hour)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.records).To(HaveLen(1))
+		Expect(loaded.get(proxyKey(u)).Positive).To(Equal(5))
+	})
+
+	It("returns zero records when disabled", func() {
+		var disabled *proxyHistory
+		Expect(disabled.get(proxyKey(u))).To(Equal(proxyRecord{}))
+		disabled.record(&Server{URL: u}, now)
+	})
+})
+
+var _ = Describe("Worker proxy history", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		path := filepath.Join(GinkgoT().TempDir(), "history.json")
+		h, err := loadProxyHistory(path, 0)
+		Expect(err).NotTo(HaveOccurred())
+
+		w = &Worker{ProxyHistory: path, history: h}
+		w.reqCtx = context.Background()
+	})
+
+	It("seeds new servers with the statistics of previous runs", func() {
+		u, _ := url.Parse("http://1.1.1.1:80")
+		w.history.records[proxyKey(u)] = proxyRecord{Positive: 8, Negative: 0, Latency: 150}
+
+		s := w.newServer(u)
+		Expect(s.Positive).To(BeZero())
+		Expect(s.avgLatency).To(Equal(150.0))
+		Expect(s.score()).To(Equal(0.9))
+	})
+
+	It("sorts servers by score across runs", func() {
+		good := &Server{past: proxyRecord{Positive: 50}}
+		fresh := &Server{}
+		bad := &Server{past: proxyRecord{Negative: 20}}
+		servers := []*Server{bad, fresh, good}
+
+		byScore(servers)
+		Expect(servers[0]).To(BeIdenticalTo(good))
+		Expect(servers[1]).To(BeIdenticalTo(fresh))
+		Expect(servers[2]).To(BeIdenticalTo(bad))
+	})
+
+	It("saves the enabled servers", func() {
+		u, _ := url.Parse("http://1.1.1.1:80")
+		s := &Server{URL: u, Positive: 2}
+		w.servers.add(s)
+
+		w.saveHistory()
+
+		loaded, err := loadProxyHistory(w.ProxyHistory, 0)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(loaded.get(proxyKey(u)).Positive).To(Equal(2))
+	})
+})
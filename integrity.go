@@ -0,0 +1,112 @@
+package httptines
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// ErrIntegrity is returned when a response body fails the integrity check.
+var ErrIntegrity = errors.New("integrity check failed")
+
+// ErrInvalidResponse is returned when a response is rejected by the ValidateResponse hook.
+var ErrInvalidResponse = errors.New("invalid response")
+
+// IntegrityRule describes the expected content of a response body.
+// Zero fields are not checked.
+type IntegrityRule struct {
+	// MinLength is the minimum body length in bytes
+	MinLength int
+	// Contains is a substring the body must contain
+	Contains string
+	// Excludes is a substring the body must not contain, e.g. a captcha marker
+	Excludes string
+	// SHA256 is the expected hex encoded checksum of the body
+	SHA256 string
+	// Pattern is a regular expression the body must match
+	Pattern string
+	// CanonicalSHA256 is the expected hex encoded checksum of the canonical body, with
+	// whitespace runs collapsed to single spaces and leading and trailing whitespace removed,
+	// so reformatted or re-wrapped bodies still match
+	CanonicalSHA256 string
+}
+
+// Check verifies the body against the rule
+// Parameters:
+//   - body: Response body
+//
+// Returns:
+//   - error: Description of the first violated expectation, nil if the body is valid
+func (r IntegrityRule) Check(body []byte) error {
+	if len(body) < r.MinLength {
+		return fmt.Errorf("body length %d is less than %d", len(body), r.MinLength)
+	}
+
+	if r.Contains != "" && !bytes.Contains(body, []byte(r.Contains)) {
+		return fmt.Errorf("body doesn't contain %q", r.Contains)
+	}
+
+	if r.Excludes != "" && bytes.Contains(body, []byte(r.Excludes)) {
+		return fmt.Errorf("body contains %q", r.Excludes)
+	}
+
+	if r.SHA256 != "" {
+		sum := sha256.Sum256(body)
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), r.SHA256) {
+			return errors.New("body checksum mismatch")
+		}
+	}
+
+	if r.Pattern != "" {
+		ok, err := regexp.Match(r.Pattern, body)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", r.Pattern, err)
+		}
+		if !ok {
+			return fmt.Errorf("body doesn't match %q", r.Pattern)
+		}
+	}
+
+	if r.CanonicalSHA256 != "" {
+		sum := sha256.Sum256(canonicalBody(body))
+		if !strings.EqualFold(hex.EncodeToString(sum[:]), r.CanonicalSHA256) {
+			return errors.New("canonical body checksum mismatch")
+		}
+	}
+
+	return nil
+}
+
+// canonicalBody returns the body with whitespace runs collapsed to single spaces.
+// Parameters:
+//   - body: Response body
+//
+// Returns:
+//   - []byte: Canonical body without leading and trailing whitespace
+func canonicalBody(body []byte) []byte {
+	return bytes.Join(bytes.Fields(body), []byte(" "))
+}
+
+// checkIntegrity runs the Integrity hook for a successfully fetched body.
+// Parameters:
+//   - ctx: Request context carrying the worker's context values
+//   - t: Target URL
+//   - body: Response body
+//
+// Returns:
+//   - error: Error wrapping ErrIntegrity if the hook rejected the body
+func (w *Worker) checkIntegrity(ctx context.Context, t string, body []byte) error {
+	if w.Integrity == nil {
+		return nil
+	}
+
+	if err := w.Integrity(ctx, t, body); err != nil {
+		return fmt.Errorf("%w: %v", ErrIntegrity, err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package httptines
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Integrity", func() {
+	Describe("IntegrityRule.Check()", func() {
+		body := []byte("<html>expected content</html>")
+
+		It("accepts a valid body", func() {
+			sum := sha256.Sum256(body)
+			r := IntegrityRule{MinLength: 10, Contains: "expected", SHA256: hex.EncodeToString(sum[:])}
+			Expect(r.Check(body)).To(Succeed())
+		})
+
+		It("rejects a short body", func() {
+			Expect(IntegrityRule{MinLength: 100}.Check(body)).NotTo(Succeed())
+		})
+
+		It("rejects a body without the substring", func() {
+			Expect(IntegrityRule{Contains: "captcha"}.Check(body)).NotTo(Succeed())
+		})
+
+		It("rejects a body with the excluded substring", func() {
+			Expect(IntegrityRule{Excludes: "captcha"}.Check(body)).To(Succeed())
+			Expect(IntegrityRule{Excludes: "expected"}.Check(body)).To(MatchError(`body contains "expected"`))
+		})
+
+		It("rejects a checksum mismatch", func() {
+			Expect(IntegrityRule{SHA256: "00"}.Check(body)).NotTo(Succeed())
+		})
+
+		It("matches the body against the pattern", func() {
+			Expect(IntegrityRule{Pattern: `<html>\w+ content`}.Check(body)).To(Succeed())
+			Expect(IntegrityRule{Pattern: `^login`}.Check(body)).To(MatchError(ContainSubstring("doesn't match")))
+			Expect(IntegrityRule{Pattern: `(`}.Check(body)).To(MatchError(ContainSubstring("invalid pattern")))
+		})
+
+		It("compares the checksum of the canonical body", func() {
+			sum := sha256.Sum256([]byte("<html>expected content</html>"))
+			r := IntegrityRule{CanonicalSHA256: hex.EncodeToString(sum[:])}
+			Expect(r.Check([]byte("\n  <html>expected\n\tcontent</html>\n"))).To(Succeed())
+			Expect(r.Check([]byte("<html>expected contents</html>"))).NotTo(Succeed())
+		})
+	})
+
+	Describe("checkIntegrity()", func() {
+		var w *Worker
+
+		BeforeEach(func() {
+			w = &Worker{}
+		})
+
+		When("no hook is set", func() {
+			It("accepts any body", func() {
+				Expect(w.checkIntegrity(context.Background(), "http://test1.com", nil)).To(Succeed())
+			})
+		})
+
+		It("wraps hook errors", func() {
+			w.Integrity = func(context.Context, string, []byte) error { return errors.New("injected") }
+
+			err := w.checkIntegrity(context.Background(), "http://test1.com", nil)
+			Expect(errors.Is(err, ErrIntegrity)).To(BeTrue())
+		})
+	})
+})
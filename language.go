@@ -0,0 +1,126 @@
+package httptines
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// htmlLang matches the lang attribute of the html tag.
+var htmlLang = regexp.MustCompile(`(?i)<html[^>]*?\slang\s*=\s*["']?([a-z]{2,3}(?:[-_][a-z0-9]+)*)`)
+
+// langScanLimit is the number of leading body bytes searched for the html tag.
+const langScanLimit = 64 << 10
+
+// countryLanguages maps ISO 3166 country codes to Accept-Language values.
+var countryLanguages = map[string]string{
+	"AR": "es-AR,es;q=0.9,en;q=0.8",
+	"AU": "en-AU,en;q=0.9",
+	"BR": "pt-BR,pt;q=0.9,en;q=0.8",
+	"CA": "en-CA,en;q=0.9,fr-CA;q=0.8",
+	"CN": "zh-CN,zh;q=0.9,en;q=0.8",
+	"DE": "de-DE,de;q=0.9,en;q=0.8",
+	"ES": "es-ES,es;q=0.9,en;q=0.8",
+	"FR": "fr-FR,fr;q=0.9,en;q=0.8",
+	"GB": "en-GB,en;q=0.9",
+	"ID": "id-ID,id;q=0.9,en;q=0.8",
+	"IN": "en-IN,en;q=0.9,hi;q=0.8",
+	"IT": "it-IT,it;q=0.9,en;q=0.8",
+	"JP": "ja-JP,ja;q=0.9,en;q=0.8",
+	"KR": "ko-KR,ko;q=0.9,en;q=0.8",
+	"MX": "es-MX,es;q=0.9,en;q=0.8",
+	"NL": "nl-NL,nl;q=0.9,en;q=0.8",
+	"PL": "pl-PL,pl;q=0.9,en;q=0.8",
+	"RU": "ru-RU,ru;q=0.9,en;q=0.8",
+	"TR": "tr-TR,tr;q=0.9,en;q=0.8",
+	"UA": "uk-UA,uk;q=0.9,en;q=0.8",
+	"US": "en-US,en;q=0.9",
+	"VN": "vi-VN,vi;q=0.9,en;q=0.8",
+}
+
+// acceptLanguage returns the Accept-Language value for a request to the target through the server.
+// A per-host override takes precedence over the language of the proxy's country.
+// Parameters:
+//   - t: Target URL
+//   - s: Server used for the request
+//
+// Returns:
+//   - string: Accept-Language value, empty if unknown
+func (w *Worker) acceptLanguage(t string, s *Server) string {
+	if len(w.AcceptLanguages) > 0 {
+		if u, err := url.Parse(t); err == nil {
+			if v, ok := w.AcceptLanguages[u.Hostname()]; ok {
+				return v
+			}
+		}
+	}
+
+	return countryLanguages[strings.ToUpper(s.Country)]
+}
+
+// locate sets the server's country using the GeoIP hook.
+// Parameters:
+//   - s: Server to locate
+func (w *Worker) locate(s *Server) {
+	if w.GeoIP != nil {
+		s.Country = strings.ToUpper(w.GeoIP(s.URL.Hostname()))
+	}
+}
+
+// pageLanguage detects the language of a page from the Content-Language header,
+// or the lang attribute of the html tag if the header is missing.
+// Parameters:
+//   - h: Response headers
+//   - body: Response body
+//
+// Returns:
+//   - string: Lowercase language tag, e.g. "en-us", empty if it isn't declared
+func pageLanguage(h http.Header, body []byte) string {
+	if v := h.Get("Content-Language"); v != "" {
+		first, _, _ := strings.Cut(v, ",")
+		return strings.ToLower(strings.TrimSpace(first))
+	}
+
+	if len(body) > langScanLimit {
+		body = body[:langScanLimit]
+	}
+	if m := htmlLang.FindSubmatch(body); m != nil {
+		return strings.ReplaceAll(strings.ToLower(string(m[1])), "_", "-")
+	}
+	return ""
+}
+
+// detectLanguage returns the page language if a language filter is configured.
+// Parameters:
+//   - h: Response headers
+//   - body: Response body
+//
+// Returns:
+//   - string: Page language, empty if Languages is not set or the language isn't declared
+func (w *Worker) detectLanguage(h http.Header, body []byte) string {
+	if len(w.Languages) == 0 {
+		return ""
+	}
+	return pageLanguage(h, body)
+}
+
+// languageAccepted reports whether the result is written to the sink. Pages
+// without a declared language are accepted.
+// Parameters:
+//   - r: Processed result
+//
+// Returns:
+//   - bool: False if the page is in a language outside Languages and LanguageFilter is "skip"
+func (w *Worker) languageAccepted(r Result) bool {
+	if len(w.Languages) == 0 || w.LanguageFilter != "skip" || r.Language == "" {
+		return true
+	}
+
+	primary, _, _ := strings.Cut(r.Language, "-")
+	return slices.ContainsFunc(w.Languages, func(l string) bool {
+		l = strings.ToLower(l)
+		return l == r.Language || l == primary
+	})
+}
@@ -0,0 +1,106 @@
+package httptines
+
+import (
+	"net/http"
+	"net/url"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Language", func() {
+	var (
+		w *Worker
+		s *Server
+	)
+
+	BeforeEach(func() {
+		u, _ := url.Parse("http://1.2.3.4:8080")
+		w = &Worker{}
+		s = &Server{URL: u}
+	})
+
+	Describe("locate()", func() {
+		It("sets the country using the GeoIP hook", func() {
+			w.GeoIP = func(host string) string {
+				Expect(host).To(Equal("1.2.3.4"))
+				return "de"
+			}
+			w.locate(s)
+			Expect(s.Country).To(Equal("DE"))
+		})
+
+		When("no hook is set", func() {
+			It("leaves the country empty", func() {
+				w.locate(s)
+				Expect(s.Country).To(BeEmpty())
+			})
+		})
+	})
+
+	Describe("acceptLanguage()", func() {
+		It("returns the language of the proxy's country", func() {
+			s.Country = "FR"
+			Expect(w.acceptLanguage("http://example.com", s)).To(Equal("fr-FR,fr;q=0.9,en;q=0.8"))
+		})
+
+		It("prefers the per-host override", func() {
+			s.Country = "FR"
+			w.AcceptLanguages = map[string]string{"example.com": "en-US"}
+			Expect(w.acceptLanguage("http://example.com/a", s)).To(Equal("en-US"))
+		})
+
+		It("returns empty string for unknown countries", func() {
+			Expect(w.acceptLanguage("http://example.com", s)).To(BeEmpty())
+		})
+	})
+
+	Describe("pageLanguage()", func() {
+		It("prefers the Content-Language header", func() {
+			h := http.Header{"Content-Language": {"de-AT, en"}}
+			Expect(pageLanguage(h, []byte(`<html lang="fr">`))).To(Equal("de-at"))
+		})
+
+		It("reads the lang attribute of the html tag", func() {
+			Expect(pageLanguage(http.Header{}, []byte("<!DOCTYPE html>\n<html class=\"no-js\" lang='pt_BR'>"))).To(Equal("pt-br"))
+			Expect(pageLanguage(http.Header{}, []byte(`<HTML LANG=en>`))).To(Equal("en"))
+		})
+
+		It("returns empty string if no language is declared", func() {
+			Expect(pageLanguage(http.Header{}, []byte(`<html><body lang="en">`))).To(BeEmpty())
+		})
+	})
+
+	Describe("languageAccepted()", func() {
+		BeforeEach(func() {
+			w.Languages = []string{"en", "de-AT"}
+			w.LanguageFilter = "skip"
+		})
+
+		It("accepts pages in the configured languages", func() {
+			Expect(w.languageAccepted(Result{Language: "en"})).To(BeTrue())
+			Expect(w.languageAccepted(Result{Language: "en-gb"})).To(BeTrue())
+			Expect(w.languageAccepted(Result{Language: "de-at"})).To(BeTrue())
+		})
+
+		It("skips pages in other languages", func() {
+			Expect(w.languageAccepted(Result{Language: "de-de"})).To(BeFalse())
+			Expect(w.languageAccepted(Result{Language: "fr"})).To(BeFalse())
+		})
+
+		It("accepts pages without a declared language", func() {
+			Expect(w.languageAccepted(Result{})).To(BeTrue())
+		})
+
+		It("accepts every page when tagging", func() {
+			w.LanguageFilter = "tag"
+			Expect(w.languageAccepted(Result{Language: "fr"})).To(BeTrue())
+		})
+	})
+
+	Describe("detectLanguage()", func() {
+		It("detects nothing without Languages", func() {
+			Expect(w.detectLanguage(http.Header{"Content-Language": {"en"}}, nil)).To(BeEmpty())
+		})
+	})
+})
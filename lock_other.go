@@ -0,0 +1,11 @@
+//go:build !unix
+
+package httptines
+
+import "os"
+
+// lockFile is a no-op on platforms without flock, claims are only exclusive within the process.
+func lockFile(*os.File) error { return nil }
+
+// unlockFile is a no-op on platforms without flock.
+func unlockFile(*os.File) error { return nil }
@@ -0,0 +1,28 @@
+//go:build unix
+
+package httptines
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive advisory lock on the file, waiting for other processes to release it.
+// Parameters:
+//   - f: File to lock
+//
+// Returns:
+//   - error: Any error that occurred while locking
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+// unlockFile releases the lock taken by lockFile.
+// Parameters:
+//   - f: Locked file
+//
+// Returns:
+//   - error: Any error that occurred while unlocking
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
@@ -0,0 +1,230 @@
+package httptines
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Log levels.
+const (
+	levelInfo  = "info"
+	levelError = "error"
+)
+
+// logHistorySize is the number of recent log lines sent to newly connected clients.
+const logHistorySize = 200
+
+// dedupWindow is the period identical errors are collapsed into a single summary.
+const dedupWindow = time.Minute
+
+// dedupIDs is the maximum number of attempt IDs listed in a summary.
+const dedupIDs = 20
+
+// logLine represents a log message sent to the web interface.
+type logLine struct {
+	// Level is the message level: info or error
+	Level string `json:"level"`
+	// Message is the formatted log message
+	Message string `json:"message"`
+}
+
+// message represents a WebSocket message with the log level it belongs to.
+// Messages without a level are sent to every client.
+type message struct {
+	data    []byte            // JSON encoded payload
+	encoded map[string][]byte // Payload in the other encodings, keyed by name
+	level   string
+}
+
+// logRing keeps the most recent log messages.
+type logRing struct {
+	m     sync.Mutex
+	lines []message
+	next  int
+}
+
+// history holds the recent log messages replayed to new clients.
+var history logRing
+
+// add stores a message, overwriting the oldest one when the ring is full.
+// Parameters:
+//   - msg: Log message
+func (r *logRing) add(msg message) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if len(r.lines) < logHistorySize {
+		r.lines = append(r.lines, msg)
+		return
+	}
+	r.lines[r.next] = msg
+	r.next = (r.next + 1) % logHistorySize
+}
+
+// recent returns the stored messages from oldest to newest.
+// Returns:
+//   - []message: Stored messages
+func (r *logRing) recent() []message {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	lines := make([]message, 0, len(r.lines))
+	lines = append(lines, r.lines[r.next:]...)
+	return append(lines, r.lines[:r.next]...)
+}
+
+// accepts reports whether a client filtering by the given level receives the message.
+// Parameters:
+//   - filter: Client's level filter, empty to receive everything
+//
+// Returns:
+//   - bool: True if the message should be sent to the client
+func (msg message) accepts(filter string) bool {
+	return filter != levelError || msg.level == "" || msg.level == levelError
+}
+
+// dedupEntry counts the occurrences of an error within the current window.
+type dedupEntry struct {
+	first time.Time
+	count int
+	class string
+	proxy string
+	ids   []string // Attempt IDs of the repetitions, at most dedupIDs
+}
+
+// dedupLog collapses repeated identical errors, keyed by proxy and error class.
+// The first occurrence is logged immediately, the repetitions are summarized
+// once the window ends.
+type dedupLog struct {
+	m    sync.Mutex
+	seen map[string]*dedupEntry
+}
+
+// add records an error occurrence.
+// Parameters:
+//   - proxy: Proxy URL
+//   - class: Error class
+//   - id: Attempt ID
+//   - now: Current time
+//
+// Returns:
+//   - []string: Summaries of the ended window of this error
+//   - bool: True if the occurrence should be logged
+func (d *dedupLog) add(proxy, class, id string, now time.Time) ([]string, bool) {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	if d.seen == nil {
+		d.seen = map[string]*dedupEntry{}
+	}
+
+	key := proxy + " " + class
+	var summaries []string
+	if e, ok := d.seen[key]; ok {
+		if now.Sub(e.first) < dedupWindow {
+			e.count++
+			if len(e.ids) < dedupIDs {
+				e.ids = append(e.ids, id)
+			}
+			return nil, false
+		}
+		summaries = e.summary()
+	}
+
+	d.seen[key] = &dedupEntry{first: now, count: 1, class: class, proxy: proxy}
+	return summaries, true
+}
+
+// flush removes the errors whose window has ended.
+// Parameters:
+//   - now: Current time
+//
+// Returns:
+//   - []string: Summaries of the repeated errors, sorted
+func (d *dedupLog) flush(now time.Time) []string {
+	d.m.Lock()
+	defer d.m.Unlock()
+
+	var summaries []string
+	for key, e := range d.seen {
+		if now.Sub(e.first) >= dedupWindow {
+			summaries = append(summaries, e.summary()...)
+			delete(d.seen, key)
+		}
+	}
+	sort.Strings(summaries)
+	return summaries
+}
+
+// summary describes the repetitions of the error with the attempt IDs of the repetitions.
+// Returns:
+//   - []string: Summary, empty if the error occurred once
+func (e *dedupEntry) summary() []string {
+	if e.count < 2 {
+		return nil
+	}
+	ids := strings.Join(e.ids, " ")
+	if more := e.count - 1 - len(e.ids); more > 0 {
+		ids += fmt.Sprintf(" and %d more", more)
+	}
+	return []string{fmt.Sprintf("error %q via %s occurred %d times in the last minute [%s]", e.class, e.proxy, e.count, ids)}
+}
+
+// errorClass returns a description of the error without request specific details,
+// so identical failures of different targets are recognized.
+// Parameters:
+//   - err: Request error
+//
+// Returns:
+//   - string: Error class
+func errorClass(err error) string {
+	var nerr net.Error
+	switch {
+	case errors.As(err, &nerr) && nerr.Timeout():
+		return "timeout"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.Is(err, syscall.ECONNRESET):
+		return "connection reset"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "unexpected EOF"
+	}
+
+	var uerr *url.Error
+	if errors.As(err, &uerr) {
+		return uerr.Err.Error()
+	}
+	return err.Error()
+}
+
+// logFailure logs a failed request, collapsing repetitions of the same error via the same proxy.
+// The attempt IDs of the repetitions are listed in their summary.
+// Parameters:
+//   - t: Target URL
+//   - proxy: Proxy URL
+//   - id: Attempt ID
+//   - err: Request error
+func (w *Worker) logFailure(t, proxy, id string, err error) {
+	summaries, first := w.errlog.add(proxy, errorClass(err), id, time.Now())
+	for _, s := range summaries {
+		w.werr(s)
+	}
+	if first {
+		w.werr(fmt.Sprintf("request to %s via %s failed [%s]: %v", t, proxy, id, err))
+	}
+}
+
+// flushFailures logs the summaries of repeated errors whose window has ended.
+func (w *Worker) flushFailures() {
+	for _, s := range w.errlog.flush(time.Now()) {
+		w.werr(s)
+	}
+}
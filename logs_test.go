@@ -0,0 +1,147 @@
+package httptines
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/url"
+	"strconv"
+	"syscall"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Logs", func() {
+	Describe("logRing", func() {
+		var r *logRing
+
+		BeforeEach(func() {
+			r = &logRing{}
+		})
+
+		It("returns messages from oldest to newest", func() {
+			r.add(message{data: []byte("1")})
+			r.add(message{data: []byte("2")})
+
+			Expect(r.recent()).To(Equal([]message{{data: []byte("1")}, {data: []byte("2")}}))
+		})
+
+		It("keeps only the most recent messages", func() {
+			for i := range logHistorySize + 5 {
+				r.add(message{data: []byte(strconv.Itoa(i))})
+			}
+
+			lines := r.recent()
+			Expect(lines).To(HaveLen(logHistorySize))
+			Expect(string(lines[0].data)).To(Equal("5"))
+			Expect(string(lines[logHistorySize-1].data)).To(Equal(strconv.Itoa(logHistorySize + 4)))
+		})
+	})
+
+	Describe("accepts()", func() {
+		It("sends everything to unfiltered clients", func() {
+			Expect(message{level: levelInfo}.accepts("")).To(BeTrue())
+		})
+
+		It("sends only errors and other payloads to error-only clients", func() {
+			Expect(message{level: levelInfo}.accepts(levelError)).To(BeFalse())
+			Expect(message{level: levelError}.accepts(levelError)).To(BeTrue())
+			Expect(message{}.accepts(levelError)).To(BeTrue())
+		})
+	})
+
+	Describe("dedupLog", func() {
+		var (
+			d   *dedupLog
+			now time.Time
+		)
+
+		BeforeEach(func() {
+			d = &dedupLog{}
+			now = time.Now()
+		})
+
+		It("logs the first occurrence only", func() {
+			_, first := d.add("http://1.1.1.1:80", "timeout", "A1", now)
+			Expect(first).To(BeTrue())
+
+			_, first = d.add("http://1.1.1.1:80", "timeout", "A1", now.Add(time.Second))
+			Expect(first).To(BeFalse())
+
+			_, first = d.add("http://2.2.2.2:80", "timeout", "A1", now.Add(time.Second))
+			Expect(first).To(BeTrue())
+		})
+
+		It("summarizes repetitions once the window ends", func() {
+			d.add("http://1.1.1.1:80", "timeout", "A1", now)
+			d.add("http://1.1.1.1:80", "timeout", "A1", now)
+			d.add("http://1.1.1.1:80", "timeout", "A1", now)
+			d.add("http://2.2.2.2:80", "timeout", "A1", now)
+
+			Expect(d.flush(now)).To(BeEmpty())
+			Expect(d.flush(now.Add(dedupWindow))).To(Equal([]string{
+				`error "timeout" via http://1.1.1.1:80 occurred 3 times in the last minute [A1 A1]`,
+			}))
+			Expect(d.seen).To(BeEmpty())
+		})
+
+		It("lists a limited number of attempt IDs", func() {
+			for i := range dedupIDs + 3 {
+				d.add("http://1.1.1.1:80", "timeout", strconv.Itoa(i), now)
+			}
+
+			summaries := d.flush(now.Add(dedupWindow))
+			Expect(summaries).To(HaveLen(1))
+			Expect(summaries[0]).To(ContainSubstring("[1 2 3 "))
+			Expect(summaries[0]).To(HaveSuffix(" 20 and 2 more]"))
+		})
+
+		It("starts a new window after the previous one ended", func() {
+			d.add("http://1.1.1.1:80", "timeout", "A1", now)
+			d.add("http://1.1.1.1:80", "timeout", "A1", now)
+
+			summaries, first := d.add("http://1.1.1.1:80", "timeout", "A1", now.Add(dedupWindow))
+			Expect(first).To(BeTrue())
+			Expect(summaries).To(HaveLen(1))
+		})
+	})
+
+	Describe("errorClass()", func() {
+		It("strips the target from request errors", func() {
+			err := &url.Error{Op: "Get", URL: "http://test1.com", Err: errors.New("proxyconnect tcp: dial failed")}
+			Expect(errorClass(err)).To(Equal("proxyconnect tcp: dial failed"))
+		})
+
+		It("recognizes timeouts", func() {
+			err := &url.Error{Op: "Get", URL: "http://test1.com", Err: context.DeadlineExceeded}
+			Expect(errorClass(err)).To(Equal("timeout"))
+		})
+
+		It("recognizes refused connections", func() {
+			err := &url.Error{Op: "Get", URL: "http://test1.com", Err: syscall.ECONNREFUSED}
+			Expect(errorClass(err)).To(Equal("connection refused"))
+		})
+	})
+
+	Describe("wlog()", func() {
+		It("writes to the worker's Logger with its namespace", func() {
+			var buf bytes.Buffer
+			w := &Worker{Logger: &buf, Namespace: "shop"}
+
+			w.wlog("hello")
+			Expect(buf.String()).To(HaveSuffix(" [shop] hello\n"))
+		})
+
+		It("keeps the output of concurrent workers apart", func() {
+			var a, b bytes.Buffer
+			wa, wb := &Worker{Logger: &a, Namespace: "a"}, &Worker{Logger: &b, Namespace: "b"}
+
+			wa.wlog("first")
+			wb.werr("second")
+			Expect(a.String()).To(HaveSuffix(" [a] first\n"))
+			Expect(b.String()).To(HaveSuffix(" [b] second\n"))
+		})
+	})
+})
@@ -0,0 +1,60 @@
+package httptines
+
+// Next passes the result to the next middleware or, at the end of the chain, to the handler.
+type Next func(Result)
+
+// Use appends middleware to the handler chain. Middleware are called in the order
+// they were added, each receiving the result and the next step of the chain. A
+// middleware may modify the result before passing it on, or drop it by not calling next.
+// Parameters:
+//   - middleware: Middleware to append
+func (w *Worker) Use(middleware ...func(Result, Next)) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.pipeline = append(w.pipeline, middleware...)
+}
+
+// chain wraps the handler with the worker's middleware.
+// Parameters:
+//   - handler: Handler at the end of the chain
+//
+// Returns:
+//   - func(Result): Handler calling the middleware first
+func (w *Worker) chain(handler func(Result)) func(Result) {
+	w.m.RLock()
+	defer w.m.RUnlock()
+
+	for i := len(w.pipeline) - 1; i >= 0; i-- {
+		mw, next := w.pipeline[i], handler
+		handler = func(r Result) { mw(r, next) }
+	}
+	return handler
+}
+
+// compose builds the function receiving the processed results: the middleware chain
+// ending with the handler, the sink and the subscribers, so they all get the same
+// results. With Ordered, the results pass the orderer first.
+// Parameters:
+//   - targets: Targets in their original order
+//   - handler: Callback function to process the result
+//
+// Returns:
+//   - func(Result): Function receiving the processed results
+func (w *Worker) compose(targets []string, handler func(Result)) func(Result) {
+	handle := handler
+	handler = w.chain(func(r Result) {
+		handle(r)
+		if w.languageAccepted(r) {
+			w.sink(r)
+		}
+		w.results.publish(r)
+	})
+
+	w.order = nil
+	if w.Ordered {
+		w.order = newOrderer(targets, w.OrderBuffer, handler)
+		handler = w.order.deliver
+	}
+	return handler
+}
@@ -0,0 +1,88 @@
+package httptines
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Middleware", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{}
+	})
+
+	Describe("chain()", func() {
+		It("calls the handler without middleware", func() {
+			var got string
+			w.chain(func(r Result) { got = r.URL })(Result{URL: "http://test1.com"})
+
+			Expect(got).To(Equal("http://test1.com"))
+		})
+
+		It("calls middleware in the order they were added", func() {
+			var calls []string
+			w.Use(func(r Result, next Next) {
+				calls = append(calls, "decompress")
+				r.Body = []byte(strings.ToUpper(string(r.Body)))
+				next(r)
+			})
+			w.Use(func(r Result, next Next) {
+				calls = append(calls, "parse")
+				next(r)
+			})
+
+			var body string
+			w.chain(func(r Result) {
+				calls = append(calls, "store")
+				body = string(r.Body)
+			})(Result{Body: []byte("good")})
+
+			Expect(calls).To(Equal([]string{"decompress", "parse", "store"}))
+			Expect(body).To(Equal("GOOD"))
+		})
+
+		It("drops results not passed on", func() {
+			w.Use(func(r Result, next Next) {
+				if r.Status == 200 {
+					next(r)
+				}
+			})
+
+			var handled []int
+			h := w.chain(func(r Result) { handled = append(handled, r.Status) })
+			h(Result{Status: 200})
+			h(Result{Status: 404})
+
+			Expect(handled).To(Equal([]int{200}))
+		})
+	})
+
+	Describe("compose()", func() {
+		It("sinks the handled results in the target order", func() {
+			var handled, sunk []string
+			w.Ordered = true
+			w.Sink = SinkFunc(func(_ context.Context, r Result) error {
+				sunk = append(sunk, r.URL)
+				return nil
+			})
+			w.Use(func(r Result, next Next) {
+				if r.Status == 200 {
+					next(r)
+				}
+			})
+
+			h := w.compose([]string{"a", "b", "c"}, func(r Result) { handled = append(handled, r.URL) })
+			h(Result{URL: "c", Status: 200})
+			h(Result{URL: "b", Status: 404})
+			Expect(sunk).To(BeEmpty())
+
+			h(Result{URL: "a", Status: 200})
+			Expect(handled).To(Equal([]string{"a", "c"}))
+			Expect(sunk).To(Equal([]string{"a", "c"}))
+		})
+	})
+})
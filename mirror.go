@@ -0,0 +1,122 @@
+package httptines
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// MirrorStat counts mirrored requests and how often their responses differed.
+type MirrorStat struct {
+	// Compared is the number of targets fetched twice and compared
+	Compared int `json:"compared"`
+	// Mismatched is the number of comparisons with a different status or body
+	Mismatched int `json:"mismatched"`
+}
+
+// sampled decides whether a processed target is mirrored.
+// Returns:
+//   - bool: True for MirrorRate percent of the calls
+func (w *Worker) sampled() bool {
+	return w.MirrorRate > 0 && rand.Intn(100) < w.MirrorRate
+}
+
+// mirrorable reports whether targets with the method may be fetched again to be
+// compared. Mirrors are plain GET requests, so other methods aren't repeated.
+// Parameters:
+//   - method: HTTP method of the target
+//
+// Returns:
+//   - bool: True for GET targets
+func mirrorable(method string) bool {
+	return method == "" || strings.EqualFold(method, http.MethodGet)
+}
+
+// mirror fetches the target again through the baseline and compares the responses.
+// Mirrors count against HostRate and MaxConcurrency like target requests, and
+// targets whose host has no token left aren't mirrored.
+// Parameters:
+//   - t: Target URL
+//   - id: ID of the mirrored attempt
+//   - s: Server that processed the target
+//   - status: Status code of the original response
+//   - body: Body of the original response
+func (w *Worker) mirror(t, id string, s *Server, status int, body []byte) {
+	baseline := w.mirrorBaseline(s)
+	if baseline == nil || w.throttle(t, time.Now()) > 0 {
+		return
+	}
+
+	w.limiter.acquire(false)
+	defer w.limiter.release()
+
+	host, sni := w.hostNames(w.target(t))
+	rep, err := request(w.requestContext(), t, baseline, reqOpts{
+		agent:   w.scrapeAgent(),
+		host:    host,
+		sni:     sni,
+		maxHops: w.MaxRedirects,
+	})
+	w.usage.add(t, rep.sent+rep.received)
+	if err != nil {
+		return
+	}
+
+	mismatch := rep.status != status || sha256.Sum256(rep.body) != sha256.Sum256(body)
+
+	w.stat.m.Lock()
+	w.stat.Mirrors.Compared++
+	if mismatch {
+		w.stat.Mirrors.Mismatched++
+	}
+	w.stat.m.Unlock()
+
+	if mismatch {
+		name := "direct"
+		if baseline.URL != nil {
+			name = baseline.name()
+		}
+		w.werr(fmt.Sprintf("mirror mismatch for %s [%s]: %s returned %d (%d bytes), %s returned %d (%d bytes)",
+			t, id, s.name(), status, len(body), name, rep.status, len(rep.body)))
+	}
+}
+
+// mirrorBaseline returns the server used to mirror a request made through s.
+// Parameters:
+//   - s: Server that processed the target
+//
+// Returns:
+//   - *Server: Another alive proxy or a direct connection, nil if none is available
+func (w *Worker) mirrorBaseline(s *Server) *Server {
+	if w.MirrorBaseline == "direct" {
+		return &Server{
+			timeout:  w.requestTimeout(),
+			maxBody:  w.MaxBodySize,
+			maxRatio: w.MaxCompressionRatio,
+		}
+	}
+
+	return w.servers.other(s)
+}
+
+// other returns an enabled registered server other than s.
+// Parameters:
+//   - s: Server to exclude
+//
+// Returns:
+//   - *Server: Another server, nil if there is none
+func (r *registry) other(s *Server) *Server {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	for _, o := range r.servers {
+		if o != s && atomic.LoadUint32(&o.Disabled) == 0 {
+			return o
+		}
+	}
+	return nil
+}
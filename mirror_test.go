@@ -0,0 +1,85 @@
+package httptines
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Mirror", func() {
+	var (
+		w      *Worker
+		target *httptest.Server
+		srv    *Server
+	)
+
+	BeforeEach(func() {
+		target = mockHTTPServer("ok")
+		u, _ := url.Parse("http://1.2.3.4:8080")
+		srv = &Server{URL: u}
+		w = &Worker{Timeout: 1, MirrorBaseline: "direct", stat: &Stat{}}
+	})
+
+	AfterEach(func() {
+		target.Close()
+	})
+
+	Describe("mirror()", func() {
+		It("counts matching responses", func() {
+			w.mirror(target.URL, "A1", srv, 200, []byte("ok"))
+			Expect(w.stat.Mirrors).To(Equal(MirrorStat{Compared: 1}))
+		})
+
+		It("counts altered responses", func() {
+			w.mirror(target.URL, "A1", srv, 200, []byte("injected"))
+			Expect(w.stat.Mirrors).To(Equal(MirrorStat{Compared: 1, Mismatched: 1}))
+		})
+
+		It("doesn't exceed the host rate", func() {
+			w.HostRate = 1
+			w.mirror(target.URL, "A1", srv, 200, []byte("ok"))
+			w.mirror(target.URL, "A2", srv, 200, []byte("ok"))
+			Expect(w.stat.Mirrors).To(Equal(MirrorStat{Compared: 1}))
+		})
+	})
+
+	Describe("mirrorable()", func() {
+		It("only mirrors GET targets", func() {
+			Expect(mirrorable("")).To(BeTrue())
+			Expect(mirrorable("get")).To(BeTrue())
+			Expect(mirrorable(http.MethodPost)).To(BeFalse())
+			Expect(mirrorable(http.MethodHead)).To(BeFalse())
+		})
+	})
+
+	Describe("mirrorBaseline()", func() {
+		BeforeEach(func() {
+			w.MirrorBaseline = "proxy"
+		})
+
+		It("picks another proxy", func() {
+			u, _ := url.Parse("http://5.6.7.8:3128")
+			other := &Server{URL: u}
+			w.servers.add(srv)
+			w.servers.add(other)
+
+			Expect(w.mirrorBaseline(srv)).To(BeIdenticalTo(other))
+		})
+
+		It("returns nil without another proxy", func() {
+			w.servers.add(srv)
+			Expect(w.mirrorBaseline(srv)).To(BeNil())
+		})
+	})
+
+	Describe("sampled()", func() {
+		It("respects the rate", func() {
+			Expect(w.sampled()).To(BeFalse())
+			w.MirrorRate = 100
+			Expect(w.sampled()).To(BeTrue())
+		})
+	})
+})
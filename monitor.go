@@ -0,0 +1,233 @@
+package httptines
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Orders of revisited targets.
+const (
+	revisitFIFO = "fifo"
+	revisitCost = "cost"
+)
+
+// TargetCost is the history of a target's visits, averaged with more weight on recent visits.
+type TargetCost struct {
+	// Latency is the average latency of successful requests
+	Latency time.Duration
+	// Bytes is the average traffic of successful requests
+	Bytes int64
+	// Attempts is the average number of attempts per visit
+	Attempts float64
+	// Visits is the number of successful visits
+	Visits int
+}
+
+// defaultCost estimates the proxy time a visit takes: the latency times the number of attempts.
+// Parameters:
+//   - c: Visit history
+//
+// Returns:
+//   - float64: Cost in milliseconds
+func defaultCost(c TargetCost) float64 {
+	return float64(c.Latency.Milliseconds()) * c.Attempts
+}
+
+// TargetStatus represents the outcome of the last attempt to process a target.
+type TargetStatus struct {
+	// Success indicates whether the last attempt succeeded
+	Success bool `json:"success"`
+	// Error contains the error message of the last failed attempt
+	Error string `json:"error,omitempty"`
+	// CheckedAt is the time of the last attempt
+	CheckedAt time.Time `json:"checkedAt"`
+	// AttemptID identifies the last attempt
+	AttemptID string `json:"attemptId,omitempty"`
+}
+
+// Statuses returns a copy of the last known status of every processed target.
+// Returns:
+//   - map[string]TargetStatus: Statuses keyed by target URL
+func (w *Worker) Statuses() map[string]TargetStatus {
+	w.m.RLock()
+	defer w.m.RUnlock()
+
+	statuses := make(map[string]TargetStatus, len(w.statuses))
+	for t, st := range w.statuses {
+		statuses[t] = st
+	}
+	return statuses
+}
+
+// track records the outcome of an attempt to process a target.
+// Parameters:
+//   - t: Target URL
+//   - id: Attempt ID
+//   - err: Error returned by the attempt, nil on success
+func (w *Worker) track(t, id string, err error) {
+	st := TargetStatus{Success: err == nil, CheckedAt: time.Now(), AttemptID: id}
+	if err != nil {
+		st.Error = err.Error()
+	}
+
+	w.m.Lock()
+	if w.statuses == nil {
+		w.statuses = map[string]TargetStatus{}
+	}
+	w.statuses[t] = st
+	w.m.Unlock()
+}
+
+// revisitTimers keeps the scheduled revisits, so they can be cancelled on shutdown.
+type revisitTimers struct {
+	m      sync.Mutex
+	timers map[*time.Timer]struct{}
+}
+
+// schedule calls f after d unless the timers are stopped first.
+// Parameters:
+//   - d: Delay
+//   - f: Function to call
+func (r *revisitTimers) schedule(d time.Duration, f func()) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.timers == nil {
+		r.timers = map[*time.Timer]struct{}{}
+	}
+
+	var timer *time.Timer
+	timer = time.AfterFunc(d, func() {
+		r.m.Lock()
+		_, pending := r.timers[timer]
+		delete(r.timers, timer)
+		r.m.Unlock()
+
+		if pending {
+			f()
+		}
+	})
+	r.timers[timer] = struct{}{}
+}
+
+// stop cancels all scheduled revisits.
+func (r *revisitTimers) stop() {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	for timer := range r.timers {
+		timer.Stop()
+	}
+	r.timers = nil
+}
+
+// revisit schedules a processed target for another visit when monitoring mode is enabled.
+// Revisits still scheduled when the worker stops are cancelled.
+// Parameters:
+//   - t: Target URL
+func (w *Worker) revisit(t string) {
+	if w.Revisit <= 0 || w.stopped() {
+		return
+	}
+
+	w.revisits.schedule(time.Duration(w.Revisit)*time.Second, func() {
+		if w.stopped() {
+			return
+		}
+		if w.RevisitOrder == revisitCost {
+			w.requeueByCost(t)
+			return
+		}
+		w.retrigger(t)
+	})
+}
+
+// recordCost adds a successful visit to the target's history when it is revisited.
+// Parameters:
+//   - t: Target URL
+//   - latency: Latency of the successful request
+//   - bytes: Bytes sent and received by the successful request
+//   - attempts: Number of attempts of the visit
+func (w *Worker) recordCost(t string, latency time.Duration, bytes int64, attempts int) {
+	if w.Revisit <= 0 {
+		return
+	}
+
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.costs == nil {
+		w.costs = map[string]TargetCost{}
+	}
+	c, ok := w.costs[t]
+	if !ok {
+		c = TargetCost{Latency: latency, Bytes: bytes, Attempts: float64(attempts)}
+	} else {
+		c.Latency += time.Duration(latencyWeight * float64(latency-c.Latency))
+		c.Bytes += int64(latencyWeight * float64(bytes-c.Bytes))
+		c.Attempts += latencyWeight * (float64(attempts) - c.Attempts)
+	}
+	c.Visits++
+	w.costs[t] = c
+}
+
+// cost returns the estimated cost of a target's next visit.
+// Parameters:
+//   - c: Visit history of the target
+//
+// Returns:
+//   - float64: Cost
+func (w *Worker) cost(c TargetCost) float64 {
+	if w.RevisitCost != nil {
+		return w.RevisitCost(c)
+	}
+	return defaultCost(c)
+}
+
+// requeueByCost puts a revisited target back into the queue ahead of the more
+// expensive targets, so cheap targets don't wait behind slow ones.
+// Parameters:
+//   - t: Target URL
+func (w *Worker) requeueByCost(t string) {
+	w.m.RLock()
+	hist := map[string]TargetCost{}
+	for _, q := range append([]string{t}, w.targets...) {
+		if c, ok := w.costs[q]; ok {
+			hist[q] = c
+		}
+	}
+	w.m.RUnlock()
+
+	// RevisitCost is user code, so it isn't called while the queue is locked.
+	// Targets queued meanwhile count as unvisited.
+	costs := make(map[string]float64, len(hist))
+	for q, c := range hist {
+		costs[q] = w.cost(c)
+	}
+
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	c := costs[t]
+	i := slices.IndexFunc(w.targets, func(q string) bool { return costs[q] > c })
+	if i < 0 {
+		i = len(w.targets)
+	}
+	w.targets = slices.Insert(w.targets, i, t)
+	w.recordQueue(true, t)
+}
+
+// Costs returns a copy of the visit history of every revisited target.
+// Returns:
+//   - map[string]TargetCost: Histories keyed by target URL
+func (w *Worker) Costs() map[string]TargetCost {
+	w.m.RLock()
+	defer w.m.RUnlock()
+
+	costs := make(map[string]TargetCost, len(w.costs))
+	for t, c := range w.costs {
+		costs[t] = c
+	}
+	return costs
+}
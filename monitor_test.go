@@ -0,0 +1,127 @@
+package httptines
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Monitor", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{}
+	})
+
+	Describe("track()", func() {
+		It("records a successful attempt", func() {
+			w.track("http://test1.com", "A1", nil)
+
+			st := w.Statuses()["http://test1.com"]
+			Expect(st.Success).To(BeTrue())
+			Expect(st.Error).To(BeEmpty())
+			Expect(st.CheckedAt).To(BeTemporally("~", time.Now(), time.Second))
+		})
+
+		It("overwrites the previous status", func() {
+			w.track("http://test1.com", "A1", nil)
+			w.track("http://test1.com", "A2", errors.New("boom"))
+
+			st := w.Statuses()["http://test1.com"]
+			Expect(st.Success).To(BeFalse())
+			Expect(st.Error).To(Equal("boom"))
+			Expect(st.AttemptID).To(Equal("A2"))
+		})
+	})
+
+	Describe("revisit()", func() {
+		When("monitoring is disabled", func() {
+			It("does not schedule the target", func() {
+				w.revisit("http://test1.com")
+				Consistently(func() []string { return w.shift(1) }, 200*time.Millisecond).Should(BeEmpty())
+			})
+		})
+
+		It("schedules the target after the interval", func() {
+			w.Revisit = 1
+			w.revisit("http://test1.com")
+
+			Expect(w.shift(1)).To(BeEmpty())
+			Eventually(func() []string { return w.shift(1) }, 2*time.Second).Should(Equal([]string{"http://test1.com"}))
+		})
+
+		It("cancels scheduled revisits on shutdown", func() {
+			w.Revisit = 1
+			w.revisit("http://test1.com")
+			w.revisits.stop()
+
+			Consistently(func() []string { return w.shift(1) }, 1500*time.Millisecond).Should(BeEmpty())
+		})
+
+		It("doesn't requeue targets after the worker stopped", func() {
+			w.Revisit = 1
+			var cancel context.CancelFunc
+			w.ctx, cancel = context.WithCancel(context.Background())
+			w.revisit("http://test1.com")
+			cancel()
+
+			Consistently(func() []string { return w.shift(1) }, 1500*time.Millisecond).Should(BeEmpty())
+		})
+	})
+
+	Describe("recordCost()", func() {
+		It("averages the visits of revisited targets", func() {
+			w.Revisit = 60
+			w.recordCost("http://test1.com", 100*time.Millisecond, 1000, 1)
+			w.recordCost("http://test1.com", 200*time.Millisecond, 2000, 3)
+
+			Expect(w.Costs()).To(Equal(map[string]TargetCost{
+				"http://test1.com": {Latency: 130 * time.Millisecond, Bytes: 1300, Attempts: 1.6, Visits: 2},
+			}))
+		})
+
+		It("doesn't keep a history without monitoring", func() {
+			w.recordCost("http://test1.com", time.Second, 1000, 1)
+			Expect(w.Costs()).To(BeEmpty())
+		})
+	})
+
+	Describe("requeueByCost()", func() {
+		BeforeEach(func() {
+			w.Revisit = 60
+			w.recordCost("http://slow.com", 2*time.Second, 0, 1)
+			w.recordCost("http://flaky.com", 500*time.Millisecond, 0, 3)
+			w.recordCost("http://fast.com", 100*time.Millisecond, 0, 1)
+			w.targets = []string{"http://new.com", "http://flaky.com", "http://slow.com"}
+		})
+
+		It("puts the target ahead of more expensive ones", func() {
+			w.requeueByCost("http://fast.com")
+			Expect(w.targets).To(Equal([]string{"http://new.com", "http://fast.com", "http://flaky.com", "http://slow.com"}))
+		})
+
+		It("puts the most expensive target at the end", func() {
+			w.recordCost("http://huge.com", 10*time.Second, 0, 1)
+			w.requeueByCost("http://huge.com")
+			Expect(w.targets[3]).To(Equal("http://huge.com"))
+		})
+
+		It("doesn't call RevisitCost while the queue is locked", func() {
+			w.RevisitCost = func(c TargetCost) float64 {
+				w.Add("http://added.com")
+				return float64(c.Visits)
+			}
+			w.requeueByCost("http://fast.com")
+			Expect(w.targets).To(ContainElements("http://fast.com", "http://added.com"))
+		})
+
+		It("estimates the cost with RevisitCost", func() {
+			w.RevisitCost = func(c TargetCost) float64 { return float64(c.Visits) }
+			w.requeueByCost("http://fast.com")
+			Expect(w.targets[len(w.targets)-1]).To(Equal("http://fast.com"))
+		})
+	})
+})
@@ -0,0 +1,174 @@
+package httptines
+
+import (
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Option configures a worker created with New.
+type Option func(*Worker) error
+
+// New creates a worker configured by the options. Defaults are applied and the
+// configuration is checked right away, so mistakes are reported before Run.
+// Workers can still be created as struct literals.
+// Parameters:
+//   - opts: Options applied in order
+//
+// Returns:
+//   - *Worker: Configured worker
+//   - error: Errors of the options and the configuration, joined
+func New(opts ...Option) (*Worker, error) {
+	w := &Worker{}
+
+	var errs []error
+	for _, opt := range opts {
+		if err := opt(w); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	w.Default()
+	errs = append(errs, w.configErrors()...)
+
+	return w, errors.Join(errs...)
+}
+
+// WithSources sets the proxy source URLs grouped by schema.
+// Parameters:
+//   - sources: Source URLs keyed by schema (http/https/socks4/socks5)
+//
+// Returns:
+//   - Option: Option setting Sources
+func WithSources(sources map[string][]string) Option {
+	return func(w *Worker) error {
+		w.Sources = sources
+		return nil
+	}
+}
+
+// WithProxies sets a fixed list of proxies.
+// Parameters:
+//   - proxies: Proxy entries, e.g. "socks5://1.2.3.4:1080"
+//
+// Returns:
+//   - Option: Option setting Proxies
+func WithProxies(proxies ...string) Option {
+	return func(w *Worker) error {
+		w.Proxies = proxies
+		return nil
+	}
+}
+
+// WithTestTarget sets the URL used for checking proxies.
+// Parameters:
+//   - target: Test URL
+//
+// Returns:
+//   - Option: Option setting TestTarget
+func WithTestTarget(target string) Option {
+	return func(w *Worker) error {
+		w.TestTarget = target
+		return nil
+	}
+}
+
+// WithTimeout sets the request timeout.
+// Parameters:
+//   - seconds: Timeout in seconds
+//
+// Returns:
+//   - Option: Option setting Timeout
+func WithTimeout(seconds int) Option {
+	return func(w *Worker) error {
+		if seconds <= 0 {
+			return fmt.Errorf("invalid timeout %d", seconds)
+		}
+		w.Timeout = seconds
+		return nil
+	}
+}
+
+// WithWorkers sets the number of proxy servers processing targets at the same time.
+// Parameters:
+//   - n: Number of workers
+//
+// Returns:
+//   - Option: Option setting Workers
+func WithWorkers(n int) Option {
+	return func(w *Worker) error {
+		if n <= 0 {
+			return fmt.Errorf("invalid number of workers %d", n)
+		}
+		w.Workers = n
+		return nil
+	}
+}
+
+// WithStrategy sets the load balancing strategy.
+// Parameters:
+//   - strategy: "minimal" or "auto"
+//
+// Returns:
+//   - Option: Option setting Strategy
+func WithStrategy(strategy string) Option {
+	return func(w *Worker) error {
+		w.Strategy = strategy
+		return nil
+	}
+}
+
+// WithPort sets the port of the web interface.
+// Parameters:
+//   - port: Port number
+//
+// Returns:
+//   - Option: Option setting Port
+func WithPort(port int) Option {
+	return func(w *Worker) error {
+		if port <= 0 || port > 65535 {
+			return fmt.Errorf("invalid port %d", port)
+		}
+		w.Port = port
+		return nil
+	}
+}
+
+// WithSink sets the sink receiving every processed result.
+// Parameters:
+//   - sink: Sink
+//
+// Returns:
+//   - Option: Option setting Sink
+func WithSink(sink Sink) Option {
+	return func(w *Worker) error {
+		w.Sink = sink
+		return nil
+	}
+}
+
+// WithLogger sets the writer receiving the log lines.
+// Parameters:
+//   - logger: Log writer
+//
+// Returns:
+//   - Option: Option setting Logger
+func WithLogger(logger io.Writer) Option {
+	return func(w *Worker) error {
+		w.Logger = logger
+		return nil
+	}
+}
+
+// WithNamespace sets the run label prefixed to logs and included in statistics.
+// Parameters:
+//   - namespace: Run label
+//
+// Returns:
+//   - Option: Option setting Namespace
+func WithNamespace(namespace string) Option {
+	return func(w *Worker) error {
+		w.Namespace = namespace
+		return nil
+	}
+}
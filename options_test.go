@@ -0,0 +1,60 @@
+package httptines
+
+import (
+	"bytes"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Options", func() {
+	Describe("New()", func() {
+		It("applies the options and the defaults", func() {
+			var buf bytes.Buffer
+			sink := &JSONLSink{Path: "results.jsonl"}
+
+			w, err := New(
+				WithSources(map[string][]string{"http": {"http://example.com/list.txt"}}),
+				WithTestTarget("http://example.com"),
+				WithTimeout(5),
+				WithWorkers(10),
+				WithStrategy("auto"),
+				WithPort(9090),
+				WithSink(sink),
+				WithLogger(&buf),
+				WithNamespace("shop-a"),
+			)
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(w.Timeout).To(Equal(5))
+			Expect(w.Workers).To(Equal(10))
+			Expect(w.Strategy).To(Equal("auto"))
+			Expect(w.Port).To(Equal(9090))
+			Expect(w.Sink).To(BeIdenticalTo(sink))
+			Expect(w.Logger).To(BeIdenticalTo(&buf))
+			Expect(w.Namespace).To(Equal("shop-a"))
+			Expect(w.Interval).To(Equal(300))
+		})
+
+		It("accepts static proxies instead of sources", func() {
+			_, err := New(WithProxies("1.2.3.4:8080"), WithTestTarget("http://example.com"))
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns configuration errors eagerly", func() {
+			w, err := New(WithTestTarget("http://example.com"), WithStrategy("fastest"))
+
+			Expect(w).NotTo(BeNil())
+			Expect(err).To(MatchError(ContainSubstring("Sources")))
+			Expect(err).To(MatchError(ContainSubstring(`unknown Strategy "fastest"`)))
+		})
+
+		It("returns option errors", func() {
+			_, err := New(WithTimeout(0), WithWorkers(-1), WithPort(70000))
+
+			Expect(err).To(MatchError(ContainSubstring("invalid timeout 0")))
+			Expect(err).To(MatchError(ContainSubstring("invalid number of workers -1")))
+			Expect(err).To(MatchError(ContainSubstring("invalid port 70000")))
+		})
+	})
+})
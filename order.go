@@ -0,0 +1,161 @@
+package httptines
+
+import (
+	"maps"
+	"slices"
+	"sync"
+)
+
+// orderer buffers results and delivers them in the original target order. A single
+// goroutine at a time passes the results whose turn has come to the handler, outside
+// of the lock, while the others only buffer theirs.
+type orderer struct {
+	m        sync.Mutex
+	room     sync.Cond        // Signalled when buffered results are delivered
+	handler  func(Result)     // Callback function receiving the ordered results
+	limit    int              // Maximum number of buffered results, 0 means unlimited
+	next     int              // Index of the next result to deliver
+	pending  map[string][]int // Undelivered indexes keyed by target URL
+	buf      map[int]Result   // Completed results waiting for their turn
+	skipped  map[int]bool     // Indexes of targets finished without a result
+	flushing bool             // Whether a goroutine is delivering the buffered results
+}
+
+// newOrderer creates an orderer for the given targets.
+// Parameters:
+//   - targets: Targets in their original order
+//   - limit: Maximum number of buffered results, 0 means unlimited
+//   - handler: Callback function to process the results
+//
+// Returns:
+//   - *orderer: Orderer expecting results for the targets
+func newOrderer(targets []string, limit int, handler func(Result)) *orderer {
+	o := &orderer{handler: handler, limit: limit, pending: map[string][]int{}, buf: map[int]Result{}, skipped: map[int]bool{}}
+	o.room.L = &o.m
+	for i, t := range targets {
+		o.pending[t] = append(o.pending[t], i)
+	}
+	return o
+}
+
+// deliver passes the result to the handler once all preceding results have been delivered.
+// Results for targets that weren't part of the original list, or whose turn was given up,
+// are delivered immediately. While the buffer is full and its results are being delivered,
+// deliver waits for room. If the buffer overflows while the next result is missing, the
+// targets holding it up lose their turn, so a slow target can't hold back all the results.
+// Parameters:
+//   - r: Completed result
+func (o *orderer) deliver(r Result) {
+	o.m.Lock()
+
+	key := r.key
+	if key == "" {
+		key = r.URL
+	}
+	idx, ok := o.take(key)
+	if !ok || idx < o.next {
+		o.m.Unlock()
+		o.handler(r)
+		return
+	}
+
+	for o.limit > 0 && len(o.buf) >= o.limit && o.flushing {
+		o.room.Wait()
+	}
+	o.buf[idx] = r
+	if _, ready := o.buf[o.next]; !ready && o.limit > 0 && len(o.buf) > o.limit {
+		o.giveUp()
+	}
+	o.m.Unlock()
+
+	o.flush()
+}
+
+// skip gives up the turn of a target finished without a result, e.g. abandoned
+// after its retries or finished by another process, so later results aren't held.
+// Nothing happens if the orderer is nil.
+// Parameters:
+//   - u: Target URL
+func (o *orderer) skip(u string) {
+	if o == nil {
+		return
+	}
+
+	o.m.Lock()
+	idx, ok := o.take(u)
+	if ok && idx >= o.next {
+		o.skipped[idx] = true
+	}
+	o.m.Unlock()
+
+	if ok {
+		o.flush()
+	}
+}
+
+// take removes the first undelivered index of the target. The caller must hold the lock.
+// Parameters:
+//   - u: Target URL
+//
+// Returns:
+//   - int: Index of the target in the original list
+//   - bool: False if the target has no undelivered index
+func (o *orderer) take(u string) (int, bool) {
+	idxs := o.pending[u]
+	if len(idxs) == 0 {
+		return 0, false
+	}
+
+	if len(idxs) == 1 {
+		delete(o.pending, u)
+	} else {
+		o.pending[u] = idxs[1:]
+	}
+	return idxs[0], true
+}
+
+// giveUp moves the turn to the first buffered result. The results of the targets
+// passed over are delivered as soon as they complete. The caller must hold the lock.
+func (o *orderer) giveUp() {
+	o.next = slices.Min(slices.Collect(maps.Keys(o.buf)))
+	for idx := range o.skipped {
+		if idx < o.next {
+			delete(o.skipped, idx)
+		}
+	}
+}
+
+// flush delivers the buffered results whose turn has come, unless another goroutine
+// is delivering them already. The handler is called without holding the lock.
+func (o *orderer) flush() {
+	o.m.Lock()
+	if o.flushing {
+		o.m.Unlock()
+		return
+	}
+	o.flushing = true
+
+	for {
+		if o.skipped[o.next] {
+			delete(o.skipped, o.next)
+			o.next++
+			continue
+		}
+
+		r, ok := o.buf[o.next]
+		if !ok {
+			break
+		}
+		delete(o.buf, o.next)
+		o.next++
+		o.room.Broadcast()
+
+		o.m.Unlock()
+		o.handler(r)
+		o.m.Lock()
+	}
+
+	o.flushing = false
+	o.room.Broadcast()
+	o.m.Unlock()
+}
@@ -0,0 +1,92 @@
+package httptines
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Order", func() {
+	var (
+		o      *orderer
+		result []string
+	)
+
+	BeforeEach(func() {
+		result = nil
+		o = newOrderer([]string{"http://test1.com", "http://test2.com", "http://test1.com", "http://test3.com"}, 0, func(r Result) {
+			result = append(result, r.URL)
+		})
+	})
+
+	Describe("deliver()", func() {
+		It("delivers results in the original order", func() {
+			o.deliver(Result{URL: "http://test3.com"})
+			o.deliver(Result{URL: "http://test2.com"})
+			Expect(result).To(BeEmpty())
+
+			o.deliver(Result{URL: "http://test1.com"})
+			Expect(result).To(Equal([]string{"http://test1.com", "http://test2.com"}))
+
+			o.deliver(Result{URL: "http://test1.com"})
+			Expect(result).To(Equal([]string{"http://test1.com", "http://test2.com", "http://test1.com", "http://test3.com"}))
+		})
+
+		It("delivers unknown targets immediately", func() {
+			o.deliver(Result{URL: "http://other.com"})
+			Expect(result).To(Equal([]string{"http://other.com"}))
+		})
+	})
+
+	Describe("limit", func() {
+		BeforeEach(func() {
+			o.limit = 1
+		})
+
+		It("gives up the turn of the targets holding an overflowing buffer", func() {
+			o.deliver(Result{URL: "http://test2.com"})
+			Expect(result).To(BeEmpty())
+
+			o.deliver(Result{URL: "http://test3.com"})
+			Expect(result).To(Equal([]string{"http://test2.com"}))
+
+			o.deliver(Result{URL: "http://test1.com"})
+			Expect(result).To(Equal([]string{"http://test2.com", "http://test1.com"}))
+			Expect(o.buf).To(HaveLen(1))
+
+			o.deliver(Result{URL: "http://test1.com"})
+			Expect(result).To(Equal([]string{"http://test2.com", "http://test1.com", "http://test1.com", "http://test3.com"}))
+			Expect(o.buf).To(BeEmpty())
+		})
+	})
+
+	Describe("flush()", func() {
+		It("calls the handler without holding the lock", func() {
+			o.handler = func(r Result) {
+				result = append(result, r.URL)
+				o.skip("http://test2.com")
+			}
+
+			o.deliver(Result{URL: "http://test3.com"})
+			o.deliver(Result{URL: "http://test1.com"})
+			o.deliver(Result{URL: "http://test1.com"})
+			Expect(result).To(Equal([]string{"http://test1.com", "http://test1.com", "http://test3.com"}))
+		})
+	})
+
+	Describe("skip()", func() {
+		It("delivers the results following a skipped target", func() {
+			o.deliver(Result{URL: "http://test3.com"})
+			o.deliver(Result{URL: "http://test1.com"})
+			o.deliver(Result{URL: "http://test1.com"})
+			Expect(result).To(Equal([]string{"http://test1.com"}))
+
+			o.skip("http://test2.com")
+			Expect(result).To(Equal([]string{"http://test1.com", "http://test1.com", "http://test3.com"}))
+		})
+
+		It("does nothing without an orderer", func() {
+			var none *orderer
+			Expect(func() { none.skip("http://test2.com") }).NotTo(Panic())
+		})
+	})
+})
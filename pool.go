@@ -0,0 +1,79 @@
+package httptines
+
+import (
+	"cmp"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// ProxyInfo describes a proxy of the alive pool.
+type ProxyInfo struct {
+	// URL is the proxy URL without credentials, e.g. "http://1.2.3.4:8080"
+	URL string `json:"url"`
+	// Proxy is the proxy URL including credentials. It isn't exposed by the HTTP API.
+	Proxy *url.URL `json:"-"`
+	// Score is the estimated success rate between 0 and 1, smoothed for proxies with few requests.
+	// It includes the requests of previous runs kept in ProxyHistory.
+	Score float64 `json:"score"`
+	// Capacity is the number of concurrent requests the proxy handles
+	Capacity int `json:"capacity"`
+	// Latency is the average latency of successful requests in milliseconds, the check latency before any
+	Latency int `json:"latency"`
+	// Positive is the number of successful requests
+	Positive int `json:"positive"`
+	// Negative is the number of failed requests
+	Negative int `json:"negative"`
+	// Anonymity is the anonymity level, empty if unknown
+	Anonymity string `json:"anonymity,omitempty"`
+	// Country is the ISO 3166 country code, empty if unknown
+	Country string `json:"country,omitempty"`
+	// Cached indicates that the proxy was detected serving cached content
+	Cached bool `json:"cached,omitempty"`
+}
+
+// AliveProxies returns the enabled proxies of the pool, best scores first, so other
+// tools or workers can reuse the validated list without checking it again.
+// Gateway slots aren't included.
+// Returns:
+//   - []ProxyInfo: Alive proxies sorted by score, then latency
+func (w *Worker) AliveProxies() []ProxyInfo {
+	proxies := []ProxyInfo{}
+	for _, s := range w.servers.enabled() {
+		if s.slot > 0 {
+			continue
+		}
+		proxies = append(proxies, s.info())
+	}
+
+	slices.SortFunc(proxies, func(a, b ProxyInfo) int {
+		return cmp.Or(cmp.Compare(b.Score, a.Score), cmp.Compare(a.Latency, b.Latency), strings.Compare(a.URL, b.URL))
+	})
+	return proxies
+}
+
+// info describes the server for the alive pool export.
+// Returns:
+//   - ProxyInfo: Server description
+func (s *Server) info() ProxyInfo {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	latency := s.CheckLatency
+	if s.avgLatency > 0 {
+		latency = int(s.avgLatency)
+	}
+
+	return ProxyInfo{
+		URL:       s.name(),
+		Proxy:     s.URL,
+		Score:     s.score(),
+		Capacity:  s.Capacity,
+		Latency:   latency,
+		Positive:  s.Positive,
+		Negative:  s.Negative,
+		Anonymity: s.Anonymity,
+		Country:   s.Country,
+		Cached:    s.Cached,
+	}
+}
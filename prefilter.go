@@ -0,0 +1,32 @@
+package httptines
+
+import (
+	"context"
+	"net"
+	"net/url"
+	"time"
+)
+
+// prefilter connects to the proxy with PrefilterTimeout, so unreachable hosts
+// are discarded without a full test request.
+// Parameters:
+//   - u: Proxy URL
+//
+// Returns:
+//   - bool: True if the proxy accepts connections or the pre-filter is disabled
+func (w *Worker) prefilter(u *url.URL) bool {
+	if w.PrefilterTimeout <= 0 || w.Transport != nil {
+		return true
+	}
+
+	ctx, cancel := context.WithTimeout(w.requestContext(), time.Duration(w.PrefilterTimeout)*time.Millisecond)
+	defer cancel()
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", u.Host)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
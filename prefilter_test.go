@@ -0,0 +1,59 @@
+package httptines
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Prefilter", func() {
+	var (
+		open   *url.URL
+		closed *url.URL
+	)
+
+	BeforeEach(func() {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		DeferCleanup(l.Close)
+		open = &url.URL{Scheme: "http", Host: l.Addr().String()}
+
+		l2, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		closed = &url.URL{Scheme: "http", Host: l2.Addr().String()}
+		l2.Close()
+	})
+
+	Describe("prefilter()", func() {
+		It("discards proxies refusing connections", func() {
+			w := &Worker{PrefilterTimeout: 200}
+			Expect(w.prefilter(open)).To(BeTrue())
+			Expect(w.prefilter(closed)).To(BeFalse())
+			Expect(w.prefilter(&url.URL{Scheme: "http", Host: "invalid.invalid:80"})).To(BeFalse())
+		})
+
+		It("passes all proxies when disabled or with a custom transport", func() {
+			Expect((&Worker{}).prefilter(closed)).To(BeTrue())
+
+			w := &Worker{PrefilterTimeout: 200, Transport: func(*url.URL) http.RoundTripper { return nil }}
+			Expect(w.prefilter(closed)).To(BeTrue())
+		})
+	})
+
+	Describe("checkProxies()", func() {
+		It("doesn't check unreachable proxies", func() {
+			target := mockHTTPServer("ok")
+			defer target.Close()
+			proxy, proxyURL := mockProxyServer(0)
+			defer proxy.Close()
+
+			w := &Worker{Strategy: "minimal", TestTarget: target.URL, Workers: 2, Timeout: 5, PrefilterTimeout: 200}
+			alive := w.checkProxies(proxyMap{proxyKey(proxyURL): proxyURL, proxyKey(closed): closed})
+			Expect(alive).To(HaveLen(1))
+			Expect(alive[0].URL).To(Equal(proxyURL))
+		})
+	})
+})
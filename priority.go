@@ -0,0 +1,78 @@
+package httptines
+
+import "fmt"
+
+// Prioritize queues targets in the priority lane. Priority targets are dequeued
+// before the regular ones and may use the concurrency reserved by PriorityShare.
+// Targets queued before Run are processed along with the ones passed to it.
+// Retries of failed priority targets go to the regular queue, while targets held
+// back by HostRate, a claim or RetryBudget return to the priority lane.
+// Parameters:
+//   - targets: Target URLs to process
+//
+// Returns:
+//   - int: Number of queued targets
+func (w *Worker) Prioritize(targets ...string) int {
+	targets = w.admit(targets)
+	if len(targets) == 0 {
+		return 0
+	}
+
+	w.m.Lock()
+	w.dropLeftovers()
+	w.priority = append(w.priority, targets...)
+	w.recordQueue(true, targets...)
+	if w.stat != nil {
+		w.stat.addTargets(len(targets))
+	}
+	w.m.Unlock()
+
+	w.wlog(fmt.Sprintf("%d priority targets queued", len(targets)))
+	return len(targets)
+}
+
+// retriggerPriority puts a held priority target back into the priority lane.
+// Parameters:
+//   - u: URL to be reprocessed
+func (w *Worker) retriggerPriority(u string) {
+	w.m.Lock()
+	w.priority = append(w.priority, u)
+	w.recordQueue(true, u)
+	w.m.Unlock()
+}
+
+// shiftPriority removes and returns the first n targets of the priority lane.
+// Parameters:
+//   - n: Number of targets to remove and return
+//
+// Returns:
+//   - []string: Slice of removed targets
+func (w *Worker) shiftPriority(n int) []string {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	n = min(n, len(w.priority))
+	if n <= 0 {
+		return nil
+	}
+
+	items := w.priority[:n:n]
+	w.priority = w.priority[n:]
+	w.recordQueue(false, items...)
+	return items
+}
+
+// reserved returns how many of n slots are kept for priority targets.
+// At least one slot is reserved when sharing is enabled, and at least one is left for the rest.
+// Parameters:
+//   - n: Number of slots
+//   - share: Reserved percentage
+//
+// Returns:
+//   - int: Number of reserved slots
+func reserved(n, share int) int {
+	if share <= 0 || n <= 1 {
+		return 0
+	}
+	return min(max(n*share/100, 1), n-1)
+}
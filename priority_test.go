@@ -0,0 +1,51 @@
+package httptines
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Priority", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{
+			AllowedHosts: []string{"example.com"},
+			stat:         &Stat{Targets: 1},
+			targets:      []string{"http://example.com/bulk"},
+		}
+	})
+
+	Describe("Prioritize()", func() {
+		It("queues permitted targets in the priority lane", func() {
+			n := w.Prioritize("http://example.com/1", "http://other.com/", "http://example.com/2")
+
+			Expect(n).To(Equal(2))
+			Expect(w.stat.Targets).To(Equal(3))
+			Expect(w.shiftPriority(10)).To(Equal([]string{"http://example.com/1", "http://example.com/2"}))
+			Expect(w.shiftPriority(10)).To(BeEmpty())
+			Expect(w.targets).To(Equal([]string{"http://example.com/bulk"}))
+		})
+
+		It("reports priority targets as unfinished", func() {
+			w.Prioritize("http://example.com/1")
+			Expect(w.Unfinished()).To(Equal([]string{"http://example.com/1", "http://example.com/bulk"}))
+		})
+	})
+
+	Describe("reserved()", func() {
+		It("reserves the share of slots", func() {
+			Expect(reserved(10, 20)).To(Equal(2))
+		})
+
+		It("reserves at least one slot and leaves one", func() {
+			Expect(reserved(3, 10)).To(Equal(1))
+			Expect(reserved(2, 100)).To(Equal(1))
+		})
+
+		It("reserves nothing when disabled or impossible", func() {
+			Expect(reserved(10, 0)).To(BeZero())
+			Expect(reserved(1, 50)).To(BeZero())
+		})
+	})
+})
@@ -0,0 +1,38 @@
+package httptines
+
+import "time"
+
+// progress returns the number of processed, failed and remaining targets. Targets
+// finished by another process sharing ClaimDir aren't remaining.
+// Returns:
+//   - int: Processed targets
+//   - int: Targets abandoned after exhausting their retries
+//   - int: Targets left to process
+func (s *Stat) progress() (done, failed, remaining int) {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	done, failed = s.processed, s.Abandoned
+	return done, failed, max(s.Targets-done-failed-s.Claimed, 0)
+}
+
+// reportProgress periodically passes the progress to OnProgress and reports
+// the final progress once the run ends.
+func (w *Worker) reportProgress() {
+	if w.OnProgress == nil {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(w.ProgressInterval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.quit:
+			w.OnProgress(w.stat.progress())
+			return
+		case <-ticker.C:
+			w.OnProgress(w.stat.progress())
+		}
+	}
+}
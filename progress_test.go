@@ -0,0 +1,63 @@
+package httptines
+
+import (
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Progress", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{
+			ProgressInterval: 1,
+			quit:             make(chan struct{}),
+			stat:             &Stat{Targets: 10, Abandoned: 2, processed: 3},
+		}
+	})
+
+	Describe("progress()", func() {
+		It("counts processed, failed and remaining targets", func() {
+			done, failed, remaining := w.stat.progress()
+			Expect([]int{done, failed, remaining}).To(Equal([]int{3, 2, 5}))
+		})
+
+		It("doesn't count targets claimed by other processes as remaining", func() {
+			w.stat.Claimed = 4
+			_, _, remaining := w.stat.progress()
+			Expect(remaining).To(Equal(1))
+		})
+	})
+
+	Describe("reportProgress()", func() {
+		It("reports periodically and at the end", func() {
+			var m sync.Mutex
+			var reports [][]int
+			w.OnProgress = func(done, failed, remaining int) {
+				m.Lock()
+				reports = append(reports, []int{done, failed, remaining})
+				m.Unlock()
+			}
+
+			finished := make(chan struct{})
+			go func() {
+				w.reportProgress()
+				close(finished)
+			}()
+
+			Eventually(func() int {
+				m.Lock()
+				defer m.Unlock()
+				return len(reports)
+			}, 2*time.Second).Should(Equal(1))
+
+			close(w.quit)
+			Eventually(finished).Should(BeClosed())
+			Expect(reports).To(HaveLen(2))
+			Expect(reports[1]).To(Equal([]int{3, 2, 5}))
+		})
+	})
+})
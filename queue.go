@@ -0,0 +1,107 @@
+package httptines
+
+import "slices"
+
+// queueJournalSize is the maximum number of queue changes kept for diffing.
+const queueJournalSize = 100000
+
+// queueEvent represents a single change of the target queue.
+type queueEvent struct {
+	seq    uint64
+	added  bool
+	target string
+}
+
+// queueDiff represents the changes of the target queue since a token.
+type queueDiff struct {
+	// Token identifies the current state of the queue and is passed as "since" in the next request
+	Token uint64 `json:"token"`
+	// Added contains targets added to the queue since the token
+	Added []string `json:"added"`
+	// Removed contains targets removed from the queue since the token
+	Removed []string `json:"removed"`
+	// Reset indicates that the token is too old and Targets contains the full queue snapshot
+	Reset bool `json:"reset"`
+	// Targets contains the full queue snapshot when Reset is true, the priority lane first
+	Targets []string `json:"targets,omitempty"`
+}
+
+// queueJournal keeps a bounded log of queue changes.
+type queueJournal struct {
+	seq    uint64
+	events []queueEvent
+}
+
+// record appends changes to the journal, dropping the oldest ones when it is full.
+// Parameters:
+//   - added: Whether the targets were added (true) or removed (false)
+//   - targets: Changed targets
+func (j *queueJournal) record(added bool, targets ...string) {
+	for _, t := range targets {
+		j.seq++
+		j.events = append(j.events, queueEvent{seq: j.seq, added: added, target: t})
+	}
+
+	if n := len(j.events) - queueJournalSize; n > 0 {
+		j.events = append(j.events[:0:0], j.events[n:]...)
+	}
+}
+
+// since computes the net changes after the given token.
+// Parameters:
+//   - token: Sequence number of the last seen change
+//
+// Returns:
+//   - queueDiff: Net changes, Reset is true if the token is no longer covered by the journal
+func (j *queueJournal) since(token uint64) queueDiff {
+	d := queueDiff{Token: j.seq, Added: []string{}, Removed: []string{}}
+
+	if token > j.seq || (len(j.events) > 0 && j.events[0].seq > token+1) {
+		d.Reset = true
+		return d
+	}
+
+	var order []string
+	counts := map[string]int{}
+	for _, e := range j.events {
+		if e.seq <= token {
+			continue
+		}
+		if _, ok := counts[e.target]; !ok {
+			order = append(order, e.target)
+		}
+		if e.added {
+			counts[e.target]++
+		} else {
+			counts[e.target]--
+		}
+	}
+
+	for _, t := range order {
+		for n := counts[t]; n > 0; n-- {
+			d.Added = append(d.Added, t)
+		}
+		for n := counts[t]; n < 0; n++ {
+			d.Removed = append(d.Removed, t)
+		}
+	}
+
+	return d
+}
+
+// queueChanges returns the queue changes since the given token.
+// Parameters:
+//   - token: Sequence number of the last seen change
+//
+// Returns:
+//   - queueDiff: Net changes or a full snapshot if the token is too old
+func (w *Worker) queueChanges(token uint64) queueDiff {
+	w.m.RLock()
+	defer w.m.RUnlock()
+
+	d := w.journal.since(token)
+	if d.Reset {
+		d.Targets = slices.Concat(w.priority, w.targets)
+	}
+	return d
+}
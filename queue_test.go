@@ -0,0 +1,85 @@
+package httptines
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Queue", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{}
+		w.targets = []string{"http://test1.com", "http://test2.com"}
+		w.journal.record(true, w.targets...)
+	})
+
+	Describe("queueChanges()", func() {
+		It("returns all targets for the zero token", func() {
+			d := w.queueChanges(0)
+			Expect(d.Token).To(Equal(uint64(2)))
+			Expect(d.Added).To(Equal([]string{"http://test1.com", "http://test2.com"}))
+			Expect(d.Removed).To(BeEmpty())
+			Expect(d.Reset).To(BeFalse())
+		})
+
+		It("returns net changes since the token", func() {
+			w.shift(1)
+			w.retrigger("http://test3.com")
+			w.retrigger("http://test1.com")
+
+			d := w.queueChanges(2)
+			Expect(d.Token).To(Equal(uint64(5)))
+			Expect(d.Added).To(Equal([]string{"http://test3.com"}))
+			Expect(d.Removed).To(BeEmpty())
+		})
+
+		It("returns removed targets", func() {
+			w.shift(2)
+
+			d := w.queueChanges(2)
+			Expect(d.Removed).To(Equal([]string{"http://test1.com", "http://test2.com"}))
+		})
+
+		When("the token is no longer covered by the journal", func() {
+			It("returns a snapshot", func() {
+				w.journal.events = w.journal.events[1:]
+
+				d := w.queueChanges(0)
+				Expect(d.Reset).To(BeTrue())
+				Expect(d.Targets).To(Equal([]string{"http://test1.com", "http://test2.com"}))
+			})
+
+			It("includes the priority lane in the snapshot", func() {
+				w.Prioritize("http://urgent.com")
+				w.journal.events = w.journal.events[1:]
+
+				d := w.queueChanges(0)
+				Expect(d.Reset).To(BeTrue())
+				Expect(d.Targets).To(Equal([]string{"http://urgent.com", "http://test1.com", "http://test2.com"}))
+			})
+		})
+	})
+
+	Describe("queueHandler()", func() {
+		It("serves the changes as JSON", func() {
+			rec := httptest.NewRecorder()
+			queueHandler(w)(rec, httptest.NewRequest(http.MethodGet, "/api/queue?since=1", nil))
+
+			var d queueDiff
+			Expect(json.Unmarshal(rec.Body.Bytes(), &d)).To(Succeed())
+			Expect(d.Added).To(Equal([]string{"http://test2.com"}))
+		})
+
+		It("rejects invalid tokens", func() {
+			rec := httptest.NewRecorder()
+			queueHandler(w)(rec, httptest.NewRequest(http.MethodGet, "/api/queue?since=abc", nil))
+
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+		})
+	})
+})
@@ -0,0 +1,150 @@
+package httptines
+
+import (
+	"fmt"
+	"time"
+)
+
+// waitSamples is the number of recent waits kept to compute percentiles.
+const waitSamples = 1000
+
+// WaitStat summarizes how long targets waited in the queue.
+type WaitStat struct {
+	// Count is the number of measured waits
+	Count int `json:"count"`
+	// Avg is the average wait in milliseconds
+	Avg int `json:"avg"`
+	// P95 is the 95th percentile of the recent waits in milliseconds
+	P95 int `json:"p95"`
+	// Max is the longest wait in milliseconds
+	Max int `json:"max"`
+}
+
+// QueueWaitStat reports the queue waits of first attempts and retries separately,
+// so retries starved by new targets, or the other way around, stand out.
+type QueueWaitStat struct {
+	// First covers the waits before the first attempt of a target
+	First WaitStat `json:"first"`
+	// Retry covers the waits before the following attempts
+	Retry WaitStat `json:"retry"`
+}
+
+// String returns a one-line description of the waits.
+// Returns:
+//   - string: Waits description
+func (s QueueWaitStat) String() string {
+	return fmt.Sprintf("queue wait: first attempts avg %dms p95 %dms max %dms, retries avg %dms p95 %dms max %dms",
+		s.First.Avg, s.First.P95, s.First.Max, s.Retry.Avg, s.Retry.P95, s.Retry.Max)
+}
+
+// waitSeries accumulates waits of one kind.
+type waitSeries struct {
+	count  int
+	total  time.Duration
+	max    time.Duration
+	recent []int // Recent waits in milliseconds, oldest overwritten first
+	next   int   // Index of the next sample in recent
+}
+
+// add records a wait.
+// Parameters:
+//   - d: Wait duration
+func (s *waitSeries) add(d time.Duration) {
+	s.count++
+	s.total += d
+	s.max = max(s.max, d)
+
+	ms := int(d.Milliseconds())
+	if len(s.recent) < waitSamples {
+		s.recent = append(s.recent, ms)
+		return
+	}
+	s.recent[s.next] = ms
+	s.next = (s.next + 1) % waitSamples
+}
+
+// stat summarizes the waits.
+// Returns:
+//   - WaitStat: Summary of the waits
+func (s *waitSeries) stat() WaitStat {
+	if s.count == 0 {
+		return WaitStat{}
+	}
+	return WaitStat{
+		Count: s.count,
+		Avg:   int((s.total / time.Duration(s.count)).Milliseconds()),
+		P95:   percentile(s.recent, 95),
+		Max:   int(s.max.Milliseconds()),
+	}
+}
+
+// queueWaits measures how long targets wait between being queued and taken
+// out of the queue. It is guarded by the worker's mutex.
+type queueWaits struct {
+	since  map[string][]time.Time   // Enqueue times of the queued copies of each target
+	waited map[string]time.Duration // Total wait of each unfinished target
+	first  waitSeries
+	retry  waitSeries
+}
+
+// recordQueue records changes of the queue in the journal and measures the waits
+// of the removed targets. The caller must hold w.m.
+// Parameters:
+//   - added: Whether the targets were added (true) or removed (false)
+//   - targets: Changed targets
+func (w *Worker) recordQueue(added bool, targets ...string) {
+	w.journal.record(added, targets...)
+
+	q := &w.waits
+	if q.since == nil {
+		q.since = map[string][]time.Time{}
+		q.waited = map[string]time.Duration{}
+	}
+
+	now := time.Now()
+	for _, t := range targets {
+		if added {
+			q.since[t] = append(q.since[t], now)
+			continue
+		}
+
+		times := q.since[t]
+		if len(times) == 0 {
+			continue
+		}
+		d := now.Sub(times[0])
+		if len(times) == 1 {
+			delete(q.since, t)
+		} else {
+			q.since[t] = times[1:]
+		}
+
+		q.waited[t] += d
+		if w.attempts[t] > 0 {
+			q.retry.add(d)
+		} else {
+			q.first.add(d)
+		}
+	}
+}
+
+// queueWait returns how long the target has waited in the queue over all its attempts.
+// Parameters:
+//   - t: Target URL
+//
+// Returns:
+//   - time.Duration: Total wait
+func (w *Worker) queueWait(t string) time.Duration {
+	w.m.RLock()
+	defer w.m.RUnlock()
+	return w.waits.waited[t]
+}
+
+// queueWaitStat summarizes the queue waits of the run.
+// Returns:
+//   - QueueWaitStat: Waits of first attempts and retries
+func (w *Worker) queueWaitStat() QueueWaitStat {
+	w.m.RLock()
+	defer w.m.RUnlock()
+	return QueueWaitStat{First: w.waits.first.stat(), Retry: w.waits.retry.stat()}
+}
@@ -0,0 +1,79 @@
+package httptines
+
+import (
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Queue wait", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{attempts: map[string]int{}}
+	})
+
+	It("measures the wait of first attempts and retries separately", func() {
+		w.Add("a", "b")
+		time.Sleep(20 * time.Millisecond)
+		Expect(w.shift(2)).To(Equal([]string{"a", "b"}))
+
+		w.attempts["a"] = 1
+		w.retrigger("a")
+		time.Sleep(40 * time.Millisecond)
+		Expect(w.shift(1)).To(Equal([]string{"a"}))
+
+		s := w.queueWaitStat()
+		Expect(s.First.Count).To(Equal(2))
+		Expect(s.First.Avg).To(BeNumerically("~", 20, 15))
+		Expect(s.Retry.Count).To(Equal(1))
+		Expect(s.Retry.Max).To(BeNumerically(">=", 40))
+		Expect(w.queueWait("a")).To(BeNumerically(">=", 60*time.Millisecond))
+	})
+
+	It("measures the priority lane", func() {
+		w.Prioritize("a")
+		Expect(w.shiftPriority(1)).To(Equal([]string{"a"}))
+
+		Expect(w.queueWaitStat().First.Count).To(Equal(1))
+	})
+
+	It("matches queued copies of the same target in order", func() {
+		w.Add("a")
+		time.Sleep(30 * time.Millisecond)
+		w.Add("a")
+		w.shift(2)
+
+		s := w.queueWaitStat()
+		Expect(s.First.Count).To(Equal(2))
+		Expect(s.First.Max).To(BeNumerically(">=", 30))
+		Expect(w.waits.since).To(BeEmpty())
+	})
+
+	It("forgets the total wait of settled targets", func() {
+		w.Add("a")
+		w.shift(1)
+		w.settle("a")
+
+		Expect(w.queueWait("a")).To(BeZero())
+	})
+
+	It("keeps a bounded number of samples", func() {
+		var s waitSeries
+		for i := range waitSamples + 10 {
+			s.add(time.Duration(i) * time.Millisecond)
+		}
+
+		Expect(s.recent).To(HaveLen(waitSamples))
+		Expect(s.stat().Count).To(Equal(waitSamples + 10))
+		Expect(s.stat().Max).To(Equal(waitSamples + 9))
+	})
+
+	It("describes the waits", func() {
+		s := QueueWaitStat{First: WaitStat{Avg: 1, P95: 2, Max: 3}}
+		Expect(s.String()).To(HavePrefix("queue wait: first attempts avg 1ms p95 2ms max 3ms"))
+		Expect(strings.Count(s.String(), "avg")).To(Equal(2))
+	})
+})
@@ -0,0 +1,177 @@
+package httptines
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hostLimiter is a set of token buckets keyed by target host.
+type hostLimiter struct {
+	m       sync.Mutex
+	buckets map[string]*bucket
+}
+
+// bucket is a token bucket refilled at the host's rate.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// hostRate returns the request rate allowed for the host.
+// Parameters:
+//   - host: Target hostname
+//
+// Returns:
+//   - float64: Requests per second, zero if unlimited
+func (w *Worker) hostRate(host string) float64 {
+	if r, ok := w.HostRates[host]; ok {
+		return r
+	}
+	return w.HostRate
+}
+
+// throttle takes a token for the target's host.
+// Parameters:
+//   - t: Target URL
+//   - now: Current time
+//
+// Returns:
+//   - time.Duration: Zero if the request may proceed, otherwise how long to wait for a token
+func (w *Worker) throttle(t string, now time.Time) time.Duration {
+	u, err := url.Parse(t)
+	if err != nil {
+		return 0
+	}
+	host := strings.ToLower(u.Hostname())
+
+	rate := w.hostRate(host)
+	if rate <= 0 {
+		return 0
+	}
+	burst := max(rate, 1)
+
+	l := &w.rates
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.buckets == nil {
+		l.buckets = map[string]*bucket{}
+	}
+	b, ok := l.buckets[host]
+	if !ok {
+		b = &bucket{tokens: burst, last: now}
+		l.buckets[host] = b
+	}
+
+	b.refill(rate, burst, now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0
+	}
+	return time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// refill adds the tokens accumulated since the last update.
+// Parameters:
+//   - rate: Tokens per second
+//   - burst: Maximum number of tokens
+//   - now: Current time
+func (b *bucket) refill(rate, burst float64, now time.Time) {
+	b.tokens = min(b.tokens+now.Sub(b.last).Seconds()*rate, burst)
+	b.last = now
+}
+
+// admitNow filters out the targets whose hosts are over their rate and puts
+// them back into their lane once a token is expected to be available.
+// Parameters:
+//   - targets: Dequeued targets
+//   - requeue: Puts a target back into the lane it was taken from
+//
+// Returns:
+//   - []string: Targets that may be requested now
+func (w *Worker) admitNow(targets []string, requeue func(string)) []string {
+	if w.HostRate <= 0 && len(w.HostRates) == 0 {
+		return targets
+	}
+
+	now := time.Now()
+	ready := targets[:0:0]
+	for _, t := range targets {
+		d := w.throttle(t, now)
+		if d <= 0 {
+			ready = append(ready, t)
+			continue
+		}
+
+		w.hold(t)
+		time.AfterFunc(d, func() {
+			requeue(t)
+			w.unhold(t)
+		})
+	}
+	return ready
+}
+
+// proxyLimiter is the token bucket limiting the requests per second sent through a proxy.
+type proxyLimiter struct {
+	m    sync.Mutex
+	rate float64
+	b    bucket
+}
+
+// proxyLimiter creates the rate limiter of the proxy.
+// Parameters:
+//   - u: Proxy URL
+//
+// Returns:
+//   - *proxyLimiter: Rate limiter, nil if the proxy is unlimited
+func (w *Worker) proxyLimiter(u *url.URL) *proxyLimiter {
+	rate, ok := w.ProxyRates[u.Host]
+	if !ok {
+		rate = w.ProxyRate
+	}
+	if rate <= 0 {
+		return nil
+	}
+	return &proxyLimiter{rate: rate, b: bucket{tokens: max(rate, 1), last: time.Now()}}
+}
+
+// reserve takes a token, going into debt if none is left, so concurrent requests queue up.
+// Parameters:
+//   - now: Current time
+//
+// Returns:
+//   - time.Duration: How long to wait before sending the request
+func (l *proxyLimiter) reserve(now time.Time) time.Duration {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.b.refill(l.rate, max(l.rate, 1), now)
+	l.b.tokens--
+	if l.b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.b.tokens / l.rate * float64(time.Second))
+}
+
+// pace waits until the proxy's rate allows the next request. The wait ends early
+// when the server's context is cancelled, and the request fails right away.
+func (s *Server) pace() {
+	if s.rate == nil {
+		return
+	}
+
+	d := s.rate.reserve(time.Now())
+	if d <= 0 {
+		return
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-s.context().Done():
+	}
+}
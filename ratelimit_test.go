@@ -0,0 +1,127 @@
+package httptines
+
+import (
+	"context"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rate limit", func() {
+	var (
+		w   *Worker
+		now time.Time
+	)
+
+	BeforeEach(func() {
+		w = &Worker{HostRate: 2, HostRates: map[string]float64{"slow.com": 0.5}}
+		now = time.Now()
+	})
+
+	Describe("throttle()", func() {
+		It("allows bursts up to the rate", func() {
+			Expect(w.throttle("http://example.com/1", now)).To(BeZero())
+			Expect(w.throttle("http://example.com/2", now)).To(BeZero())
+			Expect(w.throttle("http://example.com/3", now)).To(Equal(500 * time.Millisecond))
+		})
+
+		It("refills tokens over time", func() {
+			w.throttle("http://example.com/1", now)
+			w.throttle("http://example.com/2", now)
+			Expect(w.throttle("http://example.com/3", now.Add(500*time.Millisecond))).To(BeZero())
+		})
+
+		It("limits hosts independently", func() {
+			w.throttle("http://example.com/1", now)
+			w.throttle("http://example.com/2", now)
+			Expect(w.throttle("http://other.com/", now)).To(BeZero())
+		})
+
+		It("applies host overrides", func() {
+			Expect(w.throttle("http://slow.com/1", now)).To(BeZero())
+			Expect(w.throttle("http://slow.com/2", now)).To(Equal(2 * time.Second))
+		})
+
+		It("doesn't limit without a rate", func() {
+			w.HostRate = 0
+			for range 10 {
+				Expect(w.throttle("http://example.com/", now)).To(BeZero())
+			}
+		})
+	})
+
+	Describe("admitNow()", func() {
+		It("puts throttled targets back into the queue later", func() {
+			w.HostRate = 10
+			targets := []string{}
+			for range 11 {
+				targets = append(targets, "http://example.com/")
+			}
+
+			Expect(w.admitNow(targets, w.retrigger)).To(HaveLen(10))
+			Expect(w.Unfinished()).To(HaveLen(1))
+			Eventually(func() []string { return w.shift(1) }).Should(Equal([]string{"http://example.com/"}))
+		})
+
+		It("puts throttled priority targets back into the priority lane", func() {
+			w.HostRate = 1
+			Expect(w.admitNow([]string{"http://example.com/1", "http://example.com/2"}, w.retriggerPriority)).To(HaveLen(1))
+			Eventually(func() []string { return w.shiftPriority(1) }, 2*time.Second).Should(Equal([]string{"http://example.com/2"}))
+			Expect(w.shift(1)).To(BeEmpty())
+		})
+	})
+
+	Describe("proxyLimiter()", func() {
+		It("applies the global rate and overrides", func() {
+			w.ProxyRate = 2
+			w.ProxyRates = map[string]float64{"1.2.3.4:8080": 5, "5.6.7.8:80": 0}
+
+			u, _ := url.Parse("http://9.9.9.9:80")
+			Expect(w.proxyLimiter(u).rate).To(Equal(2.0))
+			u, _ = url.Parse("http://1.2.3.4:8080")
+			Expect(w.proxyLimiter(u).rate).To(Equal(5.0))
+			u, _ = url.Parse("http://5.6.7.8:80")
+			Expect(w.proxyLimiter(u)).To(BeNil())
+		})
+
+		It("doesn't limit without a rate", func() {
+			u, _ := url.Parse("http://9.9.9.9:80")
+			Expect(w.proxyLimiter(u)).To(BeNil())
+		})
+	})
+
+	Describe("reserve()", func() {
+		It("queues requests over the rate", func() {
+			l := &proxyLimiter{rate: 2, b: bucket{tokens: 2, last: now}}
+			Expect(l.reserve(now)).To(BeZero())
+			Expect(l.reserve(now)).To(BeZero())
+			Expect(l.reserve(now)).To(Equal(500 * time.Millisecond))
+			Expect(l.reserve(now)).To(Equal(time.Second))
+			Expect(l.reserve(now.Add(time.Second))).To(Equal(500 * time.Millisecond))
+		})
+	})
+
+	Describe("pace()", func() {
+		It("delays requests over the proxy's rate", func() {
+			s := &Server{ctx: context.Background(), rate: &proxyLimiter{rate: 10, b: bucket{tokens: 1, last: time.Now()}}}
+
+			start := time.Now()
+			s.pace()
+			s.pace()
+			s.pace()
+			Expect(time.Since(start)).To(BeNumerically("~", 200*time.Millisecond, 50*time.Millisecond))
+		})
+
+		It("stops waiting when the server is cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			s := &Server{ctx: ctx, rate: &proxyLimiter{rate: 0.1, b: bucket{last: time.Now()}}}
+
+			start := time.Now()
+			s.pace()
+			Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		})
+	})
+})
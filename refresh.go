@@ -0,0 +1,145 @@
+package httptines
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrNotRunning is returned when a refresh or check is requested outside Run.
+var ErrNotRunning = errors.New("worker isn't running")
+
+// refresher triggers proxy fetch and check cycles out of band.
+type refresher struct {
+	m       sync.Mutex
+	running bool          // Whether fetchAndCheck is serving requests
+	last    time.Time     // Start of the last cycle
+	checked time.Time     // Start of the last check, with or without a download
+	done    bool          // Whether a check has completed in this run
+	ch      chan struct{} // Signals a requested cycle to fetchAndCheck
+	check   chan struct{} // Signals a requested check without a download to fetchAndCheck
+}
+
+// start accepts requests for a run, creating the channels on first use.
+func (r *refresher) start() {
+	r.m.Lock()
+	if r.ch == nil {
+		r.ch, r.check = make(chan struct{}, 1), make(chan struct{}, 1)
+	}
+	r.running, r.done = true, false
+	r.m.Unlock()
+}
+
+// stop rejects requests once the run ended.
+func (r *refresher) stop() {
+	r.m.Lock()
+	r.running = false
+	r.m.Unlock()
+}
+
+// startCycle records the start of a fetch and check cycle.
+// Parameters:
+//   - now: Current time
+func (r *refresher) startCycle(now time.Time) {
+	r.m.Lock()
+	r.last, r.checked = now, now
+	r.m.Unlock()
+}
+
+// finishCheck records the end of a check, after which the pool reflects the fetched proxies.
+func (r *refresher) finishCheck() {
+	r.m.Lock()
+	r.done = true
+	r.m.Unlock()
+}
+
+// completed reports whether a check has completed in this run.
+// Returns:
+//   - bool: True after the first check
+func (r *refresher) completed() bool {
+	r.m.Lock()
+	defer r.m.Unlock()
+	return r.done
+}
+
+// startCheck records the start of a check without a download.
+// Parameters:
+//   - now: Current time
+func (r *refresher) startCheck(now time.Time) {
+	r.m.Lock()
+	r.checked = now
+	r.m.Unlock()
+}
+
+// Refresh starts a proxy fetch and check cycle without waiting for Interval,
+// e.g. when the pool collapsed. Cycles are at least RefreshSpacing seconds apart.
+// Returns:
+//   - time.Duration: Zero if a cycle was started, otherwise how long to wait before the next refresh
+//   - error: ErrNotRunning if called outside Run
+func (w *Worker) Refresh() (time.Duration, error) {
+	r := &w.refresh
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if !r.running {
+		return 0, ErrNotRunning
+	}
+	if wait := r.last.Add(time.Duration(w.RefreshSpacing) * time.Second).Sub(time.Now()); wait > 0 {
+		return wait, nil
+	}
+	r.last = time.Now()
+
+	select {
+	case r.ch <- struct{}{}:
+		w.wlog("proxy refresh requested")
+	default:
+	}
+	return 0, nil
+}
+
+// Recheck checks the last downloaded proxies that aren't in the pool without
+// downloading the sources again. Requests made while a check is pending are merged.
+// Checks are at least RefreshSpacing seconds apart.
+// Returns:
+//   - time.Duration: Zero if a check was started, otherwise how long to wait before the next check
+//   - error: ErrNotRunning if called outside Run
+func (w *Worker) Recheck() (time.Duration, error) {
+	r := &w.refresh
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if !r.running {
+		return 0, ErrNotRunning
+	}
+	if wait := r.checked.Add(time.Duration(w.RefreshSpacing) * time.Second).Sub(time.Now()); wait > 0 {
+		return wait, nil
+	}
+	r.checked = time.Now()
+
+	select {
+	case r.check <- struct{}{}:
+		w.wlog("proxy check requested")
+	default:
+	}
+	return 0, nil
+}
+
+// fetchInterval returns the time between proxy downloads.
+// Returns:
+//   - time.Duration: FetchInterval, or Interval if it isn't set
+func (w *Worker) fetchInterval() time.Duration {
+	if w.FetchInterval > 0 {
+		return time.Duration(w.FetchInterval) * time.Second
+	}
+	return time.Duration(w.Interval) * time.Second
+}
+
+// checkInterval returns the time between checks of the downloaded proxies.
+// Returns:
+//   - time.Duration: CheckInterval, or Interval if it isn't set
+func (w *Worker) checkInterval() time.Duration {
+	if w.CheckInterval > 0 {
+		return time.Duration(w.CheckInterval) * time.Second
+	}
+	return time.Duration(w.Interval) * time.Second
+}
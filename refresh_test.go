@@ -0,0 +1,176 @@
+package httptines
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Refresh", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{RefreshSpacing: 60, Interval: 300}
+		w.refresh.start()
+	})
+
+	Describe("Refresh()", func() {
+		It("signals a cycle", func() {
+			Expect(w.Refresh()).To(BeZero())
+			Expect(w.refresh.ch).To(Receive())
+		})
+
+		It("keeps cycles RefreshSpacing apart", func() {
+			w.refresh.startCycle(time.Now().Add(-50 * time.Second))
+			Expect(w.Refresh()).To(BeNumerically("~", 10*time.Second, time.Second))
+			Expect(w.refresh.ch).NotTo(Receive())
+
+			w.refresh.startCycle(time.Now().Add(-time.Minute))
+			Expect(w.Refresh()).To(BeZero())
+			Expect(w.Refresh()).To(BeNumerically(">", 59*time.Second))
+		})
+
+		It("fails outside Run", func() {
+			_, err := (&Worker{}).Refresh()
+			Expect(err).To(MatchError(ErrNotRunning))
+
+			ch := w.refresh.ch
+			w.refresh.stop()
+			_, err = w.Refresh()
+			Expect(err).To(MatchError(ErrNotRunning))
+
+			w.refresh.start()
+			Expect(w.refresh.ch).To(BeIdenticalTo(ch))
+			Expect(w.Refresh()).To(BeZero())
+		})
+	})
+
+	Describe("Recheck()", func() {
+		It("signals a check and merges pending requests", func() {
+			w.RefreshSpacing = 0
+			Expect(w.Recheck()).To(BeZero())
+			Expect(w.Recheck()).To(BeZero())
+			Expect(w.refresh.check).To(Receive())
+			Expect(w.refresh.check).NotTo(Receive())
+			Expect(w.refresh.ch).NotTo(Receive())
+		})
+
+		It("keeps checks RefreshSpacing apart", func() {
+			w.refresh.startCheck(time.Now().Add(-50 * time.Second))
+			Expect(w.Recheck()).To(BeNumerically("~", 10*time.Second, time.Second))
+			Expect(w.refresh.check).NotTo(Receive())
+
+			w.refresh.startCycle(time.Now().Add(-time.Minute))
+			Expect(w.Recheck()).To(BeZero())
+			Expect(w.refresh.check).To(Receive())
+		})
+
+		It("fails outside Run", func() {
+			_, err := (&Worker{}).Recheck()
+			Expect(err).To(MatchError(ErrNotRunning))
+		})
+	})
+
+	Describe("intervals", func() {
+		It("fall back to Interval", func() {
+			Expect(w.fetchInterval()).To(Equal(300 * time.Second))
+			Expect(w.checkInterval()).To(Equal(300 * time.Second))
+
+			w.FetchInterval, w.CheckInterval = 3600, 60
+			Expect(w.fetchInterval()).To(Equal(time.Hour))
+			Expect(w.checkInterval()).To(Equal(time.Minute))
+		})
+	})
+
+	Describe("fetchAndCheck()", func() {
+		It("checks the downloaded proxies again between downloads", func() {
+			var downloads, checks int32
+			src := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&downloads, 1)
+				w.Write([]byte("10.255.255.1:8080\n"))
+			}))
+			defer src.Close()
+
+			w.Sources = proxySrc{"http": {src.URL}}
+			w.TestTarget = "http://test.com"
+			w.TargetOutage = "ignore"
+			w.Strategy = "minimal"
+			w.Workers = 1
+			w.FetchInterval, w.CheckInterval = 3600, 1
+			w.Transport = func(*url.URL) http.RoundTripper {
+				return roundTripFunc(func(*http.Request) (*http.Response, error) {
+					atomic.AddInt32(&checks, 1)
+					return nil, errors.New("refused")
+				})
+			}
+			w.stat = &Stat{}
+			var cancel context.CancelFunc
+			w.ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+
+			go w.fetchAndCheck()
+
+			Eventually(func() int32 { return atomic.LoadInt32(&checks) }, 5*time.Second).Should(BeNumerically(">=", 2))
+			Expect(atomic.LoadInt32(&downloads)).To(Equal(int32(1)))
+
+			w.RefreshSpacing = 0
+			Expect(w.Refresh()).To(BeZero())
+			Eventually(func() int32 { return atomic.LoadInt32(&downloads) }, 5*time.Second).Should(Equal(int32(2)))
+		})
+	})
+
+	Describe("recheckHandler()", func() {
+		It("accepts a check and rejects the following one", func() {
+			srv := httptest.NewServer(recheckHandler(w))
+			defer srv.Close()
+
+			resp, err := http.Post(srv.URL, "", nil)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
+			Expect(w.refresh.check).To(Receive())
+
+			resp, err = http.Post(srv.URL, "", nil)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+			Expect(resp.Header.Get("Retry-After")).To(Equal("60"))
+		})
+	})
+
+	Describe("refreshHandler()", func() {
+		It("accepts a refresh and rejects the following one", func() {
+			srv := httptest.NewServer(refreshHandler(w))
+			defer srv.Close()
+
+			resp, err := http.Post(srv.URL, "", nil)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusAccepted))
+			Expect(resp.Header.Get("Content-Type")).To(Equal("application/json"))
+
+			resp, err = http.Post(srv.URL, "", nil)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+			Expect(resp.Header.Get("Retry-After")).To(Equal("60"))
+		})
+
+		It("rejects a refresh outside Run", func() {
+			srv := httptest.NewServer(refreshHandler(&Worker{}))
+			defer srv.Close()
+
+			resp, err := http.Post(srv.URL, "", nil)
+			Expect(err).NotTo(HaveOccurred())
+			resp.Body.Close()
+			Expect(resp.StatusCode).To(Equal(http.StatusConflict))
+		})
+	})
+})
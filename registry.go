@@ -0,0 +1,105 @@
+package httptines
+
+import "sync"
+
+// registry holds the active proxy servers keyed by proxyKey, so a proxy keeps
+// a single Server across refreshes and lookups don't scan the pool.
+type registry struct {
+	m       sync.RWMutex
+	servers map[string]*Server
+}
+
+// key returns the registry key of the server.
+// Returns:
+//   - string: proxyKey of the server, or slotKey for gateway slots
+func (s *Server) key() string {
+	if s.slot > 0 {
+		return slotKey(s.URL, s.slot)
+	}
+	return proxyKey(s.URL)
+}
+
+// add registers a server unless an active server with the same address exists.
+// Parameters:
+//   - s: Server to register
+//
+// Returns:
+//   - bool: True if the server was registered
+func (r *registry) add(s *Server) bool {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.servers == nil {
+		r.servers = map[string]*Server{}
+	}
+	if _, ok := r.servers[s.key()]; ok {
+		return false
+	}
+	r.servers[s.key()] = s
+	return true
+}
+
+// remove unregisters the server if it is the one registered for its address.
+// Parameters:
+//   - s: Server to unregister
+func (r *registry) remove(s *Server) {
+	r.m.Lock()
+	if r.servers[s.key()] == s {
+		delete(r.servers, s.key())
+	}
+	r.m.Unlock()
+}
+
+// get returns the server registered for the address.
+// Parameters:
+//   - addr: Proxy key in the scheme://host:port format
+//
+// Returns:
+//   - *Server: Registered server, nil if none
+func (r *registry) get(addr string) *Server {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return r.servers[addr]
+}
+
+// len returns the number of registered servers.
+// Returns:
+//   - int: Number of registered servers
+func (r *registry) len() int {
+	r.m.RLock()
+	defer r.m.RUnlock()
+	return len(r.servers)
+}
+
+// capacity returns the total capacity of the registered servers.
+// Returns:
+//   - int: Sum of server capacities
+func (r *registry) capacity() int {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	n := 0
+	for _, s := range r.servers {
+		n += s.Capacity
+	}
+	return n
+}
+
+// unknown drops the proxies that already have a registered server.
+// Parameters:
+//   - proxies: Set of proxy URLs keyed by proxyKey
+//
+// Returns:
+//   - proxyMap: Proxies that need to be checked
+func (r *registry) unknown(proxies proxyMap) proxyMap {
+	r.m.RLock()
+	defer r.m.RUnlock()
+
+	fresh := make(proxyMap, len(proxies))
+	for addr, u := range proxies {
+		if _, ok := r.servers[addr]; !ok {
+			fresh[addr] = u
+		}
+	}
+	return fresh
+}
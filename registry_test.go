@@ -0,0 +1,48 @@
+package httptines
+
+import (
+	"net/url"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Registry", func() {
+	var (
+		r   *registry
+		srv *Server
+	)
+
+	BeforeEach(func() {
+		r = &registry{}
+		u, _ := url.Parse("http://1.2.3.4:8080")
+		srv = &Server{URL: u}
+	})
+
+	It("registers one server per address", func() {
+		Expect(r.add(srv)).To(BeTrue())
+		Expect(r.add(&Server{URL: srv.URL})).To(BeFalse())
+		Expect(r.get("http://1.2.3.4:8080")).To(BeIdenticalTo(srv))
+		Expect(r.len()).To(Equal(1))
+
+		socks, _ := url.Parse("socks5://1.2.3.4:8080")
+		Expect(r.add(&Server{URL: socks})).To(BeTrue())
+	})
+
+	It("removes only the registered server", func() {
+		r.add(srv)
+		r.remove(&Server{URL: srv.URL})
+		Expect(r.get("http://1.2.3.4:8080")).To(BeIdenticalTo(srv))
+
+		r.remove(srv)
+		Expect(r.get("http://1.2.3.4:8080")).To(BeNil())
+	})
+
+	It("filters out registered proxies", func() {
+		r.add(srv)
+		other, _ := url.Parse("http://5.6.7.8:3128")
+
+		fresh := r.unknown(proxyMap{proxyKey(srv.URL): srv.URL, proxyKey(other): other})
+		Expect(fresh).To(Equal(proxyMap{proxyKey(other): other}))
+	})
+})
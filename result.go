@@ -0,0 +1,272 @@
+package httptines
+
+import (
+	"context"
+	"iter"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is the number of results buffered for a subscriber.
+// Results are dropped for subscribers that fall further behind.
+const subscriberBuffer = 256
+
+// Result represents a successfully processed target.
+type Result struct {
+	// URL is the processed target
+	URL string
+	// Status is the response status code
+	Status int
+	// Header contains the response headers
+	Header http.Header
+	// Redirects contains the redirects followed, in order
+	Redirects []Redirect
+	// Proxy is the URL of the proxy that served the request
+	Proxy string
+	// Latency is the time taken by the successful attempt
+	Latency time.Duration
+	// Attempts is the number of attempts made, including the successful one
+	Attempts int
+	// QueueWait is the time the target spent in the queue over all attempts
+	QueueWait time.Duration
+	// Body is the response body
+	Body []byte
+	// Meta is the metadata of the Target, nil for plain URLs
+	Meta map[string]any
+	// Language is the declared page language, set if Languages is configured
+	Language string
+	// Encoding is the compression of Body, e.g. "gzip", empty if it isn't compressed
+	Encoding string
+	// AttemptID identifies the successful attempt in logs, statuses and the results stream
+	AttemptID string
+
+	key string // Queue key of the target, the URL unless several targets share it
+	ctx context.Context
+}
+
+// Redirect describes a redirect followed while processing a target.
+type Redirect struct {
+	// URL is the redirecting URL
+	URL string
+	// Status is the redirect status code
+	Status int
+	// Location is the URL redirected to
+	Location string
+	// Latency is the time taken by the redirecting request
+	Latency time.Duration
+}
+
+// Context returns a context carrying the values of the request context, e.g. the
+// worker's context values and the attempt ID. It is cancelled when the worker stops,
+// so downstream processing can skip work on results that will be discarded, but
+// doesn't expire with the request timeout, so results held by Ordered or written to
+// the Sink later can still use it.
+// Returns:
+//   - context.Context: Result context, context.Background() if none is set
+func (r Result) Context() context.Context {
+	if r.ctx != nil {
+		return r.ctx
+	}
+	return context.Background()
+}
+
+// resultContext is a context with the values of one context and the lifetime of another.
+type resultContext struct {
+	context.Context
+	values context.Context
+}
+
+// Value returns the value of the key from the values context.
+// Parameters:
+//   - key: Context key
+//
+// Returns:
+//   - any: Value associated with the key, nil if none
+func (c resultContext) Value(key any) any {
+	return c.values.Value(key)
+}
+
+// resultContext returns the context of a result: the values of the request context,
+// cancelled along with the worker rather than the request.
+// Parameters:
+//   - req: Request context
+//
+// Returns:
+//   - context.Context: Result context
+func (w *Worker) resultContext(req context.Context) context.Context {
+	life := w.ctx
+	if life == nil {
+		life = context.Background()
+	}
+	return resultContext{Context: life, values: req}
+}
+
+// Results starts the worker with the given targets and returns an iterator over
+// the results as they complete, each with a nil error. The iteration ends when all
+// targets are processed. If the configuration is invalid or the context is cancelled,
+// the last pair carries the error and an empty result. Breaking out of the loop
+// stops the worker.
+// Parameters:
+//   - ctx: Context controlling the worker's lifetime
+//   - targets: List of URLs to process
+//
+// Returns:
+//   - iter.Seq2[Result, error]: Iterator over the results
+func (w *Worker) Results(ctx context.Context, targets []string) iter.Seq2[Result, error] {
+	return func(yield func(Result, error) bool) {
+		results := make(chan Result)
+		done := make(chan struct{})
+		defer close(done)
+
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		var err error
+		go func() {
+			defer close(results)
+			err = w.run(ctx, targets, func(r Result) {
+				select {
+				case results <- r:
+				case <-done:
+				}
+			})
+			if err == nil {
+				err = ctx.Err()
+			}
+		}()
+
+		for r := range results {
+			if !yield(r, nil) {
+				return
+			}
+		}
+		if err != nil {
+			yield(Result{}, err)
+		}
+	}
+}
+
+// Collect runs the worker with the given targets and returns all results once
+// they are processed, for small jobs and tests. Results are in completion order,
+// and their contexts are done by the time Collect returns.
+// Parameters:
+//   - ctx: Context controlling the worker's lifetime
+//   - targets: List of URLs to process
+//
+// Returns:
+//   - []Result: Processed results, partial if the context was cancelled
+//   - error: *ValidationError if the configuration is invalid, or the context's error
+func (w *Worker) Collect(ctx context.Context, targets []string) ([]Result, error) {
+	var results []Result
+	var m sync.Mutex
+
+	err := w.run(ctx, targets, func(r Result) {
+		m.Lock()
+		results = append(results, r)
+		m.Unlock()
+	})
+	if err == nil {
+		err = ctx.Err()
+	}
+	return results, err
+}
+
+// attempt counts an attempt to process a target.
+// Parameters:
+//   - t: Target URL
+//
+// Returns:
+//   - int: Number of attempts made so far, including this one
+func (w *Worker) attempt(t string) int {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.attempts == nil {
+		w.attempts = map[string]int{}
+	}
+	w.attempts[t]++
+	return w.attempts[t]
+}
+
+// refund takes back an attempt that failed because of the proxy rather than the target,
+// so it doesn't count towards MaxRetries.
+// Parameters:
+//   - t: Target URL
+func (w *Worker) refund(t string) {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	if w.attempts[t] <= 1 {
+		delete(w.attempts, t)
+		return
+	}
+	w.attempts[t]--
+}
+
+// attemptCount returns the number of attempts made for a target.
+// Parameters:
+//   - t: Target URL
+//
+// Returns:
+//   - int: Number of attempts
+func (w *Worker) attemptCount(t string) int {
+	w.m.RLock()
+	defer w.m.RUnlock()
+	return w.attempts[t]
+}
+
+// settle resets the attempt counter of a processed target.
+// Parameters:
+//   - t: Target URL
+func (w *Worker) settle(t string) {
+	w.m.Lock()
+	delete(w.attempts, t)
+	delete(w.waits.waited, t)
+	w.m.Unlock()
+}
+
+// resultHub fans out results to subscribers such as streaming API clients.
+type resultHub struct {
+	m    sync.Mutex
+	subs map[chan Result]struct{}
+}
+
+// subscribe registers a subscriber.
+// Returns:
+//   - chan Result: Channel receiving the results
+func (h *resultHub) subscribe() chan Result {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	if h.subs == nil {
+		h.subs = map[chan Result]struct{}{}
+	}
+	ch := make(chan Result, subscriberBuffer)
+	h.subs[ch] = struct{}{}
+	return ch
+}
+
+// unsubscribe removes a subscriber.
+// Parameters:
+//   - ch: Channel returned by subscribe
+func (h *resultHub) unsubscribe(ch chan Result) {
+	h.m.Lock()
+	delete(h.subs, ch)
+	h.m.Unlock()
+}
+
+// publish sends the result to every subscriber without blocking.
+// Parameters:
+//   - r: Processed result
+func (h *resultHub) publish(r Result) {
+	h.m.Lock()
+	defer h.m.Unlock()
+
+	for ch := range h.subs {
+		select {
+		case ch <- r:
+		default:
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package httptines
+
+import (
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Result", func() {
+	Describe("resultHub", func() {
+		var h *resultHub
+
+		BeforeEach(func() {
+			h = &resultHub{}
+		})
+
+		It("sends results to every subscriber", func() {
+			a, b := h.subscribe(), h.subscribe()
+			h.publish(Result{URL: "http://test1.com"})
+
+			Expect((<-a).URL).To(Equal("http://test1.com"))
+			Expect((<-b).URL).To(Equal("http://test1.com"))
+		})
+
+		It("drops results for slow subscribers", func() {
+			ch := h.subscribe()
+			for range subscriberBuffer + 10 {
+				h.publish(Result{})
+			}
+			Expect(ch).To(HaveLen(subscriberBuffer))
+		})
+
+		It("stops sending after unsubscribe", func() {
+			ch := h.subscribe()
+			h.unsubscribe(ch)
+			h.publish(Result{})
+			Expect(ch).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,186 @@
+package httptines
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// budgetSlots is the number of slots the retry budget window is divided into.
+const budgetSlots = 30
+
+// RetryBudgetStat reports the retry budget of a target host.
+type RetryBudgetStat struct {
+	// Requests is the number of requests sent to the host within the window, retries included
+	Requests int `json:"requests"`
+	// Retries is the number of retries sent to the host within the window
+	Retries int `json:"retries"`
+	// Deferred is the number of retries held back during the run because the budget was used up
+	Deferred int `json:"deferred"`
+}
+
+// retryBudget counts requests and retries per target host in a sliding window.
+type retryBudget struct {
+	m     sync.Mutex
+	hosts map[string]*budgetWindow
+}
+
+// budgetWindow holds the slots of a host, oldest first.
+type budgetWindow struct {
+	slots    []budgetSlot
+	deferred int
+}
+
+// budgetSlot counts the requests started within a slot of the window.
+type budgetSlot struct {
+	start    time.Time
+	requests int
+	retries  int
+}
+
+// totals sums the slots that started within the window.
+// Parameters:
+//   - now: Current time
+//   - window: Window duration
+//
+// Returns:
+//   - int: Requests
+//   - int: Retries
+func (b *budgetWindow) totals(now time.Time, window time.Duration) (int, int) {
+	i := 0
+	for i < len(b.slots) && now.Sub(b.slots[i].start) >= window {
+		i++
+	}
+	b.slots = b.slots[i:]
+
+	requests, retries := 0, 0
+	for _, s := range b.slots {
+		requests += s.requests
+		retries += s.retries
+	}
+	return requests, retries
+}
+
+// add counts a request in the current slot.
+// Parameters:
+//   - now: Current time
+//   - window: Window duration
+//   - retry: Whether the request is a retry
+func (b *budgetWindow) add(now time.Time, window time.Duration, retry bool) {
+	n := len(b.slots)
+	if n == 0 || now.Sub(b.slots[n-1].start) >= window/budgetSlots {
+		b.slots = append(b.slots, budgetSlot{start: now})
+		n++
+	}
+	b.slots[n-1].requests++
+	if retry {
+		b.slots[n-1].retries++
+	}
+}
+
+// targetHost returns the lowercase hostname of the target.
+// Parameters:
+//   - t: Target URL
+//
+// Returns:
+//   - string: Hostname, the target itself if it can't be parsed
+func targetHost(t string) string {
+	u, err := url.Parse(t)
+	if err != nil || u.Host == "" {
+		return t
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// spendBudget counts the request to the target's host and reports whether
+// it may be sent. Retries are allowed while they stay within RetryBudget
+// percent of the host's requests over RetryBudgetWindow, or below RetryBudgetMin.
+// Parameters:
+//   - t: Target URL
+//   - retry: Whether the request is a retry
+//   - now: Current time
+//
+// Returns:
+//   - bool: True if the request may be sent
+func (w *Worker) spendBudget(t string, retry bool, now time.Time) bool {
+	window := time.Duration(w.RetryBudgetWindow) * time.Second
+	host := targetHost(t)
+
+	rb := &w.budget
+	rb.m.Lock()
+	defer rb.m.Unlock()
+
+	if rb.hosts == nil {
+		rb.hosts = map[string]*budgetWindow{}
+	}
+	b, ok := rb.hosts[host]
+	if !ok {
+		b = &budgetWindow{}
+		rb.hosts[host] = b
+	}
+
+	if retry {
+		requests, retries := b.totals(now, window)
+		if retries+1 > w.RetryBudgetMin && (retries+1)*100 > w.RetryBudget*(requests+1) {
+			b.deferred++
+			return false
+		}
+	}
+
+	b.add(now, window, retry)
+	return true
+}
+
+// budgetRetries puts back the retries of hosts that used up their retry budget.
+// They are queued again in their lane once the next slot of the window starts.
+// Parameters:
+//   - targets: Dequeued targets
+//   - requeue: Puts a target back into the lane it was taken from
+//
+// Returns:
+//   - []string: Targets that may be requested now
+func (w *Worker) budgetRetries(targets []string, requeue func(string)) []string {
+	if w.RetryBudget <= 0 || len(targets) == 0 {
+		return targets
+	}
+
+	now := time.Now()
+	d := time.Duration(w.RetryBudgetWindow) * time.Second / budgetSlots
+	allowed := targets[:0:0]
+	for _, t := range targets {
+		if w.spendBudget(t, w.attemptCount(t) > 0, now) {
+			allowed = append(allowed, t)
+			continue
+		}
+
+		w.hold(t)
+		time.AfterFunc(d, func() {
+			requeue(t)
+			w.unhold(t)
+		})
+	}
+	return allowed
+}
+
+// retryBudgetStat reports the retry budget of every host seen in the window.
+// Returns:
+//   - map[string]RetryBudgetStat: Budget keyed by target host
+func (w *Worker) retryBudgetStat() map[string]RetryBudgetStat {
+	window := time.Duration(w.RetryBudgetWindow) * time.Second
+	now := time.Now()
+
+	rb := &w.budget
+	rb.m.Lock()
+	defer rb.m.Unlock()
+
+	stat := map[string]RetryBudgetStat{}
+	for host, b := range rb.hosts {
+		requests, retries := b.totals(now, window)
+		if requests == 0 && b.deferred == 0 {
+			continue
+		}
+		stat[host] = RetryBudgetStat{Requests: requests, Retries: retries, Deferred: b.deferred}
+	}
+	return stat
+}
@@ -0,0 +1,102 @@
+package httptines
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Retry budget", func() {
+	var (
+		w   *Worker
+		now time.Time
+	)
+
+	BeforeEach(func() {
+		w = &Worker{RetryBudget: 20, RetryBudgetWindow: 300, RetryBudgetMin: 1}
+		now = time.Now()
+	})
+
+	Describe("spendBudget()", func() {
+		It("allows retries within the percentage of requests", func() {
+			for range 8 {
+				Expect(w.spendBudget("http://example.com/", false, now)).To(BeTrue())
+			}
+			Expect(w.spendBudget("http://example.com/", true, now)).To(BeTrue())
+			Expect(w.spendBudget("http://example.com/", true, now)).To(BeTrue())
+			Expect(w.spendBudget("http://example.com/", true, now)).To(BeFalse())
+		})
+
+		It("always allows RetryBudgetMin retries", func() {
+			w.RetryBudgetMin = 3
+			for range 3 {
+				Expect(w.spendBudget("http://example.com/", true, now)).To(BeTrue())
+			}
+			Expect(w.spendBudget("http://example.com/", true, now)).To(BeFalse())
+		})
+
+		It("never limits first attempts", func() {
+			w.RetryBudget = 1
+			w.spendBudget("http://example.com/", true, now)
+			for range 10 {
+				Expect(w.spendBudget("http://example.com/", false, now)).To(BeTrue())
+			}
+		})
+
+		It("keeps budgets of hosts apart", func() {
+			Expect(w.spendBudget("http://example.com/", true, now)).To(BeTrue())
+			Expect(w.spendBudget("http://example.com/", true, now)).To(BeFalse())
+			Expect(w.spendBudget("http://Other.com/", true, now)).To(BeTrue())
+		})
+
+		It("frees the budget as the window slides", func() {
+			w.spendBudget("http://example.com/", true, now)
+			Expect(w.spendBudget("http://example.com/", true, now.Add(time.Minute))).To(BeFalse())
+			Expect(w.spendBudget("http://example.com/", true, now.Add(5*time.Minute))).To(BeTrue())
+		})
+	})
+
+	Describe("retryBudgetStat()", func() {
+		It("reports requests, retries and deferred retries per host", func() {
+			w.RetryBudgetWindow = 60
+			for range 4 {
+				w.spendBudget("http://example.com/a", false, time.Now())
+			}
+			w.spendBudget("http://example.com/a", true, time.Now())
+			w.spendBudget("http://example.com/a", true, time.Now())
+
+			Expect(w.retryBudgetStat()).To(Equal(map[string]RetryBudgetStat{
+				"example.com": {Requests: 5, Retries: 1, Deferred: 1},
+			}))
+		})
+	})
+
+	Describe("budgetRetries()", func() {
+		It("puts retries over the budget back into the queue later", func() {
+			w.RetryBudgetWindow = 3
+			w.attempts = map[string]int{"http://example.com/1": 1, "http://example.com/2": 1}
+
+			Expect(w.budgetRetries([]string{"http://example.com/1", "http://example.com/2"}, w.retrigger)).
+				To(Equal([]string{"http://example.com/1"}))
+			Expect(w.Unfinished()).To(ConsistOf("http://example.com/2"))
+			Eventually(func() []string { return w.shift(1) }).Should(Equal([]string{"http://example.com/2"}))
+		})
+
+		It("puts priority retries back into the priority lane", func() {
+			w.RetryBudgetWindow = 3
+			w.attempts = map[string]int{"http://example.com/1": 1, "http://example.com/2": 1}
+
+			Expect(w.budgetRetries([]string{"http://example.com/1", "http://example.com/2"}, w.retriggerPriority)).
+				To(Equal([]string{"http://example.com/1"}))
+			Eventually(func() []string { return w.shiftPriority(1) }).Should(Equal([]string{"http://example.com/2"}))
+			Expect(w.shift(1)).To(BeEmpty())
+		})
+
+		It("passes targets through without a budget", func() {
+			w.RetryBudget = 0
+			w.attempts = map[string]int{"http://example.com/": 5}
+			Expect(w.budgetRetries([]string{"http://example.com/", "http://example.com/"}, w.retrigger)).To(HaveLen(2))
+		})
+	})
+})
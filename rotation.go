@@ -0,0 +1,341 @@
+package httptines
+
+import (
+	"cmp"
+	"math/rand"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Rotation strategies.
+const (
+	rotationPull       = "pull"
+	rotationRoundRobin = "round-robin"
+	rotationRandom     = "random"
+	rotationLeastConns = "least-connections"
+	rotationSticky     = "sticky-host"
+)
+
+// rotationStrategies lists the valid values of Worker.Rotation.
+var rotationStrategies = []string{rotationPull, rotationRoundRobin, rotationRandom, rotationLeastConns, rotationSticky}
+
+// rotationPoll is the longest wait of a server that isn't picked for the next target,
+// in case the picked server leaves the pool without taking its turn.
+const rotationPoll = 100 * time.Millisecond
+
+// rotator decides which server takes the next target, and which server
+// the targets of each host are bound to with sticky rotation.
+type rotator struct {
+	m     sync.Mutex
+	next  *Server // Server picked for the next target
+	last  string  // Name of the server that took the last target
+	hm    sync.Mutex
+	hosts map[string]*Server // Servers bound to target hosts
+}
+
+// free reports whether the server is enabled and has a free slot.
+// Parameters:
+//   - s: Server to check
+//
+// Returns:
+//   - bool: True if the server can take a target
+func (w *Worker) free(s *Server) bool {
+	if atomic.LoadUint32(&s.Disabled) > 0 {
+		return false
+	}
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return int(atomic.LoadInt32(&s.busy)) < w.slots.inflight(s.ramp.cap(s.Capacity))
+}
+
+// ready returns the servers that can take a target.
+// Returns:
+//   - []*Server: Enabled servers with a free slot that aren't banned
+func (w *Worker) ready() []*Server {
+	var servers []*Server
+	for _, s := range w.servers.enabled() {
+		if w.free(s) && !w.banned(s.name()) {
+			servers = append(servers, s)
+		}
+	}
+	return servers
+}
+
+// local reports whether a proxy checked in the region is local to the worker.
+// Without Region set, every proxy is.
+// Parameters:
+//   - region: Region the proxy was checked in
+//
+// Returns:
+//   - bool: True if the proxy is region-local
+func (w *Worker) local(region string) bool {
+	return w.Region == "" || region == "" || region == w.Region
+}
+
+// preferLocal returns the region-local servers, or all servers if none is local.
+// Parameters:
+//   - servers: Ready servers
+//
+// Returns:
+//   - []*Server: Servers to pick from
+func (w *Worker) preferLocal(servers []*Server) []*Server {
+	local := slices.DeleteFunc(slices.Clone(servers), func(s *Server) bool { return !w.local(s.Region) })
+	if len(local) == 0 {
+		return servers
+	}
+	return local
+}
+
+// localReady reports whether a region-local server can take a target.
+// Returns:
+//   - bool: True if a region-local server is ready
+func (w *Worker) localReady() bool {
+	return slices.ContainsFunc(w.ready(), func(s *Server) bool { return w.local(s.Region) })
+}
+
+// pick chooses the server for the next target according to the rotation strategy.
+// Parameters:
+//   - strategy: Rotation strategy
+//   - servers: Ready servers
+//
+// Returns:
+//   - *Server: Picked server, nil if there are no servers
+func (r *rotator) pick(strategy string, servers []*Server) *Server {
+	if len(servers) == 0 {
+		return nil
+	}
+
+	scores := make(map[*Server]float64, len(servers))
+	for _, s := range servers {
+		s.m.RLock()
+		scores[s] = s.score()
+		s.m.RUnlock()
+	}
+
+	if strategy == rotationRandom {
+		// Servers are picked with a probability proportional to their score
+		var total float64
+		for _, s := range servers {
+			total += scores[s]
+		}
+		x := rand.Float64() * total
+		for _, s := range servers {
+			if x -= scores[s]; x < 0 {
+				return s
+			}
+		}
+		return servers[len(servers)-1]
+	}
+
+	candidates := servers
+	if strategy == rotationLeastConns {
+		// The least busy servers with the best score, equal ones take turns
+		least := slices.MinFunc(servers, func(a, b *Server) int {
+			return int(atomic.LoadInt32(&a.busy) - atomic.LoadInt32(&b.busy))
+		})
+		candidates = slices.DeleteFunc(slices.Clone(servers), func(s *Server) bool {
+			return atomic.LoadInt32(&s.busy) > atomic.LoadInt32(&least.busy)
+		})
+		best := slices.MaxFunc(candidates, func(a, b *Server) int { return cmp.Compare(scores[a], scores[b]) })
+		candidates = slices.DeleteFunc(candidates, func(s *Server) bool { return scores[s] < scores[best] })
+	}
+	// The candidate following the last server by name, so equal candidates take turns
+	var first, after *Server
+	for _, s := range candidates {
+		name := s.name()
+		if first == nil || name < first.name() {
+			first = s
+		}
+		if name > r.last && (after == nil || name < after.name()) {
+			after = s
+		}
+	}
+	if after != nil {
+		return after
+	}
+	return first
+}
+
+// turns returns how many targets the server may take now. With the pull and sticky
+// strategies a server takes targets for all its free slots, with the other
+// strategies targets are handed out one at a time to the picked server. Once a
+// server has taken its turn, the following server is picked and signalled, so
+// waiting servers don't have to poll. Turns aren't taken while the queue is empty.
+// Servers checked in another region only take targets while no region-local server can.
+// Parameters:
+//   - s: Server asking for targets
+//   - n: Free slots of the server
+//
+// Returns:
+//   - int: Number of targets the server may take
+func (w *Worker) turns(s *Server, n int) int {
+	switch w.Rotation {
+	case rotationRoundRobin, rotationRandom, rotationLeastConns:
+	default:
+		if n > 0 && !w.local(s.Region) && w.localReady() {
+			return 0
+		}
+		return n
+	}
+	if n <= 0 || w.pendingTargets() == 0 {
+		return n
+	}
+
+	r := &w.rotation
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.next == nil || !w.free(r.next) || w.banned(r.next.name()) {
+		if r.next = r.pick(w.Rotation, w.preferLocal(w.ready())); r.next == nil {
+			return 0
+		}
+	}
+	if r.next != s {
+		r.next.signal()
+		return 0
+	}
+
+	r.last = s.name()
+	servers := w.ready()
+	if w.Rotation == rotationLeastConns {
+		// The server taking the turn is left out, its slot is about to be used
+		servers = slices.DeleteFunc(servers, func(o *Server) bool { return o == s })
+	}
+	r.next = r.pick(w.Rotation, w.preferLocal(servers))
+	if r.next != nil {
+		r.next.signal()
+	}
+	return 1
+}
+
+// signal wakes up the server if it is waiting for its turn.
+func (s *Server) signal() {
+	select {
+	case s.turn <- struct{}{}:
+	default:
+	}
+}
+
+// awaitTurn waits until the server is picked for the next target, at most rotationPoll.
+func (s *Server) awaitTurn() {
+	t := time.NewTimer(rotationPoll)
+	defer t.Stop()
+
+	select {
+	case <-s.turn:
+	case <-t.C:
+	}
+}
+
+// bound reports whether the server may take a target of the host with sticky
+// rotation, binding the host to the server if it isn't bound yet.
+// Parameters:
+//   - s: Server asking for the target
+//   - t: Target URL
+//
+// Returns:
+//   - bool: True if the host is bound to the server
+func (r *rotator) bound(s *Server, t string) bool {
+	host := targetHost(t)
+
+	r.hm.Lock()
+	defer r.hm.Unlock()
+
+	if r.hosts == nil {
+		r.hosts = map[string]*Server{}
+	}
+	if b, ok := r.hosts[host]; ok && b != s && atomic.LoadUint32(&b.Disabled) == 0 {
+		return false
+	}
+	r.hosts[host] = s
+	return true
+}
+
+// release unbinds the hosts bound to a server leaving the pool, so other servers take them over.
+// Parameters:
+//   - s: Server leaving the pool
+func (r *rotator) release(s *Server) {
+	r.hm.Lock()
+	for host, b := range r.hosts {
+		if b == s {
+			delete(r.hosts, host)
+		}
+	}
+	r.hm.Unlock()
+
+	r.m.Lock()
+	if r.next == s {
+		r.next = nil
+	}
+	r.m.Unlock()
+}
+
+// pendingTargets returns the number of queued targets in both lanes.
+// Returns:
+//   - int: Number of queued targets
+func (w *Worker) pendingTargets() int {
+	w.m.RLock()
+	defer w.m.RUnlock()
+	return len(w.targets) + len(w.priority)
+}
+
+// shiftFor removes up to n targets the server may take from the front of the queue.
+// With sticky rotation, targets of hosts bound to other servers are skipped.
+// Parameters:
+//   - s: Server taking the targets
+//   - n: Maximum number of targets
+//
+// Returns:
+//   - []string: Targets taken out of the queue
+func (w *Worker) shiftFor(s *Server, n int) []string {
+	if w.Rotation != rotationSticky {
+		return w.shift(n)
+	}
+	return w.shiftBound(&w.targets, s, n)
+}
+
+// shiftPriorityFor removes up to n priority targets the server may take.
+// Parameters:
+//   - s: Server taking the targets
+//   - n: Maximum number of targets
+//
+// Returns:
+//   - []string: Targets taken out of the priority lane
+func (w *Worker) shiftPriorityFor(s *Server, n int) []string {
+	if w.Rotation != rotationSticky {
+		return w.shiftPriority(n)
+	}
+	return w.shiftBound(&w.priority, s, n)
+}
+
+// shiftBound removes up to n targets of hosts bound to the server, or unbound ones, from the queue.
+// Parameters:
+//   - queue: Queue to take the targets from
+//   - s: Server taking the targets
+//   - n: Maximum number of targets
+//
+// Returns:
+//   - []string: Targets taken out of the queue
+func (w *Worker) shiftBound(queue *[]string, s *Server, n int) []string {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	var items []string
+	rest := (*queue)[:0:0]
+	for _, t := range *queue {
+		if len(items) < n && w.rotation.bound(s, t) {
+			items = append(items, t)
+		} else {
+			rest = append(rest, t)
+		}
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	*queue = rest
+	w.recordQueue(false, items...)
+	return items
+}
@@ -0,0 +1,163 @@
+package httptines
+
+import (
+	"net/url"
+	"sync/atomic"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Rotation", func() {
+	var (
+		w       *Worker
+		a, b, c *Server
+	)
+
+	newServer := func(addr string) *Server {
+		u, _ := url.Parse("http://" + addr)
+		s := &Server{URL: u, Capacity: 2}
+		w.servers.add(s)
+		return s
+	}
+
+	// taker returns the first server allowed to take a target when the servers ask in turn.
+	taker := func() *Server {
+		for _, s := range []*Server{a, b, c} {
+			if w.turns(s, 2) > 0 {
+				return s
+			}
+		}
+		return nil
+	}
+
+	BeforeEach(func() {
+		w = &Worker{targets: []string{"http://example.com/1", "http://example.com/2"}}
+		a = newServer("1.1.1.1:80")
+		b = newServer("2.2.2.2:80")
+		c = newServer("3.3.3.3:80")
+	})
+
+	Describe("turns()", func() {
+		It("lets every server take all its free slots with pull", func() {
+			w.Rotation = rotationPull
+			Expect(w.turns(a, 2)).To(Equal(2))
+			Expect(w.turns(b, 2)).To(Equal(2))
+		})
+
+		It("lets servers of another region take targets only while no local server can", func() {
+			w.Rotation, w.Region = rotationPull, "eu"
+			a.Region, b.Region, c.Region = "eu", "us", "us"
+			Expect(w.turns(b, 2)).To(BeZero())
+
+			atomic.StoreInt32(&a.busy, 2)
+			Expect(w.turns(b, 2)).To(Equal(2))
+		})
+
+		It("picks servers of the worker's region first", func() {
+			w.Rotation, w.Region = rotationRoundRobin, "eu"
+			a.Region, b.Region, c.Region = "us", "eu", "eu"
+			Expect([]*Server{taker(), taker(), taker()}).To(Equal([]*Server{b, c, b}))
+
+			atomic.StoreUint32(&b.Disabled, 1)
+			atomic.StoreUint32(&c.Disabled, 1)
+			Expect(taker()).To(BeIdenticalTo(a))
+		})
+
+		It("hands out targets in turn with round-robin", func() {
+			w.Rotation = rotationRoundRobin
+			Expect([]*Server{taker(), taker(), taker(), taker()}).To(Equal([]*Server{a, b, c, a}))
+		})
+
+		It("skips servers without a free slot or disabled", func() {
+			w.Rotation = rotationRoundRobin
+			atomic.StoreInt32(&b.busy, 2)
+			atomic.StoreUint32(&c.Disabled, 1)
+			Expect([]*Server{taker(), taker()}).To(Equal([]*Server{a, a}))
+		})
+
+		It("picks the server with the fewest in-flight requests with least-connections", func() {
+			w.Rotation = rotationLeastConns
+			atomic.StoreInt32(&a.busy, 1)
+			atomic.StoreInt32(&c.busy, 1)
+			Expect(taker()).To(BeIdenticalTo(b))
+
+			atomic.StoreInt32(&b.busy, 1)
+			Expect(taker()).To(BeIdenticalTo(c))
+		})
+
+		It("prefers the server with the best score among the least busy", func() {
+			w.Rotation = rotationLeastConns
+			b.past = proxyRecord{Negative: 10}
+			Expect([]*Server{taker(), taker(), taker()}).To(Equal([]*Server{a, c, a}))
+		})
+
+		It("favors servers with a better score with random", func() {
+			w.Rotation = rotationRandom
+			a.past = proxyRecord{Negative: 1000}
+			atomic.StoreInt32(&c.busy, 2)
+			picks := map[*Server]int{}
+			for range 50 {
+				picks[taker()]++
+			}
+			Expect(picks[b]).To(BeNumerically(">", picks[a]))
+		})
+
+		It("picks a ready server with random", func() {
+			w.Rotation = rotationRandom
+			atomic.StoreInt32(&a.busy, 2)
+			for range 10 {
+				Expect(taker()).To(Or(BeIdenticalTo(b), BeIdenticalTo(c)))
+			}
+		})
+
+		It("signals the server picked for the next target", func() {
+			w.Rotation = rotationRoundRobin
+			for _, s := range []*Server{a, b, c} {
+				s.turn = make(chan struct{}, 1)
+			}
+
+			Expect(w.turns(a, 2)).To(Equal(1))
+			Expect(b.turn).To(Receive())
+			Expect(c.turn).NotTo(Receive())
+
+			Expect(w.turns(c, 2)).To(BeZero())
+			Expect(b.turn).To(Receive())
+		})
+
+		It("doesn't take turns while the queue is empty", func() {
+			w.Rotation = rotationRoundRobin
+			w.targets = nil
+			Expect(w.turns(a, 2)).To(Equal(2))
+			Expect(w.turns(b, 2)).To(Equal(2))
+		})
+	})
+
+	Describe("shiftFor()", func() {
+		BeforeEach(func() {
+			w.Rotation = rotationSticky
+			w.targets = []string{"http://one.com/1", "http://two.com/1", "http://one.com/2", "http://two.com/2"}
+		})
+
+		It("keeps the targets of a host on the same server", func() {
+			Expect(w.shiftFor(a, 1)).To(Equal([]string{"http://one.com/1"}))
+			Expect(w.shiftFor(b, 2)).To(Equal([]string{"http://two.com/1", "http://two.com/2"}))
+			Expect(w.shiftFor(b, 2)).To(BeEmpty())
+			Expect(w.shiftFor(a, 2)).To(Equal([]string{"http://one.com/2"}))
+		})
+
+		It("hands the hosts of a server leaving the pool over", func() {
+			w.shiftFor(a, 1)
+			Expect(w.shiftFor(b, 4)).To(Equal([]string{"http://two.com/1", "http://two.com/2"}))
+
+			w.rotation.release(a)
+			Expect(w.shiftFor(b, 4)).To(Equal([]string{"http://one.com/2"}))
+		})
+
+		It("hands the hosts of a disabled server over", func() {
+			w.shiftFor(a, 1)
+			atomic.StoreUint32(&a.Disabled, 1)
+			Expect(w.shiftFor(b, 4)).To(HaveLen(3))
+		})
+	})
+})
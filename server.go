@@ -3,12 +3,20 @@ package httptines
 import (
 	"context"
 	"math"
+	"net/http"
 	"net/url"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
+// diagHeaders lists response headers that reveal caching or content altering proxies.
+var diagHeaders = []string{"Via", "X-Cache", "X-Cache-Lookup", "X-Forwarded-For", "Proxy-Agent", "Proxy-Connection", "Age"}
+
+// diagValuesLimit is the maximum number of distinct values kept per diagnostic header.
+const diagValuesLimit = 5
+
 // Server represents a proxy server with its current state and performance metrics.
 type Server struct {
 	// URL is the proxy server's URL
@@ -25,6 +33,26 @@ type Server struct {
 	Positive int `json:"positive"`
 	// Negative is the count of failed requests processed by this server
 	Negative int `json:"negative"`
+	// Redirects is the count of 3xx responses without a Location header
+	Redirects int `json:"redirects"`
+	// Cached indicates that the proxy was detected serving cached content
+	Cached bool `json:"cached"`
+	// Anonymity is the anonymity level of the proxy: transparent, anonymous or elite, empty if unknown
+	Anonymity string `json:"anonymity"`
+	// Country is the ISO 3166 country code of the proxy, empty if unknown
+	Country string `json:"country"`
+	// Sent is the number of bytes sent in requests through this server
+	Sent int64 `json:"sent"`
+	// Overhead is the number of request header bytes included in Sent
+	Overhead int64 `json:"overhead"`
+	// Received is the number of bytes received in responses through this server
+	Received int64 `json:"received"`
+	// Region is the region of the worker that validated the proxy, empty if not set
+	Region string `json:"region"`
+	// CheckLatency is the response time in milliseconds measured while validating the proxy
+	CheckLatency int `json:"checkLatency"`
+	// Tests contains the results of the check against each test URL
+	Tests map[string]bool `json:"tests"`
 
 	// The array used to determine 5 fail in row
 	l5 [5]bool
@@ -32,12 +60,49 @@ type Server struct {
 	l5i int
 	// Timeout specifies the request timeout in seconds
 	timeout time.Duration
+	// header contains headers sent on the CONNECT request to the proxy
+	header http.Header
+	// session holds templated credentials, nil if the proxy doesn't use sessions
+	session *sessionState
+	// maxBody is the maximum decompressed body size in bytes, zero means unlimited
+	maxBody int
+	// maxRatio is the maximum compression ratio of a body, zero means unlimited
+	maxRatio int
+	// agent is the user agent used for capacity checks
+	agent string
+	// tor rotates the circuit of the Tor proxy, nil for other proxies
+	tor *torCircuit
+	// transport creates the round tripper for requests through the proxy, nil for the default one
+	transport func(proxy *url.URL) http.RoundTripper
+	conns     *connPool
+	// adaptive derives the timeout from avgLatency, nil to use timeout
+	adaptive *adaptiveTimeout
+	// avgLatency is the exponentially weighted average latency of successful requests in milliseconds
+	avgLatency float64
+	// rate limits the requests per second sent through the proxy, nil if unlimited
+	rate *proxyLimiter
+	// slot is the number of the gateway slot the server stands for, zero for other proxies
+	slot int
+	// ramp limits the concurrency of a new proxy while it warms up, nil if WarmUp is disabled
+	ramp *ramp
+	// busy is the number of in-flight requests through the server
+	busy int32
+	// turn is signalled when the server is picked for the next target with rotation
+	turn chan struct{}
+	// past holds the statistics of previous runs, zero without ProxyHistory
+	past proxyRecord
+	// restored is the time a proxy loaded from the alive cache was last seen alive, zero for checked proxies
+	restored time.Time
+	// headers contains distinct values of diagnostic response headers
+	headers map[string][]string
 	// m is a mutex for protecting concurrent access to server data
 	m sync.RWMutex
 	// ctx is the context for managing server lifecycle
 	ctx context.Context
 	// cancel is the function to cancel the server's context
 	cancel context.CancelFunc
+	// cm guards ctx and cancel, which are replaced when a disabled server is checked again
+	cm sync.Mutex
 }
 
 // Start marks the beginning of a request and returns the start time
@@ -65,12 +130,14 @@ func (s *Server) finish(startedAt time.Time, err error) srvMap {
 
 	if err == nil {
 		s.Positive++
+		s.observeLatency(s.Latency)
 		s.l5[s.l5i] = true
 		s.updateL5(true)
 	} else {
 		s.Negative++
 		s.updateL5(false)
 	}
+	s.ramp.record(err == nil, s.Capacity)
 
 	if s.fiveFailInRow() {
 		s.disable()
@@ -79,10 +146,89 @@ func (s *Server) finish(startedAt time.Time, err error) srvMap {
 	return s.toMap()
 }
 
+// traffic records the bytes transferred by a request.
+// Parameters:
+//   - sent: Bytes sent
+//   - overhead: Request header bytes included in sent
+//   - received: Bytes received
+func (s *Server) traffic(sent, overhead, received int64) {
+	s.m.Lock()
+	s.Sent += sent
+	s.Overhead += overhead
+	s.Received += received
+	s.m.Unlock()
+}
+
+// redirect records a 3xx response without a Location header.
+func (s *Server) redirect() {
+	s.m.Lock()
+	s.Redirects++
+	s.m.Unlock()
+}
+
+// proxy returns the proxy URL for a request to the given target.
+// Parameters:
+//   - target: URL of the request
+//
+// Returns:
+//   - *url.URL: Proxy URL with session credentials applied
+func (s *Server) proxy(target string) *url.URL {
+	if s.session == nil {
+		return s.URL
+	}
+
+	u := *s.URL
+	u.User = s.session.userinfo(target)
+	return &u
+}
+
+// deadline returns the context of a request started at the given time. It is cancelled
+// with the server's context and expires once the request timeout has passed, so the
+// handler processing the response can stop along with the fetch.
+// Parameters:
+//   - startedAt: Time the request was started
+//
+// Returns:
+//   - context.Context: Request context
+//   - context.CancelFunc: Function releasing the context
+func (s *Server) deadline(startedAt time.Time) (context.Context, context.CancelFunc) {
+	timeout := s.requestTimeout()
+	if timeout <= 0 {
+		return context.WithCancel(s.context())
+	}
+	return context.WithDeadline(s.context(), startedAt.Add(timeout))
+}
+
 // disable disables the server and cancels its context.
 func (s *Server) disable() {
 	atomic.AddUint32(&s.Disabled, 1)
-	s.cancel()
+	s.stop()
+}
+
+// context returns the context of the server.
+// Returns:
+//   - context.Context: Server's context
+func (s *Server) context() context.Context {
+	s.cm.Lock()
+	defer s.cm.Unlock()
+	return s.ctx
+}
+
+// stop cancels the context of the server.
+func (s *Server) stop() {
+	s.cm.Lock()
+	cancel := s.cancel
+	s.cm.Unlock()
+	cancel()
+}
+
+// renew replaces the context of the server, e.g. before a disabled server is checked again.
+// Parameters:
+//   - parent: Context the new context is derived from
+func (s *Server) renew(parent context.Context) {
+	s.cm.Lock()
+	s.ctx, s.cancel = context.WithCancel(parent)
+	s.cm.Unlock()
 }
 
 // toMap converts server statistics to a map
@@ -90,17 +236,59 @@ func (s *Server) disable() {
 //   - srvMap: Server statistics as a map
 func (s *Server) toMap() srvMap {
 	return srvMap{
-		"url":        s.URL.String(),
-		"disabled":   s.Disabled,
-		"latency":    s.Latency,
-		"capacity":   s.Capacity,
-		"requests":   s.Requests,
-		"positive":   s.Positive,
-		"negative":   s.Negative,
-		"efficiency": s.efficiency(),
+		"url":          s.name(),
+		"disabled":     s.Disabled,
+		"latency":      s.Latency,
+		"capacity":     s.Capacity,
+		"requests":     s.Requests,
+		"positive":     s.Positive,
+		"negative":     s.Negative,
+		"redirects":    s.Redirects,
+		"cached":       s.Cached,
+		"anonymity":    s.Anonymity,
+		"country":      s.Country,
+		"sent":         s.Sent,
+		"overhead":     s.Overhead,
+		"received":     s.Received,
+		"efficiency":   s.efficiency(),
+		"headers":      s.copyHeaders(),
+		"region":       s.Region,
+		"checkLatency": s.CheckLatency,
+		"tests":        s.Tests,
+		"timeout":      s.currentTimeout().Milliseconds(),
 	}
 }
 
+// capture records distinct values of diagnostic headers from a response
+// Parameters:
+//   - h: Response headers
+func (s *Server) capture(h http.Header) {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	for _, k := range diagHeaders {
+		v := h.Get(k)
+		if v == "" || len(s.headers[k]) >= diagValuesLimit || slices.Contains(s.headers[k], v) {
+			continue
+		}
+		if s.headers == nil {
+			s.headers = map[string][]string{}
+		}
+		s.headers[k] = append(s.headers[k], v)
+	}
+}
+
+// copyHeaders returns a copy of the captured diagnostic headers
+// Returns:
+//   - map[string][]string: Captured header values
+func (s *Server) copyHeaders() map[string][]string {
+	h := make(map[string][]string, len(s.headers))
+	for k, v := range s.headers {
+		h[k] = slices.Clone(v)
+	}
+	return h
+}
+
 // efficiency calculates the server's success rate
 // Returns:
 //   - float64: Success rate as a percentage
@@ -128,43 +316,72 @@ func (s *Server) fiveFailInRow() bool {
 // Parameters:
 //   - strategy: Strategy minimal or auto
 //   - target: URL to test capacity against
-func (s *Server) computeCapacity(strategy, target string) {
+//   - rule: Expected content of the responses, nil to accept any body
+//   - limit: Highest capacity of the auto strategy
+func (s *Server) computeCapacity(strategy, target string, rule *IntegrityRule, limit int) {
 	if strategy == "minimal" {
-		s.minimalCapacity(target)
+		s.minimalCapacity(target, rule)
 	} else {
-		s.autoAdjustCapacity(target)
+		s.autoAdjustCapacity(target, rule, limit)
+	}
+}
+
+// probe requests a test URL through the server and checks the body.
+// Parameters:
+//   - ctx: Context of the request
+//   - target: Test URL
+//   - rule: Expected content of the body, nil to accept any body
+//
+// Returns:
+//   - error: Any error that occurred during the request, or the violated expectation
+func (s *Server) probe(ctx context.Context, target string, rule *IntegrityRule) error {
+	rep, err := request(ctx, target, s, reqOpts{agent: s.agent})
+	if err != nil || rule == nil {
+		return err
 	}
+	return rule.Check(rep.body)
 }
 
-// autoAdjustCapacity automatically determines optimal server capacity
+// autoAdjustCapacity automatically determines optimal server capacity. Probing
+// stops at the first failure or once limit concurrent requests succeeded.
 // Parameters:
 //   - target: URL to test capacity against
-func (s *Server) autoAdjustCapacity(target string) {
+//   - rule: Expected content of the responses, nil to accept any body
+//   - limit: Highest capacity to probe for
+func (s *Server) autoAdjustCapacity(target string, rule *IntegrityRule, limit int) {
 	wg := sync.WaitGroup{}
 	capacity := uint32(1)
 	stop := uint32(0)
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(s.context())
 	defer cancel()
 
 	for {
+		startedAt := time.Now()
 		for range capacity {
 			wg.Add(1)
 			go func() {
 				defer wg.Done()
 
-				if _, err := request(ctx, target, s); err != nil {
+				if err := s.probe(ctx, target, rule); err != nil {
 					atomic.AddUint32(&stop, 1)
 				}
 			}()
 		}
 		wg.Wait()
 
+		if capacity == 1 {
+			s.CheckLatency = int(time.Since(startedAt).Milliseconds())
+		}
+
 		if atomic.LoadUint32(&stop) > 0 {
 			if atomic.LoadUint32(&capacity) == 1 {
 				atomic.StoreUint32(&capacity, 0)
 			}
 			break
 		}
+		if int(capacity) >= limit {
+			break
+		}
 
 		atomic.AddUint32(&capacity, 1)
 	}
@@ -175,12 +392,15 @@ func (s *Server) autoAdjustCapacity(target string) {
 // minimalCapacity sets minimal server capacity
 // Parameters:
 //   - target: URL to test capacity against
-func (s *Server) minimalCapacity(target string) {
-	ctx, cancel := context.WithCancel(context.Background())
+//   - rule: Expected content of the response, nil to accept any body
+func (s *Server) minimalCapacity(target string, rule *IntegrityRule) {
+	ctx, cancel := context.WithCancel(s.context())
 	defer cancel()
 
-	if _, err := request(ctx, target, s); err == nil {
+	startedAt := time.Now()
+	if err := s.probe(ctx, target, rule); err == nil {
 		s.Capacity = 1
+		s.CheckLatency = int(time.Since(startedAt).Milliseconds())
 	}
 }
 
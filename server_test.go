@@ -2,7 +2,9 @@ package httptines
 
 import (
 	"context"
+	"net/http"
 	"net/url"
+	"strconv"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -105,6 +107,59 @@ var _ = Describe("Server", func() {
 		})
 	})
 
+	Describe("proxy()", func() {
+		When("no session is configured", func() {
+			It("returns the server URL", func() {
+				Expect(server.proxy("http://test1.com")).To(Equal(serverURL))
+			})
+		})
+
+		It("applies session credentials", func() {
+			server.session = newSessionState(Session{Username: "user-{id}"})
+
+			u := server.proxy("http://test1.com")
+			Expect(u.User.Username()).To(Equal("user-" + server.session.id))
+			Expect(server.URL.User).To(BeNil())
+		})
+	})
+
+	Describe("traffic()", func() {
+		It("accumulates transferred bytes", func() {
+			server.traffic(100, 60, 1000)
+			server.traffic(50, 40, 500)
+
+			Expect(server.Sent).To(Equal(int64(150)))
+			Expect(server.Overhead).To(Equal(int64(100)))
+			Expect(server.Received).To(Equal(int64(1500)))
+		})
+	})
+
+	Describe("redirect()", func() {
+		It("increments the redirects counter", func() {
+			server.redirect()
+			Expect(server.Redirects).To(Equal(1))
+		})
+	})
+
+	Describe("capture()", func() {
+		It("records distinct values of diagnostic headers", func() {
+			server.capture(http.Header{"Via": {"1.1 squid"}, "Content-Type": {"text/html"}})
+			server.capture(http.Header{"Via": {"1.1 squid"}, "X-Cache": {"HIT"}})
+
+			Expect(server.headers).To(Equal(map[string][]string{
+				"Via":     {"1.1 squid"},
+				"X-Cache": {"HIT"},
+			}))
+		})
+
+		It("limits the number of values per header", func() {
+			for i := range diagValuesLimit + 2 {
+				server.capture(http.Header{"Age": {strconv.Itoa(i)}})
+			}
+			Expect(server.headers["Age"]).To(HaveLen(diagValuesLimit))
+		})
+	})
+
 	Describe("toMap()", func() {
 		It("should convert server stats to map", func() {
 			server.Positive = 10
@@ -112,6 +167,7 @@ var _ = Describe("Server", func() {
 			server.Latency = 100
 			server.Requests = 3
 			server.Capacity = 5
+			server.Redirects = 1
 
 			result := server.toMap()
 			Expect(result).To(HaveKeyWithValue("url", server.URL.String()))
@@ -121,7 +177,10 @@ var _ = Describe("Server", func() {
 			Expect(result).To(HaveKeyWithValue("requests", 3))
 			Expect(result).To(HaveKeyWithValue("positive", 10))
 			Expect(result).To(HaveKeyWithValue("negative", 2))
+			Expect(result).To(HaveKeyWithValue("redirects", 1))
+			Expect(result).To(HaveKeyWithValue("headers", map[string][]string{}))
 			Expect(result).To(HaveKeyWithValue("efficiency", 83.0))
+			Expect(result).To(HaveKeyWithValue("timeout", server.timeout.Milliseconds()))
 		})
 	})
 })
@@ -0,0 +1,110 @@
+package httptines
+
+import (
+	"math/rand"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Session describes templated proxy credentials used by rotating residential providers.
+// The "{id}" placeholder in Username and Password is replaced with the current session ID.
+type Session struct {
+	// Username template, e.g. "user-session-{id}"
+	Username string
+	// Password template
+	Password string
+	// Rotate determines when a new session ID is generated: "requests", "host" or "failure".
+	//
+	// - "requests" A new ID is generated every Every requests.
+	// - "host" Each target host gets its own sticky ID.
+	// - "failure" The ID is kept until a request fails.
+	Rotate string
+	// Every defines the number of requests per session for the "requests" policy
+	Every int
+}
+
+// sessionState holds the runtime session state of a single server.
+type sessionState struct {
+	cfg   Session
+	id    string
+	count int
+	hosts map[string]string
+	m     sync.Mutex
+}
+
+// newSessionState creates the session state for the given configuration.
+// Parameters:
+//   - cfg: Session configuration
+//
+// Returns:
+//   - *sessionState: Session state with a fresh ID
+func newSessionState(cfg Session) *sessionState {
+	if cfg.Every <= 0 {
+		cfg.Every = 1
+	}
+	return &sessionState{cfg: cfg, id: sessionID(), hosts: map[string]string{}}
+}
+
+// userinfo returns the credentials for a request to the given target.
+// Parameters:
+//   - target: URL of the request
+//
+// Returns:
+//   - *url.Userinfo: Credentials with the session ID applied
+func (st *sessionState) userinfo(target string) *url.Userinfo {
+	st.m.Lock()
+	defer st.m.Unlock()
+
+	var id string
+	switch st.cfg.Rotate {
+	case "host":
+		host := target
+		if u, err := url.Parse(target); err == nil {
+			host = u.Host
+		}
+		if id = st.hosts[host]; id == "" {
+			id = sessionID()
+			st.hosts[host] = id
+		}
+	case "failure":
+		id = st.id
+	default:
+		if st.count >= st.cfg.Every {
+			st.id, st.count = sessionID(), 0
+		}
+		st.count++
+		id = st.id
+	}
+
+	user := strings.ReplaceAll(st.cfg.Username, "{id}", id)
+	if st.cfg.Password == "" {
+		return url.User(user)
+	}
+	return url.UserPassword(user, strings.ReplaceAll(st.cfg.Password, "{id}", id))
+}
+
+// failed rotates the session ID after a failed request.
+// Parameters:
+//   - target: URL of the failed request
+func (st *sessionState) failed(target string) {
+	st.m.Lock()
+	defer st.m.Unlock()
+
+	switch st.cfg.Rotate {
+	case "failure":
+		st.id = sessionID()
+	case "host":
+		if u, err := url.Parse(target); err == nil {
+			delete(st.hosts, u.Host)
+		}
+	}
+}
+
+// sessionID generates a random session ID.
+// Returns:
+//   - string: Random alphanumeric ID
+func sessionID() string {
+	return strconv.FormatInt(rand.Int63(), 36)
+}
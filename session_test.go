@@ -0,0 +1,56 @@
+package httptines
+
+import (
+	"net/url"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Session", func() {
+	username := func(u *url.Userinfo) string { return u.Username() }
+
+	Describe("userinfo()", func() {
+		It("applies the session ID to the templates", func() {
+			st := newSessionState(Session{Username: "user-session-{id}", Password: "pass-{id}"})
+
+			u := st.userinfo("http://test1.com")
+			p, _ := u.Password()
+			Expect(u.Username()).To(Equal("user-session-" + st.id))
+			Expect(p).To(Equal("pass-" + st.id))
+		})
+
+		When("rotating per requests", func() {
+			It("changes the ID every N requests", func() {
+				st := newSessionState(Session{Username: "{id}", Rotate: "requests", Every: 2})
+
+				first := username(st.userinfo("http://test1.com"))
+				Expect(username(st.userinfo("http://test1.com"))).To(Equal(first))
+				Expect(username(st.userinfo("http://test1.com"))).NotTo(Equal(first))
+			})
+		})
+
+		When("rotating per host", func() {
+			It("keeps a sticky ID for each host", func() {
+				st := newSessionState(Session{Username: "{id}", Rotate: "host"})
+
+				a := username(st.userinfo("http://a.com/1"))
+				b := username(st.userinfo("http://b.com/1"))
+				Expect(a).NotTo(Equal(b))
+				Expect(username(st.userinfo("http://a.com/2"))).To(Equal(a))
+			})
+		})
+	})
+
+	Describe("failed()", func() {
+		It("rotates the ID for the failure policy", func() {
+			st := newSessionState(Session{Username: "{id}", Rotate: "failure"})
+
+			first := username(st.userinfo("http://test1.com"))
+			Expect(username(st.userinfo("http://test1.com"))).To(Equal(first))
+
+			st.failed("http://test1.com")
+			Expect(username(st.userinfo("http://test1.com"))).NotTo(Equal(first))
+		})
+	})
+})
@@ -0,0 +1,270 @@
+package httptines
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+)
+
+// ShutdownReport describes the state of an aborted run.
+type ShutdownReport struct {
+	// Reason describes why the run was aborted
+	Reason string
+	// Canceled contains the targets that were in flight when the run was aborted and didn't complete
+	Canceled []string
+	// Unfinished contains all targets that were not processed
+	Unfinished []string
+	// Checkpoint is the file the unfinished targets were written to, empty if none was written
+	Checkpoint string
+	// Resume describes how to continue the run
+	Resume string
+}
+
+// String returns a multi-line description of the report.
+// Returns:
+//   - string: Report description
+func (r *ShutdownReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "run aborted: %s\n", r.Reason)
+	fmt.Fprintf(&b, "  canceled in-flight targets: %d\n", len(r.Canceled))
+	for _, t := range r.Canceled {
+		fmt.Fprintf(&b, "    %s\n", t)
+	}
+	fmt.Fprintf(&b, "  unfinished targets: %d\n", len(r.Unfinished))
+	if r.Checkpoint != "" {
+		fmt.Fprintf(&b, "  checkpoint: %s\n", r.Checkpoint)
+	}
+	fmt.Fprintf(&b, "  resume: %s", r.Resume)
+	return b.String()
+}
+
+// Shutdown stops dequeuing targets and fetching proxies, then waits for in-flight
+// requests to finish. If the context is done first, in-flight requests are cancelled.
+// Parameters:
+//   - ctx: Context limiting how long in-flight requests are awaited
+//
+// Returns:
+//   - []string: Targets that were not processed
+//   - error: The context's error if the deadline was reached before draining
+func (w *Worker) Shutdown(ctx context.Context) ([]string, error) {
+	w.setReason("shutdown requested")
+	w.setState(StateStopped)
+	w.stop()
+
+	drained := make(chan struct{})
+	go func() {
+		w.inflight.Wait()
+		close(drained)
+	}()
+
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+		if w.abort != nil {
+			w.abort()
+		}
+		<-drained
+	}
+
+	unfinished := w.Unfinished()
+	w.wlog(fmt.Sprintf("shutdown: %d targets unfinished", len(unfinished)))
+
+	return unfinished, err
+}
+
+// Unfinished returns the targets that are queued in either lane, in-flight or waiting to be retried.
+// Returns:
+//   - []string: Unfinished targets
+func (w *Worker) Unfinished() []string {
+	w.m.RLock()
+	defer w.m.RUnlock()
+
+	unfinished := slices.Concat(w.priority, w.targets)
+	for t, n := range w.pending {
+		for range n {
+			unfinished = append(unfinished, t)
+		}
+	}
+	return unfinished
+}
+
+// hold marks a target as taken out of the queue but not finished yet.
+// Parameters:
+//   - t: Target URL
+func (w *Worker) hold(t string) {
+	w.m.Lock()
+	if w.pending == nil {
+		w.pending = map[string]int{}
+	}
+	w.pending[t]++
+	w.m.Unlock()
+}
+
+// unhold removes the mark set by hold.
+// Parameters:
+//   - t: Target URL
+func (w *Worker) unhold(t string) {
+	w.m.Lock()
+	if w.pending[t] <= 1 {
+		delete(w.pending, t)
+	} else {
+		w.pending[t]--
+	}
+	w.m.Unlock()
+}
+
+// inFlight returns the targets that are being processed or waiting to be retried.
+// Returns:
+//   - []string: Targets taken out of the queue but not finished
+func (w *Worker) inFlight() []string {
+	w.m.RLock()
+	defer w.m.RUnlock()
+
+	var targets []string
+	for t, n := range w.pending {
+		for range n {
+			targets = append(targets, t)
+		}
+	}
+	slices.Sort(targets)
+	return targets
+}
+
+// setReason records why the run is aborted. The first reason is kept.
+// Parameters:
+//   - reason: Description of the cause
+func (w *Worker) setReason(reason string) {
+	w.m.Lock()
+	if w.reason == "" {
+		w.reason = reason
+	}
+	w.m.Unlock()
+}
+
+// abortReport builds the shutdown report of an aborted run, writes the
+// checkpoint and logs the report.
+// Parameters:
+//   - inflight: Targets that were in flight when the run was aborted
+//
+// Returns:
+//   - *ShutdownReport: Shutdown report
+func (w *Worker) abortReport(inflight []string) *ShutdownReport {
+	w.m.RLock()
+	reason := w.reason
+	w.m.RUnlock()
+	if reason == "" {
+		reason = "context canceled"
+	}
+
+	r := &ShutdownReport{Reason: reason, Unfinished: w.Unfinished()}
+	for _, t := range inflight {
+		if slices.Contains(r.Unfinished, t) {
+			r.Canceled = append(r.Canceled, t)
+		}
+	}
+
+	r.Resume = "run again with the unfinished targets"
+	if w.Checkpoint != "" {
+		if err := w.writeCheckpoint(r.Unfinished); err != nil {
+			w.werr(fmt.Sprintf("error writing checkpoint %s: %v", w.Checkpoint, err))
+		} else {
+			r.Checkpoint = w.Checkpoint
+			r.Resume = fmt.Sprintf("run again with the targets listed in %s", w.Checkpoint)
+		}
+	}
+
+	w.werr(r.String())
+	return r
+}
+
+// writeCheckpoint writes the unfinished targets to the Checkpoint file. With
+// ClaimDir set, the checkpoint may be shared by several processes, so under the
+// claim lock the targets are merged with those already in the file, and targets
+// finished by any process are left out.
+// Parameters:
+//   - targets: Unfinished targets of this process
+//
+// Returns:
+//   - error: Any error that occurred while writing
+func (w *Worker) writeCheckpoint(targets []string) error {
+	if w.claims == nil {
+		return writeCheckpoint(w.Checkpoint, targets)
+	}
+
+	return w.claims.locked(func() error {
+		saved, err := readCheckpoint(w.Checkpoint)
+		if err != nil {
+			return err
+		}
+		merged, err := w.claims.pending(mergeTargets(saved, targets))
+		if err != nil {
+			return err
+		}
+		return writeCheckpoint(w.Checkpoint, merged)
+	})
+}
+
+// readCheckpoint reads the targets of a checkpoint file. A missing file yields no targets.
+// Parameters:
+//   - path: File path
+//
+// Returns:
+//   - []string: Targets in the file
+//   - error: Any error that occurred while reading
+func readCheckpoint(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(data)), nil
+}
+
+// mergeTargets appends the targets of the second list that aren't in the first one.
+// A target listed several times is kept as many times as in the list having more of it.
+// Parameters:
+//   - a: Targets kept in their order
+//   - b: Targets to add
+//
+// Returns:
+//   - []string: Targets of both lists
+func mergeTargets(a, b []string) []string {
+	listed := make(map[string]int, len(a))
+	for _, t := range a {
+		listed[t]++
+	}
+
+	result := slices.Clone(a)
+	for _, t := range b {
+		if listed[t] > 0 {
+			listed[t]--
+			continue
+		}
+		result = append(result, t)
+	}
+	return result
+}
+
+// writeCheckpoint writes the targets to a file, one per line.
+// Parameters:
+//   - path: File path
+//   - targets: Targets to write
+//
+// Returns:
+//   - error: Any error that occurred while writing
+func writeCheckpoint(path string, targets []string) error {
+	var b strings.Builder
+	for _, t := range targets {
+		b.WriteString(t)
+		b.WriteByte('\n')
+	}
+	return os.WriteFile(path, []byte(b.String()), 0o644)
+}
@@ -0,0 +1,92 @@
+package httptines
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Shutdown", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{
+			stat:    &Stat{State: StateRunning, Servers: map[string]srvMap{}},
+			targets: []string{"http://example.com/1"},
+		}
+		w.ctx, w.cancel = context.WithCancel(context.Background())
+		w.reqCtx, w.abort = context.WithCancel(context.Background())
+	})
+
+	Describe("Shutdown()", func() {
+		It("waits for in-flight targets and reports the queue", func() {
+			w.hold("http://example.com/2")
+			w.inflight.Add(1)
+			go func() {
+				defer w.inflight.Done()
+				time.Sleep(20 * time.Millisecond)
+				w.unhold("http://example.com/2")
+			}()
+
+			unfinished, err := w.Shutdown(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(unfinished).To(Equal([]string{"http://example.com/1"}))
+			Expect(w.stopped()).To(BeTrue())
+			Expect(w.stat.State).To(Equal(StateStopped))
+		})
+
+		It("cancels in-flight requests after the deadline", func() {
+			w.hold("http://example.com/2")
+			w.inflight.Add(1)
+			go func() {
+				defer w.inflight.Done()
+				<-w.requestContext().Done()
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			unfinished, err := w.Shutdown(ctx)
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+			Expect(unfinished).To(ConsistOf("http://example.com/1", "http://example.com/2"))
+		})
+	})
+
+	Describe("Unfinished()", func() {
+		It("includes targets waiting to be retried", func() {
+			w.hold("http://example.com/3")
+			w.hold("http://example.com/3")
+			w.unhold("http://example.com/3")
+			Expect(w.Unfinished()).To(ConsistOf("http://example.com/1", "http://example.com/3"))
+		})
+	})
+
+	Describe("abortReport()", func() {
+		It("reports canceled targets and writes the checkpoint", func() {
+			w.Checkpoint = filepath.Join(GinkgoT().TempDir(), "checkpoint.txt")
+			w.Stop()
+			w.retrigger("http://example.com/2")
+
+			r := w.abortReport([]string{"http://example.com/2", "http://example.com/3"})
+
+			Expect(r.Reason).To(Equal("stopped"))
+			Expect(r.Canceled).To(Equal([]string{"http://example.com/2"}))
+			Expect(r.Unfinished).To(Equal([]string{"http://example.com/1", "http://example.com/2"}))
+			Expect(r.Checkpoint).To(Equal(w.Checkpoint))
+			Expect(r.Resume).To(ContainSubstring(w.Checkpoint))
+			Expect(r.String()).To(ContainSubstring("canceled in-flight targets: 1"))
+
+			data, err := os.ReadFile(w.Checkpoint)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal("http://example.com/1\nhttp://example.com/2\n"))
+		})
+
+		It("defaults to context cancellation", func() {
+			Expect(w.abortReport(nil).Reason).To(Equal("context canceled"))
+		})
+	})
+})
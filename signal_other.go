@@ -0,0 +1,6 @@
+//go:build !unix
+
+package httptines
+
+// handleSignals is a no-op on platforms without SIGUSR1/SIGUSR2.
+func (w *Worker) handleSignals() {}
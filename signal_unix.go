@@ -0,0 +1,29 @@
+//go:build unix
+
+package httptines
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleSignals adjusts the concurrency on SIGUSR1 (up) and SIGUSR2 (down).
+func (w *Worker) handleSignals() {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2)
+	defer signal.Stop(ch)
+
+	for {
+		select {
+		case <-w.quit:
+			return
+		case sig := <-ch:
+			if sig == syscall.SIGUSR1 {
+				w.scale(1)
+			} else {
+				w.scale(-1)
+			}
+		}
+	}
+}
@@ -0,0 +1,379 @@
+package httptines
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink receives processed results, e.g. to store them in a database or a message queue.
+type Sink interface {
+	// Write stores the result. A non-nil error means the result wasn't stored.
+	Write(ctx context.Context, r Result) error
+}
+
+// SinkFunc is an adapter allowing ordinary functions to be used as sinks.
+type SinkFunc func(ctx context.Context, r Result) error
+
+// Write calls f(ctx, r).
+func (f SinkFunc) Write(ctx context.Context, r Result) error {
+	return f(ctx, r)
+}
+
+// JSONLSink appends results to a file as newline-delimited JSON, in the format of
+// the results stream. With MaxSize or MaxAge set, results are written to numbered
+// files instead (results-0001.jsonl, results-0002.jsonl, ...) listed in an index
+// manifest (results-index.json), so long runs don't produce a single huge file.
+// The manifest is updated whenever a file is opened or closed.
+type JSONLSink struct {
+	// Path is the file results are appended to, and the name pattern of rotated files
+	Path string
+	// MaxSize starts a new file once the current one has this many bytes of JSON. Zero disables it.
+	MaxSize int64
+	// MaxAge starts a new file once the current one is this many seconds old. Zero disables it.
+	MaxAge int
+	// Compress gzips the files and adds the .gz extension
+	Compress bool
+
+	m     sync.Mutex
+	f     *os.File
+	zw    *gzip.Writer
+	w     io.Writer
+	files []SinkFile
+}
+
+// SinkFile describes a file written by JSONLSink, as listed in the index manifest.
+type SinkFile struct {
+	// Name is the file path
+	Name string `json:"name"`
+	// Results is the number of results in the file
+	Results int `json:"results"`
+	// Size is the number of bytes of JSON written to the file, before compression
+	Size int64 `json:"size"`
+	// Started is the time the file was created
+	Started time.Time `json:"started"`
+	// Finished is the time the file was closed, zero while it is written
+	Finished time.Time `json:"finished,omitzero"`
+}
+
+// Write appends the result to the current file, opening or rotating it as needed.
+// Parameters:
+//   - ctx: Request context (unused)
+//   - r: Result to write
+//
+// Returns:
+//   - error: Any error that occurred while writing
+func (s *JSONLSink) Write(_ context.Context, r Result) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	now := time.Now()
+	if s.f != nil && s.rollover(now) {
+		if err := s.closeFile(now); err != nil {
+			return err
+		}
+	}
+	if s.f == nil {
+		if err := s.open(now); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(toStreamed(r))
+	if err != nil {
+		return err
+	}
+
+	n, err := s.w.Write(append(data, '\n'))
+	cur := &s.files[len(s.files)-1]
+	cur.Size += int64(n)
+	if err == nil {
+		cur.Results++
+	}
+	return err
+}
+
+// Files returns the files written so far, as listed in the index manifest.
+// Returns:
+//   - []SinkFile: Written files, oldest first
+func (s *JSONLSink) Files() []SinkFile {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return slices.Clone(s.files)
+}
+
+// Close closes the current file and updates the index manifest.
+// Returns:
+//   - error: Any error that occurred while closing
+func (s *JSONLSink) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.f == nil {
+		return nil
+	}
+	return s.closeFile(time.Now())
+}
+
+// rotating reports whether results are written to numbered files.
+// Returns:
+//   - bool: True if MaxSize or MaxAge is set
+func (s *JSONLSink) rotating() bool {
+	return s.MaxSize > 0 || s.MaxAge > 0
+}
+
+// rollover reports whether the current file is full.
+// Parameters:
+//   - now: Current time
+//
+// Returns:
+//   - bool: True if a new file should be started
+func (s *JSONLSink) rollover(now time.Time) bool {
+	cur := s.files[len(s.files)-1]
+	return (s.MaxSize > 0 && cur.Size >= s.MaxSize) ||
+		(s.MaxAge > 0 && now.Sub(cur.Started) >= time.Duration(s.MaxAge)*time.Second)
+}
+
+// open opens the next file. Rotated files are numbered after the existing ones.
+// Parameters:
+//   - now: Current time
+//
+// Returns:
+//   - error: Any error that occurred while opening
+func (s *JSONLSink) open(now time.Time) error {
+	ext := filepath.Ext(s.Path)
+	base := strings.TrimSuffix(s.Path, ext)
+	if s.Compress {
+		ext += ".gz"
+	}
+
+	name := base + ext
+	flag := os.O_CREATE | os.O_APPEND | os.O_WRONLY
+	if s.rotating() {
+		if s.files == nil {
+			s.files = readSinkIndex(base + "-index.json")
+		}
+		for seq := len(s.files) + 1; ; seq++ {
+			name = fmt.Sprintf("%s-%04d%s", base, seq, ext)
+			if _, err := os.Stat(name); errors.Is(err, fs.ErrNotExist) {
+				break
+			}
+		}
+		flag = os.O_CREATE | os.O_EXCL | os.O_WRONLY
+	}
+
+	f, err := os.OpenFile(name, flag, 0o644)
+	if err != nil {
+		return err
+	}
+
+	s.f, s.w = f, f
+	if s.Compress {
+		s.zw = gzip.NewWriter(f)
+		s.w = s.zw
+	}
+	s.files = append(s.files, SinkFile{Name: name, Started: now})
+
+	// The manifest lists the file while it is written, so it isn't lost if the process dies
+	if s.rotating() {
+		if err := writeSinkIndex(base+"-index.json", s.files); err != nil {
+			werr(fmt.Sprintf("error writing sink index: %v", err))
+		}
+	}
+	return nil
+}
+
+// closeFile closes the current file and updates the index manifest.
+// Parameters:
+//   - now: Current time
+//
+// Returns:
+//   - error: Any error that occurred while closing
+func (s *JSONLSink) closeFile(now time.Time) error {
+	var errs []error
+	if s.zw != nil {
+		errs = append(errs, s.zw.Close())
+	}
+	errs = append(errs, s.f.Close())
+	s.f, s.zw, s.w = nil, nil, nil
+	s.files[len(s.files)-1].Finished = now
+
+	if s.rotating() {
+		base := strings.TrimSuffix(s.Path, filepath.Ext(s.Path))
+		errs = append(errs, writeSinkIndex(base+"-index.json", s.files))
+	}
+	return errors.Join(errs...)
+}
+
+// readSinkIndex reads the index manifest of a previous run.
+// Parameters:
+//   - path: Manifest path
+//
+// Returns:
+//   - []SinkFile: Listed files, empty if the manifest is missing or invalid
+func readSinkIndex(path string) []SinkFile {
+	files := []SinkFile{}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return files
+	}
+	if err := json.Unmarshal(data, &files); err != nil {
+		werr(fmt.Sprintf("error reading sink index %s: %v", path, err))
+		return []SinkFile{}
+	}
+	return files
+}
+
+// writeSinkIndex writes the index manifest.
+// Parameters:
+//   - path: Manifest path
+//   - files: Files to list
+//
+// Returns:
+//   - error: Any error that occurred while writing
+func writeSinkIndex(path string, files []SinkFile) error {
+	data, err := json.MarshalIndent(files, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// FailoverSink writes results to Primary and switches to Fallback while Primary fails.
+// Results written to Fallback are kept and replayed to Primary once it recovers,
+// so no result is lost when the downstream system blips. Writes are serialized.
+type FailoverSink struct {
+	// Primary is the sink results are written to normally
+	Primary Sink
+	// Fallback receives results while Primary fails
+	Fallback Sink
+	// RetryAfter defines how long (in seconds) to wait before retrying Primary.
+	// Zero retries it on every write.
+	RetryAfter int
+
+	m       sync.Mutex
+	downAt  time.Time
+	down    bool
+	backlog []Result
+}
+
+// Write writes the result to Primary, or to Fallback while Primary is down.
+// Parameters:
+//   - ctx: Request context
+//   - r: Result to write
+//
+// Returns:
+//   - error: Error of Fallback if neither sink stored the result
+func (s *FailoverSink) Write(ctx context.Context, r Result) error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.down && time.Since(s.downAt) >= time.Duration(s.RetryAfter)*time.Second {
+		s.replay(ctx)
+	}
+
+	if !s.down {
+		err := s.Primary.Write(ctx, r)
+		if err == nil {
+			return nil
+		}
+		s.down, s.downAt = true, time.Now()
+		werr(fmt.Sprintf("primary sink failed, switching to fallback: %v", err))
+	}
+
+	if err := s.Fallback.Write(ctx, r); err != nil {
+		return err
+	}
+	s.backlog = append(s.backlog, r)
+	return nil
+}
+
+// Close closes Primary and Fallback if they implement io.Closer.
+// Returns:
+//   - error: Errors of the closed sinks
+func (s *FailoverSink) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	var errs []error
+	for _, sink := range []Sink{s.Primary, s.Fallback} {
+		if c, ok := sink.(io.Closer); ok {
+			errs = append(errs, c.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Pending returns the number of results waiting to be replayed to Primary.
+// Returns:
+//   - int: Number of results written to Fallback only
+func (s *FailoverSink) Pending() int {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return len(s.backlog)
+}
+
+// replay writes the backlog to Primary. Primary is considered recovered once
+// the whole backlog is written. The caller must hold the lock.
+// Parameters:
+//   - ctx: Request context
+func (s *FailoverSink) replay(ctx context.Context) {
+	for i, r := range s.backlog {
+		if err := s.Primary.Write(ctx, r); err != nil {
+			s.backlog = s.backlog[i:]
+			s.downAt = time.Now()
+			return
+		}
+	}
+
+	wlog(fmt.Sprintf("primary sink recovered, %d results replayed", len(s.backlog)))
+	s.backlog = nil
+	s.down = false
+}
+
+// sink writes the result to the worker's Sink, compressing the body if configured.
+// Parameters:
+//   - r: Result to write
+func (w *Worker) sink(r Result) {
+	if w.Sink == nil {
+		return
+	}
+
+	r, err := w.compressBody(r)
+	if err != nil {
+		w.werr(fmt.Sprintf("error compressing %s: %v", r.URL, err))
+	}
+
+	if err := w.Sink.Write(r.Context(), r); err != nil {
+		w.werr(fmt.Sprintf("error writing %s to sink: %v", r.URL, err))
+	}
+}
+
+// closeSink closes the worker's Sink if it implements io.Closer, once all results were written.
+func (w *Worker) closeSink() {
+	c, ok := w.Sink.(io.Closer)
+	if !ok {
+		return
+	}
+	if err := c.Close(); err != nil {
+		w.werr(fmt.Sprintf("error closing sink: %v", err))
+	}
+}
@@ -0,0 +1,214 @@
+package httptines
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Sink", func() {
+	Describe("JSONLSink", func() {
+		It("appends results as JSON lines", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "results.jsonl")
+			s := &JSONLSink{Path: path}
+
+			Expect(s.Write(context.Background(), Result{URL: "http://test1.com", Status: 200})).To(Succeed())
+			Expect(s.Write(context.Background(), Result{URL: "http://test2.com", Status: 200})).To(Succeed())
+			Expect(s.Close()).To(Succeed())
+
+			data, err := os.ReadFile(path)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(Equal(
+				`{"url":"http://test1.com","status":200,"proxy":"","latency":0,"attempts":0,"body":""}` + "\n" +
+					`{"url":"http://test2.com","status":200,"proxy":"","latency":0,"attempts":0,"body":""}` + "\n",
+			))
+		})
+
+		It("rotates files by size and lists them in the index", func() {
+			dir := GinkgoT().TempDir()
+			s := &JSONLSink{Path: filepath.Join(dir, "results.jsonl"), MaxSize: 1}
+
+			for _, u := range []string{"a", "b", "c"} {
+				Expect(s.Write(context.Background(), Result{URL: u})).To(Succeed())
+			}
+			Expect(s.Close()).To(Succeed())
+
+			data, err := os.ReadFile(filepath.Join(dir, "results-index.json"))
+			Expect(err).NotTo(HaveOccurred())
+
+			var files []SinkFile
+			Expect(json.Unmarshal(data, &files)).To(Succeed())
+			Expect(files).To(HaveLen(3))
+			Expect(files[0].Name).To(Equal(filepath.Join(dir, "results-0001.jsonl")))
+			Expect(files[2].Name).To(Equal(filepath.Join(dir, "results-0003.jsonl")))
+			for _, f := range files {
+				Expect(f.Results).To(Equal(1))
+				Expect(f.Finished.IsZero()).To(BeFalse())
+			}
+		})
+
+		It("lists the current file in the index while it is written", func() {
+			dir := GinkgoT().TempDir()
+			s := &JSONLSink{Path: filepath.Join(dir, "results.jsonl"), MaxSize: 1 << 20}
+			defer s.Close()
+
+			Expect(s.Write(context.Background(), Result{URL: "a"})).To(Succeed())
+
+			files := readSinkIndex(filepath.Join(dir, "results-index.json"))
+			Expect(files).To(HaveLen(1))
+			Expect(files[0].Name).To(Equal(filepath.Join(dir, "results-0001.jsonl")))
+			Expect(files[0].Finished.IsZero()).To(BeTrue())
+		})
+
+		It("rotates files by age", func() {
+			dir := GinkgoT().TempDir()
+			s := &JSONLSink{Path: filepath.Join(dir, "results.jsonl"), MaxAge: 60}
+
+			Expect(s.Write(context.Background(), Result{URL: "a"})).To(Succeed())
+			Expect(s.Write(context.Background(), Result{URL: "b"})).To(Succeed())
+			Expect(s.Files()).To(HaveLen(1))
+
+			s.files[0].Started = time.Now().Add(-time.Minute)
+			Expect(s.Write(context.Background(), Result{URL: "c"})).To(Succeed())
+			Expect(s.Close()).To(Succeed())
+
+			files := s.Files()
+			Expect(files).To(HaveLen(2))
+			Expect(files[0].Results).To(Equal(2))
+			Expect(files[1].Results).To(Equal(1))
+		})
+
+		It("continues the numbering of a previous run", func() {
+			dir := GinkgoT().TempDir()
+			path := filepath.Join(dir, "results.jsonl")
+
+			s := &JSONLSink{Path: path, MaxSize: 1}
+			Expect(s.Write(context.Background(), Result{URL: "a"})).To(Succeed())
+			Expect(s.Close()).To(Succeed())
+
+			s = &JSONLSink{Path: path, MaxSize: 1}
+			Expect(s.Write(context.Background(), Result{URL: "b"})).To(Succeed())
+			Expect(s.Close()).To(Succeed())
+
+			files := s.Files()
+			Expect(files).To(HaveLen(2))
+			Expect(files[1].Name).To(Equal(filepath.Join(dir, "results-0002.jsonl")))
+		})
+
+		It("compresses the files", func() {
+			dir := GinkgoT().TempDir()
+			s := &JSONLSink{Path: filepath.Join(dir, "results.jsonl"), MaxSize: 1 << 20, Compress: true}
+
+			Expect(s.Write(context.Background(), Result{URL: "a"})).To(Succeed())
+			Expect(s.Close()).To(Succeed())
+
+			f, err := os.Open(filepath.Join(dir, "results-0001.jsonl.gz"))
+			Expect(err).NotTo(HaveOccurred())
+			defer f.Close()
+
+			zr, err := gzip.NewReader(f)
+			Expect(err).NotTo(HaveOccurred())
+			sc := bufio.NewScanner(zr)
+			Expect(sc.Scan()).To(BeTrue())
+			Expect(sc.Text()).To(ContainSubstring(`"url":"a"`))
+		})
+	})
+
+	Describe("closeSink()", func() {
+		It("closes a sink implementing io.Closer", func() {
+			f := &JSONLSink{Path: filepath.Join(GinkgoT().TempDir(), "results.jsonl")}
+			Expect(f.Write(context.Background(), Result{URL: "a"})).To(Succeed())
+
+			(&Worker{Sink: f}).closeSink()
+			Expect(f.Files()[0].Finished.IsZero()).To(BeFalse())
+		})
+
+		It("ignores other sinks", func() {
+			(&Worker{Sink: SinkFunc(func(context.Context, Result) error { return nil })}).closeSink()
+			(&Worker{}).closeSink()
+		})
+	})
+
+	Describe("FailoverSink", func() {
+		var (
+			up                bool
+			primary, fallback []string
+			s                 *FailoverSink
+		)
+
+		BeforeEach(func() {
+			up = true
+			primary, fallback = nil, nil
+			s = &FailoverSink{
+				Primary: SinkFunc(func(_ context.Context, r Result) error {
+					if !up {
+						return errors.New("unavailable")
+					}
+					primary = append(primary, r.URL)
+					return nil
+				}),
+				Fallback: SinkFunc(func(_ context.Context, r Result) error {
+					fallback = append(fallback, r.URL)
+					return nil
+				}),
+			}
+		})
+
+		It("writes to the primary sink", func() {
+			Expect(s.Write(context.Background(), Result{URL: "a"})).To(Succeed())
+			Expect(primary).To(Equal([]string{"a"}))
+			Expect(fallback).To(BeEmpty())
+		})
+
+		It("fails over and replays the backlog on recovery", func() {
+			up = false
+			Expect(s.Write(context.Background(), Result{URL: "a"})).To(Succeed())
+			Expect(s.Write(context.Background(), Result{URL: "b"})).To(Succeed())
+			Expect(fallback).To(Equal([]string{"a", "b"}))
+			Expect(s.Pending()).To(Equal(2))
+
+			up = true
+			Expect(s.Write(context.Background(), Result{URL: "c"})).To(Succeed())
+			Expect(primary).To(Equal([]string{"a", "b", "c"}))
+			Expect(s.Pending()).To(BeZero())
+		})
+
+		It("waits RetryAfter before retrying the primary sink", func() {
+			s.RetryAfter = 60
+			up = false
+			Expect(s.Write(context.Background(), Result{URL: "a"})).To(Succeed())
+
+			up = true
+			Expect(s.Write(context.Background(), Result{URL: "b"})).To(Succeed())
+			Expect(primary).To(BeEmpty())
+			Expect(fallback).To(Equal([]string{"a", "b"}))
+		})
+
+		It("closes the sinks", func() {
+			dir := GinkgoT().TempDir()
+			f := &JSONLSink{Path: filepath.Join(dir, "results.jsonl"), MaxSize: 1 << 20}
+			s.Fallback = f
+			up = false
+			Expect(s.Write(context.Background(), Result{URL: "a"})).To(Succeed())
+
+			Expect(s.Close()).To(Succeed())
+			Expect(f.Files()[0].Finished.IsZero()).To(BeFalse())
+		})
+
+		It("fails when both sinks fail", func() {
+			up = false
+			s.Fallback = SinkFunc(func(context.Context, Result) error { return errors.New("disk full") })
+
+			Expect(s.Write(context.Background(), Result{URL: "a"})).To(MatchError("disk full"))
+			Expect(s.Pending()).To(BeZero())
+		})
+	})
+})
@@ -3,21 +3,60 @@ package httptines
 import (
 	"encoding/json"
 	"fmt"
+	"slices"
 	"sync"
 	"time"
 )
 
 // Stat represents the global statistics for the application.
 type Stat struct {
+	// Namespace is the run label
+	Namespace string `json:"namespace,omitempty"`
+	// Build describes the build of the running binary
+	Build BuildInfo `json:"build"`
+	// State is the worker state: running, paused, stopped or finished
+	State string `json:"state"`
 	// Targets is the total number of URLs to process
 	Targets int `json:"targets"`
 	// RPM represents the current requests per minute
 	RPM int `json:"rpm"`
 	// Servers contains a map of active proxy servers and their statistics
 	Servers map[string]srvMap `json:"servers"`
+	// Bans contains banned proxies with their expiration times
+	Bans map[string]time.Time `json:"bans"`
+	// Sources contains parse statistics of the last fetch keyed by source URL
+	Sources map[string]SourceStat `json:"sources"`
+	// Mirrors counts mirrored requests and mismatching responses
+	Mirrors MirrorStat `json:"mirrors"`
+	// SuggestedTimeout is the timeout (in seconds) suggested by the last check cycle
+	SuggestedTimeout int `json:"suggestedTimeout"`
+	// Abandoned is the number of targets given up after exhausting their retries
+	Abandoned int `json:"abandoned"`
+	// Claimed is the number of targets skipped because another process finished them
+	Claimed int `json:"claimed"`
+	// Connections reports the reuse of pre-warmed connections
+	Connections ConnStat `json:"connections"`
+	// QueueWait reports how long targets waited in the queue
+	QueueWait QueueWaitStat `json:"queueWait"`
+	// RetryBudget reports the retry budget of every target host keyed by host
+	RetryBudget map[string]RetryBudgetStat `json:"retryBudget,omitempty"`
 
-	m          sync.RWMutex
-	timestamps []time.Time
+	m         sync.RWMutex
+	processed int         // Number of successful requests
+	first     time.Time   // Time of the first successful request
+	last      time.Time   // Time of the last successful request
+	recent    []time.Time // Times of the successful requests of the last minute, sorted
+}
+
+// SourceStat represents the parse quality of a proxy source.
+type SourceStat struct {
+	// Accepted is the number of valid proxy entries
+	Accepted int `json:"accepted"`
+	// Rejected is the number of malformed lines
+	Rejected int `json:"rejected"`
+	// Duplicates is the number of accepted entries skipped as they were already
+	// listed by this or a previous source
+	Duplicates int `json:"duplicates"`
 }
 
 // MarshalJSON implements the json.Marshaler interface for Stat
@@ -25,33 +64,35 @@ type Stat struct {
 //   - []byte: JSON representation of the statistics
 //   - error: Any error that occurred during marshaling
 func (s *Stat) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.view())
+}
+
+// view returns the statistics along with the computed fields, as they are encoded.
+// Returns:
+//   - any: Struct encoded in place of the statistics
+func (s *Stat) view() any {
 	type Alias Stat
 
-	return json.Marshal(&struct {
+	return &struct {
 		RPM       int    `json:"rpm"`
 		Processed int    `json:"processed"`
 		Elapsed   string `json:"elapsed"`
 		*Alias
 	}{
 		RPM:       s.rpm(),
-		Processed: len(s.timestamps),
+		Processed: s.processed,
 		Elapsed:   s.elapsed(),
 		Alias:     (*Alias)(s),
-	})
+	}
 }
 
 // rpm calculates the current requests per minute based on successful requests
 // Returns:
 //   - int: Number of successful requests in the last minute
 func (s *Stat) rpm() int {
-	rpm, lastMinute := 0, time.Now().Add(-time.Minute)
-	for i := len(s.timestamps) - 1; i >= 0; i-- {
-		if s.timestamps[i].Compare(lastMinute) < 0 {
-			break
-		}
-		rpm++
-	}
-	return rpm
+	lastMinute := time.Now().Add(-time.Minute)
+	i, _ := slices.BinarySearchFunc(s.recent, lastMinute, time.Time.Compare)
+	return len(s.recent) - i
 }
 
 // addServer adds or updates server statistics
@@ -65,13 +106,66 @@ func (s *Stat) addServer(data srvMap) {
 	s.m.Unlock()
 }
 
-// addTimestamp adds a timestamp for successful requests
+// setSources replaces the source statistics
+// Parameters:
+//   - sources: Parse statistics keyed by source URL
+func (s *Stat) setSources(sources map[string]SourceStat) {
+	s.m.Lock()
+	s.Sources = sources
+	s.m.Unlock()
+}
+
+// addTargets increases the number of targets to process.
+// Parameters:
+//   - n: Number of added targets
+func (s *Stat) addTargets(n int) {
+	s.m.Lock()
+	s.Targets += n
+	s.m.Unlock()
+}
+
+// claim counts a target skipped because another process finished it.
+func (s *Stat) claim() {
+	s.m.Lock()
+	s.Claimed++
+	s.m.Unlock()
+}
+
+// abandon counts a target given up after exhausting its retries.
+func (s *Stat) abandon() {
+	s.m.Lock()
+	s.Abandoned++
+	s.m.Unlock()
+}
+
+// addTimestamp counts a successful request. Only the last minute of timestamps is
+// kept for the rpm, so a monitor running forever doesn't grow it. They are kept sorted,
+// since completions of concurrent requests may arrive out of order. Timestamps taken
+// with time.Now carry a monotonic clock reading, so wall clock changes don't affect
+// the rpm and elapsed time.
 // Parameters:
 //   - t: Time of the successful request
 func (s *Stat) addTimestamp(t time.Time) {
 	s.m.Lock()
-	s.timestamps = append(s.timestamps, t)
-	s.m.Unlock()
+	defer s.m.Unlock()
+
+	s.processed++
+	if s.first.IsZero() || t.Before(s.first) {
+		s.first = t
+	}
+	if t.After(s.last) {
+		s.last = t
+	}
+
+	i := len(s.recent)
+	for i > 0 && s.recent[i-1].Compare(t) > 0 {
+		i--
+	}
+	s.recent = slices.Insert(s.recent, i, t)
+
+	if n, _ := slices.BinarySearchFunc(s.recent, s.last.Add(-time.Minute), time.Time.Compare); n > 0 {
+		s.recent = slices.Delete(s.recent, 0, n)
+	}
 }
 
 // allTargetsProcessed determines whether all targets have been processed
@@ -81,18 +175,69 @@ func (s *Stat) allTargetsProcessed() bool {
 	s.m.RLock()
 	defer s.m.RUnlock()
 
-	return len(s.timestamps) == s.Targets
+	return s.processed+s.Abandoned+s.Claimed >= s.Targets
 }
 
 // elapsed calculates the time spent on processing targets
 // Returns:
 //   - string: Time in format mm:ss
 func (s *Stat) elapsed() string {
-	if tLen := len(s.timestamps); tLen > 1 {
-		elapsed := int(s.timestamps[tLen-1].Sub(s.timestamps[0]).Seconds())
+	if s.processed > 1 {
+		elapsed := int(s.last.Sub(s.first).Seconds())
 		minutes := elapsed / 60
 		seconds := elapsed % 60
 		return fmt.Sprintf("%02d:%02d", minutes, seconds)
 	}
 	return "00:00"
 }
+
+// requests returns the successful and failed requests of all servers so far.
+// The caller must hold the lock.
+// Returns:
+//   - int: Successful requests
+//   - int: Failed requests
+func (s *Stat) requests() (positive, negative int) {
+	for _, sm := range s.Servers {
+		if v, ok := sm["positive"].(int); ok {
+			positive += v
+		}
+		if v, ok := sm["negative"].(int); ok {
+			negative += v
+		}
+	}
+	return positive, negative
+}
+
+// processingTime returns the time between the first and the last processed target
+// Returns:
+//   - time.Duration: Processing time
+func (s *Stat) processingTime() time.Duration {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	if s.processed > 1 {
+		return s.last.Sub(s.first)
+	}
+	return 0
+}
+
+// avgLatency returns the average last measured latency of the servers
+// Returns:
+//   - time.Duration: Average latency
+func (s *Stat) avgLatency() time.Duration {
+	s.m.RLock()
+	defer s.m.RUnlock()
+
+	var total, count int
+	for _, sm := range s.Servers {
+		if v, ok := sm["latency"].(int); ok {
+			total += v
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+	return time.Duration(total/count) * time.Millisecond
+}
@@ -56,10 +56,11 @@ var _ = Describe("Stat", func() {
 		It("adds timestamp to the list", func() {
 			testTime := time.Now()
 			w.stat.addTimestamp(testTime)
-			Expect(w.stat.timestamps).To(ContainElement(testTime))
+			Expect(w.stat.recent).To(ContainElement(testTime))
+			Expect(w.stat.processed).To(Equal(1))
 		})
 
-		It("maintains order of timestamps", func() {
+		It("keeps timestamps in chronological order", func() {
 			time1 := time.Now().Add(2 * time.Second)
 			time2 := time.Now().Add(time.Second)
 			time3 := time.Now()
@@ -68,7 +69,18 @@ var _ = Describe("Stat", func() {
 			w.stat.addTimestamp(time2)
 			w.stat.addTimestamp(time3)
 
-			Expect(w.stat.timestamps).To(Equal([]time.Time{time1, time2, time3}))
+			Expect(w.stat.recent).To(Equal([]time.Time{time3, time2, time1}))
+		})
+
+		It("keeps only the last minute of timestamps", func() {
+			now := time.Now()
+			for i := range 1000 {
+				w.stat.addTimestamp(now.Add(time.Duration(i) * time.Second))
+			}
+
+			Expect(w.stat.processed).To(Equal(1000))
+			Expect(w.stat.recent).To(HaveLen(61))
+			Expect(w.stat.processingTime()).To(Equal(999 * time.Second))
 		})
 	})
 
@@ -87,6 +99,16 @@ var _ = Describe("Stat", func() {
 
 			Expect(w.stat.rpm()).To(Equal(2))
 		})
+
+		It("counts timestamps arriving out of order", func() {
+			now := time.Now()
+			w.stat.addTimestamp(now)
+			w.stat.addTimestamp(now.Add(-2 * time.Minute))
+			w.stat.addTimestamp(now.Add(-30 * time.Second))
+
+			Expect(w.stat.rpm()).To(Equal(2))
+			Expect(w.stat.processingTime()).To(Equal(2 * time.Minute))
+		})
 	})
 
 	Describe("MarshalJSON()", func() {
@@ -109,6 +131,45 @@ var _ = Describe("Stat", func() {
 			Expect(result).To(HaveKeyWithValue("rpm", float64(2)))
 			Expect(result).To(HaveKeyWithValue("processed", float64(2)))
 			Expect(result).To(HaveKey("servers"))
+			Expect(result).NotTo(HaveKey("namespace"))
+		})
+
+		It("includes the namespace", func() {
+			w.stat.Namespace = "shop-a"
+
+			data, err := json.Marshal(w.stat)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(data)).To(ContainSubstring(`"namespace":"shop-a"`))
+		})
+	})
+
+	Describe("requests()", func() {
+		It("sums the requests of all servers", func() {
+			w.stat.addServer(srvMap{"url": "http://a.com", "disabled": uint32(0), "positive": 7, "negative": 3})
+			w.stat.addServer(srvMap{"url": "http://b.com", "disabled": uint32(1), "positive": 0, "negative": 10})
+
+			positive, negative := w.stat.requests()
+			Expect(positive).To(Equal(7))
+			Expect(negative).To(Equal(13))
+		})
+	})
+
+	Describe("processingTime()", func() {
+		It("returns the time between the first and the last timestamp", func() {
+			now := time.Now()
+			w.stat.addTimestamp(now)
+			w.stat.addTimestamp(now.Add(3 * time.Second))
+
+			Expect(w.stat.processingTime()).To(Equal(3 * time.Second))
+		})
+	})
+
+	Describe("avgLatency()", func() {
+		It("returns the average server latency", func() {
+			w.stat.addServer(srvMap{"url": "http://a.com", "latency": 100})
+			w.stat.addServer(srvMap{"url": "http://b.com", "latency": 300})
+
+			Expect(w.stat.avgLatency()).To(Equal(200 * time.Millisecond))
 		})
 	})
 
@@ -136,7 +197,7 @@ var _ = Describe("Stat", func() {
 
 			// Give goroutine time to process
 			time.Sleep(200 * time.Millisecond)
-			Expect(w.stat.timestamps).To(ContainElement(testTime))
+			Expect(w.stat.recent).To(ContainElement(testTime))
 		})
 	})
 })
@@ -0,0 +1,75 @@
+package httptines
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// stormGuard spreads retries over time and quarantines them after mass failures.
+type stormGuard struct {
+	m           sync.Mutex
+	next        time.Time // Earliest time of the next rate-limited retry
+	windowStart time.Time // Start of the current one-second failure window
+	failures    int       // Number of failures in the current window
+	until       time.Time // End of the current quarantine
+}
+
+// retryDelay registers a failure and returns how long the target should wait before it is retried.
+// Parameters:
+//   - now: Time of the failure
+//
+// Returns:
+//   - time.Duration: Delay before the target is put back into the queue
+func (w *Worker) retryDelay(now time.Time) time.Duration {
+	g := &w.storm
+	g.m.Lock()
+	defer g.m.Unlock()
+
+	if now.Sub(g.windowStart) >= time.Second {
+		g.windowStart, g.failures = now, 0
+	}
+	g.failures++
+
+	if w.StormThreshold > 0 && g.failures >= w.StormThreshold && !now.Before(g.until) {
+		g.until = now.Add(time.Duration(w.StormQuarantine) * time.Second)
+		w.wlog(fmt.Sprintf("%d failures in a second, retries are quarantined for %ds", g.failures, w.StormQuarantine))
+	}
+
+	at := now
+	if now.Before(g.until) {
+		at = g.until
+	}
+
+	if w.RetryRate > 0 {
+		if g.next.After(at) {
+			at = g.next
+		}
+		g.next = at.Add(time.Second / time.Duration(w.RetryRate))
+	}
+
+	return at.Sub(now)
+}
+
+// retry puts a failed target back into the queue, respecting the retry limit, backoff,
+// retry rate and quarantine.
+// Parameters:
+//   - u: URL to be reprocessed
+func (w *Worker) retry(u string) {
+	n := w.attemptCount(u)
+	if w.exhausted(u, n) {
+		return
+	}
+
+	d := max(w.retryDelay(time.Now()), w.backoff(n))
+	if d <= 0 {
+		w.retrigger(u)
+		return
+	}
+
+	w.hold(u)
+	time.AfterFunc(d, func() {
+		w.retrigger(u)
+		w.unhold(u)
+	})
+}
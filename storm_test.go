@@ -0,0 +1,64 @@
+package httptines
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Storm", func() {
+	var (
+		w   *Worker
+		now time.Time
+	)
+
+	BeforeEach(func() {
+		w = &Worker{StormQuarantine: 5}
+		now = time.Now()
+	})
+
+	Describe("retryDelay()", func() {
+		When("protection is disabled", func() {
+			It("retries immediately", func() {
+				for range 100 {
+					Expect(w.retryDelay(now)).To(BeZero())
+				}
+			})
+		})
+
+		It("spreads retries according to the rate", func() {
+			w.RetryRate = 10
+
+			Expect(w.retryDelay(now)).To(BeZero())
+			Expect(w.retryDelay(now)).To(Equal(100 * time.Millisecond))
+			Expect(w.retryDelay(now)).To(Equal(200 * time.Millisecond))
+		})
+
+		It("quarantines retries after mass failures", func() {
+			w.StormThreshold = 3
+
+			Expect(w.retryDelay(now)).To(BeZero())
+			Expect(w.retryDelay(now)).To(BeZero())
+			Expect(w.retryDelay(now)).To(Equal(5 * time.Second))
+			Expect(w.retryDelay(now.Add(time.Second))).To(Equal(4 * time.Second))
+			Expect(w.retryDelay(now.Add(6 * time.Second))).To(BeZero())
+		})
+	})
+
+	Describe("retry()", func() {
+		It("puts the target back into the queue", func() {
+			w.retry("http://test1.com")
+			Expect(w.targets).To(Equal([]string{"http://test1.com"}))
+		})
+
+		It("delays the target during quarantine", func() {
+			w.StormThreshold = 1
+			w.StormQuarantine = 1
+			w.retry("http://test1.com")
+
+			Expect(w.shift(1)).To(BeEmpty())
+			Eventually(func() []string { return w.shift(1) }, 2*time.Second).Should(Equal([]string{"http://test1.com"}))
+		})
+	})
+})
@@ -0,0 +1,73 @@
+package httptines
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// RunStream is like RunResults, but pulls targets lazily from a channel, so the
+// target list doesn't have to fit in memory. Targets are read only while the queue
+// is shorter than the capacity of the alive proxies. The run finishes once the
+// channel is closed and all targets are processed.
+// Parameters:
+//   - ctx: Context controlling the worker's lifetime
+//   - targets: Channel of URLs to process
+//   - handler: Callback function to process the result
+//
+// Returns:
+//   - error: *ValidationError if the configuration is invalid
+func (w *Worker) RunStream(ctx context.Context, targets <-chan string, handler func(Result)) error {
+	w.stream = targets
+	defer func() { w.stream = nil }()
+
+	return w.run(ctx, nil, handler)
+}
+
+// feed moves targets from the stream into the queue, applying backpressure
+// when the queue is long enough to keep all proxies busy.
+func (w *Worker) feed() {
+	defer atomic.StoreUint32(&w.feeding, 0)
+
+	for {
+		for w.queued() >= max(w.servers.capacity(), 1) {
+			select {
+			case <-w.ctx.Done():
+				return
+			case <-time.After(100 * time.Millisecond):
+			}
+		}
+
+		select {
+		case <-w.ctx.Done():
+			return
+		case t, ok := <-w.stream:
+			if !ok {
+				return
+			}
+			if !w.allowed(t) {
+				w.wlog("target " + t + " rejected: host is not allowed")
+				continue
+			}
+
+			w.stat.addTargets(1)
+			w.retrigger(t)
+		}
+	}
+}
+
+// feedingTargets reports whether targets are still being read from the stream.
+// Returns:
+//   - bool: True if the stream is open
+func (w *Worker) feedingTargets() bool {
+	return atomic.LoadUint32(&w.feeding) > 0
+}
+
+// queued returns the number of targets waiting in the regular queue.
+// Returns:
+//   - int: Queue length
+func (w *Worker) queued() int {
+	w.m.RLock()
+	defer w.m.RUnlock()
+	return len(w.targets)
+}
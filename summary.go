@@ -0,0 +1,78 @@
+package httptines
+
+import (
+	"fmt"
+	"time"
+)
+
+// Summary describes a completed run.
+type Summary struct {
+	// State is the final worker state: finished or stopped
+	State string
+	// Targets is the total number of targets
+	Targets int
+	// Processed is the number of successfully processed targets
+	Processed int
+	// Failed is the number of targets abandoned after exhausting their retries
+	Failed int
+	// Claimed is the number of targets skipped because another process sharing ClaimDir finished them
+	Claimed int
+	// Unfinished is the number of targets left when the run was stopped
+	Unfinished int
+	// Elapsed is the duration of the run
+	Elapsed time.Duration
+	// QueueWait reports how long targets waited in the queue
+	QueueWait QueueWaitStat
+	// Shutdown describes the state of an aborted run, nil if the run finished
+	Shutdown *ShutdownReport
+}
+
+// String returns a one-line description of the summary.
+// Returns:
+//   - string: Summary description
+func (s Summary) String() string {
+	claimed := ""
+	if s.Claimed > 0 {
+		claimed = fmt.Sprintf(", %d claimed by other processes", s.Claimed)
+	}
+	return fmt.Sprintf("%s: %d/%d processed, %d failed%s, %d unfinished in %s",
+		s.State, s.Processed, s.Targets, s.Failed, claimed, s.Unfinished, s.Elapsed.Round(time.Millisecond))
+}
+
+// summarize builds the summary of the run.
+// Parameters:
+//   - startedAt: Start time of the run
+//
+// Returns:
+//   - Summary: Run summary
+func (w *Worker) summarize(startedAt time.Time) Summary {
+	w.stat.m.RLock()
+	s := Summary{
+		State:     w.stat.State,
+		Targets:   w.stat.Targets,
+		Processed: w.stat.processed,
+		Failed:    w.stat.Abandoned,
+		Claimed:   w.stat.Claimed,
+		Elapsed:   time.Since(startedAt),
+	}
+	w.stat.m.RUnlock()
+	s.QueueWait = w.queueWaitStat()
+
+	s.Unfinished = len(w.Unfinished())
+	return s
+}
+
+// complete logs the summary and passes it to OnComplete.
+// Parameters:
+//   - startedAt: Start time of the run
+//   - report: Shutdown report of an aborted run, nil if the run finished
+func (w *Worker) complete(startedAt time.Time, report *ShutdownReport) {
+	s := w.summarize(startedAt)
+	s.Shutdown = report
+	w.wlog(s.String())
+	w.wlog(s.QueueWait.String())
+
+	if w.OnComplete != nil {
+		w.OnComplete(s)
+	}
+}
@@ -0,0 +1,55 @@
+package httptines
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Summary", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{
+			stat: &Stat{
+				State:     StateStopped,
+				Targets:   5,
+				Abandoned: 1,
+				processed: 2,
+			},
+			targets: []string{"http://test1.com", "http://test2.com"},
+		}
+	})
+
+	Describe("summarize()", func() {
+		It("counts the outcome of the run", func() {
+			s := w.summarize(time.Now().Add(-time.Minute))
+
+			Expect(s.State).To(Equal(StateStopped))
+			Expect(s.Targets).To(Equal(5))
+			Expect(s.Processed).To(Equal(2))
+			Expect(s.Failed).To(Equal(1))
+			Expect(s.Claimed).To(BeZero())
+			Expect(s.Unfinished).To(Equal(2))
+			Expect(s.Elapsed).To(BeNumerically(">=", time.Minute))
+		})
+	})
+
+	Describe("complete()", func() {
+		It("passes the summary to OnComplete", func() {
+			var got Summary
+			w.OnComplete = func(s Summary) { got = s }
+
+			w.complete(time.Now(), nil)
+			Expect(got.Processed).To(Equal(2))
+		})
+	})
+
+	Describe("String()", func() {
+		It("describes the summary", func() {
+			s := Summary{State: StateFinished, Targets: 3, Processed: 3, Elapsed: 1500 * time.Millisecond}
+			Expect(s.String()).To(Equal("finished: 3/3 processed, 0 failed, 0 unfinished in 1.5s"))
+		})
+	})
+})
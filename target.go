@@ -0,0 +1,131 @@
+package httptines
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// Target describes a target with its own request options.
+type Target struct {
+	// URL is the target URL
+	URL string
+	// Method is the HTTP method, GET if empty
+	Method string
+	// Header contains additional request headers
+	Header http.Header
+	// Body is the request body
+	Body []byte
+	// ContentType is the Content-Type header of the body
+	ContentType string
+	// Meta is passed through to the Result unchanged
+	Meta map[string]any
+	// Host is the Host header and, unless ServerName is set, the TLS server name,
+	// e.g. "example.com" when the URL addresses an origin server by IP. It takes
+	// precedence over the worker's HostOverrides.
+	Host string
+	// ServerName is the TLS server name (SNI), Host if empty
+	ServerName string
+}
+
+// RunTargets is like RunResults, but accepts targets with their own request options.
+// Targets sharing a URL keep their own options; in Statuses, Failed and Unfinished the
+// later ones are listed with a "#2", "#3", ... suffix.
+// Parameters:
+//   - ctx: Context controlling the worker's lifetime
+//   - targets: Targets to process
+//   - handler: Callback function to process the result
+//
+// Returns:
+//   - error: *ValidationError if the configuration is invalid
+func (w *Worker) RunTargets(ctx context.Context, targets []Target, handler func(Result)) error {
+	return w.run(ctx, w.register(targets), handler)
+}
+
+// register replaces the request options with those of the targets. Each target is
+// queued under its own key: its URL, or the URL with a "#n" suffix if an earlier
+// target has the same URL. The fragment isn't sent, and the host stays the same.
+// Parameters:
+//   - targets: Targets with their request options
+//
+// Returns:
+//   - []string: Keys of the targets to queue
+func (w *Worker) register(targets []Target) []string {
+	w.m.Lock()
+	defer w.m.Unlock()
+
+	w.options = make(map[string]Target, len(targets))
+	keys := make([]string, 0, len(targets))
+	for _, t := range targets {
+		key := t.URL
+		for n := 2; ; n++ {
+			if _, ok := w.options[key]; !ok {
+				break
+			}
+			key = fmt.Sprintf("%s#%d", t.URL, n)
+		}
+		w.options[key] = t
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// hostNames returns the Host header and TLS server name of the target: its own Host and
+// ServerName, falling back to HostOverrides.
+// Parameters:
+//   - t: Request options of the target
+//
+// Returns:
+//   - string: Host header override, empty if none is configured
+//   - string: TLS server name override, empty if none is configured
+func (w *Worker) hostNames(t Target) (string, string) {
+	host := t.Host
+	if host == "" {
+		host = w.hostOverride(t.URL)
+	}
+	if t.ServerName != "" {
+		return host, t.ServerName
+	}
+	return host, host
+}
+
+// clearOptions drops the request options registered for a run.
+func (w *Worker) clearOptions() {
+	w.m.Lock()
+	w.options = nil
+	w.m.Unlock()
+}
+
+// target returns the request options of the queued target. Options left empty
+// are taken from the worker's Method, Body and ContentType.
+// Parameters:
+//   - key: Target key, the URL for plain targets
+//
+// Returns:
+//   - Target: Request options of the target
+func (w *Worker) target(key string) Target {
+	w.m.RLock()
+	t, ok := w.options[key]
+	w.m.RUnlock()
+
+	if !ok {
+		t = Target{URL: key}
+	}
+	if t.Method == "" {
+		t.Method = w.Method
+	}
+	if t.Body == nil {
+		t.Body = w.Body
+	}
+	if t.ContentType == "" {
+		t.ContentType = w.ContentType
+	}
+	if t.ContentType != "" {
+		t.Header = t.Header.Clone()
+		if t.Header == nil {
+			t.Header = http.Header{}
+		}
+		t.Header.Set("Content-Type", t.ContentType)
+	}
+	return t
+}
@@ -0,0 +1,308 @@
+package httptines
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type recordingTransport struct {
+	req  *http.Request
+	body string
+}
+
+func (t *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.req = r
+	if r.Body != nil {
+		b, _ := io.ReadAll(r.Body)
+		t.body = string(b)
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{},
+		Body:       io.NopCloser(strings.NewReader("ok")),
+		Request:    r,
+	}, nil
+}
+
+var _ = Describe("Target", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{}
+	})
+
+	Describe("register()", func() {
+		It("returns the target URLs", func() {
+			urls := w.register([]Target{{URL: "http://a.com"}, {URL: "http://b.com"}})
+			Expect(urls).To(Equal([]string{"http://a.com", "http://b.com"}))
+		})
+
+		It("keeps the options of targets sharing a URL", func() {
+			keys := w.register([]Target{
+				{URL: "http://a.com/api", Method: http.MethodPost, Body: []byte("1")},
+				{URL: "http://a.com/api", Method: http.MethodPost, Body: []byte("2")},
+				{URL: "http://a.com/api", Method: http.MethodPut, Body: []byte("3")},
+			})
+			Expect(keys).To(Equal([]string{"http://a.com/api", "http://a.com/api#2", "http://a.com/api#3"}))
+
+			t := w.target("http://a.com/api#2")
+			Expect(t.URL).To(Equal("http://a.com/api"))
+			Expect(t.Body).To(Equal([]byte("2")))
+			Expect(w.target("http://a.com/api#3").Method).To(Equal(http.MethodPut))
+		})
+
+		It("replaces the options of the previous run", func() {
+			w.register([]Target{{URL: "http://a.com", Method: http.MethodPost}})
+			w.register([]Target{{URL: "http://b.com"}})
+			Expect(w.target("http://a.com").Method).To(BeEmpty())
+
+			w.clearOptions()
+			Expect(w.target("http://b.com")).To(Equal(Target{URL: "http://b.com"}))
+		})
+	})
+
+	Describe("target()", func() {
+		It("returns the registered options", func() {
+			w.register([]Target{{URL: "http://a.com", Method: http.MethodPost, Meta: map[string]any{"id": 1}}})
+
+			t := w.target("http://a.com")
+			Expect(t.Method).To(Equal(http.MethodPost))
+			Expect(t.Meta).To(HaveKeyWithValue("id", 1))
+		})
+
+		It("returns a plain target for unknown URLs", func() {
+			Expect(w.target("http://b.com")).To(Equal(Target{URL: "http://b.com"}))
+		})
+
+		It("applies the worker's method, body and content type", func() {
+			w.Method = http.MethodPut
+			w.Body = []byte("a=1")
+			w.ContentType = "application/x-www-form-urlencoded"
+
+			t := w.target("http://b.com")
+			Expect(t.Method).To(Equal(http.MethodPut))
+			Expect(t.Body).To(Equal([]byte("a=1")))
+			Expect(t.Header.Get("Content-Type")).To(Equal("application/x-www-form-urlencoded"))
+		})
+
+		It("prefers the target's options over the worker's", func() {
+			w.Method = http.MethodPut
+			w.ContentType = "text/plain"
+			header := http.Header{"X-Id": {"1"}}
+			w.register([]Target{{URL: "http://a.com", Method: http.MethodPost, Header: header, ContentType: "application/json"}})
+
+			t := w.target("http://a.com")
+			Expect(t.Method).To(Equal(http.MethodPost))
+			Expect(t.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(t.Header.Get("X-Id")).To(Equal("1"))
+			Expect(header).NotTo(HaveKey("Content-Type"))
+		})
+	})
+
+	Describe("hostNames()", func() {
+		BeforeEach(func() {
+			w.HostOverrides = map[string]string{"203.0.113.10": "example.com"}
+		})
+
+		It("falls back to HostOverrides", func() {
+			host, sni := w.hostNames(Target{URL: "https://203.0.113.10/"})
+			Expect(host).To(Equal("example.com"))
+			Expect(sni).To(Equal("example.com"))
+		})
+
+		It("prefers the target's host over HostOverrides", func() {
+			host, sni := w.hostNames(Target{URL: "https://203.0.113.10/", Host: "cdn.example.com"})
+			Expect(host).To(Equal("cdn.example.com"))
+			Expect(sni).To(Equal("cdn.example.com"))
+		})
+
+		It("uses the target's server name for TLS only", func() {
+			host, sni := w.hostNames(Target{URL: "https://203.0.113.10/", ServerName: "edge.example.com"})
+			Expect(host).To(Equal("example.com"))
+			Expect(sni).To(Equal("edge.example.com"))
+		})
+	})
+
+	Describe("request()", func() {
+		It("sends the method, headers and body of the target", func() {
+			rt := &recordingTransport{}
+			s := &Server{
+				URL:       &url.URL{Scheme: "http", Host: "127.0.0.1:8080"},
+				transport: func(*url.URL) http.RoundTripper { return rt },
+			}
+
+			_, err := request(context.Background(), "http://a.com/api", s, reqOpts{
+				agent:  "test",
+				method: http.MethodPost,
+				header: http.Header{"content-type": {"application/json"}},
+				body:   []byte(`{"q":1}`),
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rt.req.Method).To(Equal(http.MethodPost))
+			Expect(rt.req.Header.Get("Content-Type")).To(Equal("application/json"))
+			Expect(rt.body).To(Equal(`{"q":1}`))
+		})
+
+		It("calls the prepare hook before sending", func() {
+			rt := &recordingTransport{}
+			s := &Server{
+				URL:       &url.URL{Scheme: "http", Host: "127.0.0.1:8080"},
+				transport: func(*url.URL) http.RoundTripper { return rt },
+			}
+
+			_, err := request(context.Background(), "http://a.com", s, reqOpts{
+				agent: "test",
+				prepare: func(r *http.Request) error {
+					r.Header.Set("Authorization", "Bearer token")
+					return nil
+				},
+			})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rt.req.Header.Get("Authorization")).To(Equal("Bearer token"))
+		})
+
+		It("doesn't send the request if the prepare hook fails", func() {
+			rt := &recordingTransport{}
+			s := &Server{
+				URL:       &url.URL{Scheme: "http", Host: "127.0.0.1:8080"},
+				transport: func(*url.URL) http.RoundTripper { return rt },
+			}
+
+			_, err := request(context.Background(), "http://a.com", s, reqOpts{
+				agent:   "test",
+				prepare: func(*http.Request) error { return errors.New("no token") },
+			})
+
+			Expect(err).To(MatchError("no token"))
+			Expect(rt.req).To(BeNil())
+		})
+
+		It("sends the Host header override", func() {
+			rt := &recordingTransport{}
+			s := &Server{
+				URL:       &url.URL{Scheme: "http", Host: "127.0.0.1:8080"},
+				transport: func(*url.URL) http.RoundTripper { return rt },
+			}
+
+			_, err := request(context.Background(), "http://203.0.113.10/", s, reqOpts{host: "example.com", sni: "edge.example.com"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rt.req.Host).To(Equal("example.com"))
+		})
+
+		It("sends a GET without options", func() {
+			rt := &recordingTransport{}
+			s := &Server{
+				URL:       &url.URL{Scheme: "http", Host: "127.0.0.1:8080"},
+				transport: func(*url.URL) http.RoundTripper { return rt },
+			}
+
+			_, err := request(context.Background(), "http://a.com", s, reqOpts{agent: "test"})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(rt.req.Method).To(Equal(http.MethodGet))
+			Expect(rt.body).To(BeEmpty())
+		})
+
+		Context("with the validate hook", func() {
+			respond := func(status int, body string) *Server {
+				return &Server{
+					URL: &url.URL{Scheme: "http", Host: "127.0.0.1:8080"},
+					transport: func(*url.URL) http.RoundTripper {
+						return roundTripFunc(func(r *http.Request) (*http.Response, error) {
+							return &http.Response{StatusCode: status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(body)), Request: r}, nil
+						})
+					},
+				}
+			}
+			validate := func(status int, body []byte) error {
+				if status == http.StatusNotFound || status == http.StatusOK && string(body) != "captcha" {
+					return nil
+				}
+				return errors.New("rejected")
+			}
+
+			It("accepts a status the hook accepts", func() {
+				rep, err := request(context.Background(), "http://a.com", respond(http.StatusNotFound, "gone"), reqOpts{validate: validate})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(rep.status).To(Equal(http.StatusNotFound))
+				Expect(string(rep.body)).To(Equal("gone"))
+			})
+
+			It("rejects a 200 the hook rejects", func() {
+				_, err := request(context.Background(), "http://a.com", respond(http.StatusOK, "captcha"), reqOpts{validate: validate})
+				Expect(err).To(MatchError(ErrInvalidResponse))
+			})
+
+			It("rejects other statuses", func() {
+				_, err := request(context.Background(), "http://a.com", respond(http.StatusForbidden, "no"), reqOpts{validate: validate})
+				Expect(err).To(MatchError(ErrInvalidResponse))
+			})
+		})
+	})
+
+	Describe("redirects", func() {
+		var (
+			target *httptest.Server
+			s      *Server
+		)
+
+		BeforeEach(func() {
+			target = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch r.URL.Path {
+				case "/a":
+					http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+				case "/b":
+					http.Redirect(w, r, "/c", http.StatusFound)
+				case "/loop":
+					http.Redirect(w, r, "/loop2", http.StatusFound)
+				case "/loop2":
+					http.Redirect(w, r, "/loop", http.StatusFound)
+				default:
+					w.Write([]byte("good"))
+				}
+			}))
+			s = &Server{
+				URL:       &url.URL{Scheme: "http", Host: "127.0.0.1:8080"},
+				transport: func(*url.URL) http.RoundTripper { return http.DefaultTransport },
+			}
+		})
+
+		AfterEach(func() {
+			target.Close()
+		})
+
+		It("records every hop", func() {
+			rep, err := request(context.Background(), target.URL+"/a", s, reqOpts{agent: "test"})
+
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(rep.body)).To(Equal("good"))
+			Expect(rep.hops).To(HaveLen(2))
+			Expect(rep.hops[0].URL).To(Equal(target.URL + "/a"))
+			Expect(rep.hops[0].Status).To(Equal(http.StatusMovedPermanently))
+			Expect(rep.hops[0].Location).To(Equal(target.URL + "/b"))
+			Expect(rep.hops[1].Status).To(Equal(http.StatusFound))
+			Expect(rep.hops[1].Latency).To(BeNumerically(">", 0))
+		})
+
+		It("detects loops", func() {
+			_, err := request(context.Background(), target.URL+"/loop", s, reqOpts{agent: "test"})
+			Expect(err).To(MatchError(ErrRedirectLoop))
+		})
+
+		It("fails after the maximum number of redirects", func() {
+			_, err := request(context.Background(), target.URL+"/a", s, reqOpts{agent: "test", maxHops: 1})
+			Expect(err).To(MatchError(ErrTooManyRedirects))
+			Expect(errorClass(err)).To(Equal("too many redirects"))
+		})
+	})
+})
@@ -0,0 +1,140 @@
+package httptines
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// requestTimeout returns the current request timeout.
+// Returns:
+//   - time.Duration: Timeout, possibly adjusted by AutoTimeout
+func (w *Worker) requestTimeout() time.Duration {
+	w.m.RLock()
+	defer w.m.RUnlock()
+	return time.Duration(w.Timeout) * time.Second
+}
+
+// latencyWeight is the weight of the latest latency in a proxy's average latency.
+const latencyWeight = 0.3
+
+// adaptiveTimeout derives a proxy's timeout from its average latency.
+type adaptiveTimeout struct {
+	factor int           // Multiplier of the average latency
+	lower  time.Duration // Lower bound of the timeout
+	upper  time.Duration // Upper bound of the timeout
+}
+
+// adaptiveTimeout returns the timeout policy of new servers.
+// Returns:
+//   - *adaptiveTimeout: Policy built from TimeoutFactor, MinTimeout and MaxTimeout, nil if AdaptiveTimeout is off
+func (w *Worker) adaptiveTimeout() *adaptiveTimeout {
+	if !w.AdaptiveTimeout {
+		return nil
+	}
+	return &adaptiveTimeout{
+		factor: w.TimeoutFactor,
+		lower:  time.Duration(w.MinTimeout) * time.Second,
+		upper:  time.Duration(w.MaxTimeout) * time.Second,
+	}
+}
+
+// observeLatency adds the latency of a successful request to the server's
+// exponentially weighted average. The caller must hold s.m.
+// Parameters:
+//   - latency: Request latency in milliseconds
+func (s *Server) observeLatency(latency int) {
+	if s.avgLatency == 0 {
+		s.avgLatency = float64(latency)
+		return
+	}
+	s.avgLatency = latencyWeight*float64(latency) + (1-latencyWeight)*s.avgLatency
+}
+
+// currentTimeout returns the server's request timeout. The caller must hold s.m.
+// Returns:
+//   - time.Duration: Adaptive timeout once a request succeeded, the worker's timeout otherwise
+func (s *Server) currentTimeout() time.Duration {
+	if s.adaptive == nil || s.avgLatency == 0 {
+		return s.timeout
+	}
+
+	d := time.Duration(float64(s.adaptive.factor) * s.avgLatency * float64(time.Millisecond))
+	return min(max(d, s.adaptive.lower), s.adaptive.upper)
+}
+
+// requestTimeout returns the server's request timeout.
+// Returns:
+//   - time.Duration: Timeout of the next request
+func (s *Server) requestTimeout() time.Duration {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	return s.currentTimeout()
+}
+
+// percentile returns the p-th percentile of the values using the nearest-rank method.
+// Parameters:
+//   - values: Values to compute the percentile of
+//   - p: Percentile between 0 and 100
+//
+// Returns:
+//   - int: Percentile value, zero if there are no values
+func percentile(values []int, p int) int {
+	if len(values) == 0 {
+		return 0
+	}
+
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	i := max((len(sorted)*p+99)/100-1, 0)
+	return sorted[i]
+}
+
+// suggestTimeout computes a Timeout of TimeoutFactor times the p95 latency of
+// the pool, records it in the statistics and applies it if AutoTimeout is set.
+// Parameters:
+//   - latencies: Latencies of the alive pool in milliseconds
+//
+// Returns:
+//   - int: Suggested timeout in seconds, zero if the pool is empty
+func (w *Worker) suggestTimeout(latencies []int) int {
+	p95 := percentile(latencies, 95)
+	if p95 == 0 {
+		return 0
+	}
+
+	suggested := (p95*w.TimeoutFactor + 999) / 1000
+	suggested = min(max(suggested, w.MinTimeout), w.MaxTimeout)
+
+	w.stat.m.Lock()
+	w.stat.SuggestedTimeout = suggested
+	w.stat.m.Unlock()
+
+	w.m.Lock()
+	current := w.Timeout
+	if w.AutoTimeout {
+		w.Timeout = suggested
+	}
+	w.m.Unlock()
+
+	if w.AutoTimeout && suggested != current {
+		w.wlog(fmt.Sprintf("timeout set to %ds (p95 latency %dms)", suggested, p95))
+	} else if !w.AutoTimeout {
+		w.wlog(fmt.Sprintf("suggested timeout: %ds (p95 latency %dms)", suggested, p95))
+	}
+	return suggested
+}
+
+// checkLatencies returns the check latencies of the servers.
+// Parameters:
+//   - servers: Checked servers
+//
+// Returns:
+//   - []int: Latencies in milliseconds
+func checkLatencies(servers []*Server) []int {
+	latencies := make([]int, 0, len(servers))
+	for _, s := range servers {
+		latencies = append(latencies, s.CheckLatency)
+	}
+	return latencies
+}
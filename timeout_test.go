@@ -0,0 +1,103 @@
+package httptines
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Timeout", func() {
+	var w *Worker
+
+	BeforeEach(func() {
+		w = &Worker{Timeout: 10, TimeoutFactor: 3, MinTimeout: 1, MaxTimeout: 60, stat: &Stat{}}
+	})
+
+	Describe("percentile()", func() {
+		It("uses the nearest rank", func() {
+			values := []int{}
+			for i := 100; i >= 1; i-- {
+				values = append(values, i)
+			}
+			Expect(percentile(values, 95)).To(Equal(95))
+			Expect(percentile(values, 100)).To(Equal(100))
+			Expect(percentile([]int{7}, 95)).To(Equal(7))
+			Expect(percentile(nil, 95)).To(BeZero())
+		})
+	})
+
+	Describe("suggestTimeout()", func() {
+		It("suggests the p95 latency times the factor", func() {
+			Expect(w.suggestTimeout([]int{200, 400, 1500})).To(Equal(5))
+			Expect(w.stat.SuggestedTimeout).To(Equal(5))
+			Expect(w.requestTimeout()).To(Equal(10 * time.Second))
+		})
+
+		It("keeps the suggestion within bounds", func() {
+			Expect(w.suggestTimeout([]int{10})).To(Equal(1))
+			Expect(w.suggestTimeout([]int{50000})).To(Equal(60))
+		})
+
+		It("applies the suggestion with AutoTimeout", func() {
+			w.AutoTimeout = true
+			w.suggestTimeout([]int{1000})
+			Expect(w.requestTimeout()).To(Equal(3 * time.Second))
+		})
+
+		It("suggests nothing for an empty pool", func() {
+			Expect(w.suggestTimeout(nil)).To(BeZero())
+			Expect(w.requestTimeout()).To(Equal(10 * time.Second))
+		})
+	})
+	Describe("adaptive timeout", func() {
+		var s *Server
+
+		BeforeEach(func() {
+			w.AdaptiveTimeout = true
+			s = &Server{timeout: w.requestTimeout(), adaptive: w.adaptiveTimeout()}
+		})
+
+		It("uses the worker's timeout until a request succeeds", func() {
+			Expect(s.requestTimeout()).To(Equal(10 * time.Second))
+		})
+
+		It("follows the average latency of the proxy", func() {
+			s.observeLatency(1000)
+			Expect(s.requestTimeout()).To(Equal(3 * time.Second))
+
+			s.observeLatency(2000)
+			Expect(s.avgLatency).To(BeNumerically("~", 1300, 0.001))
+			Expect(s.requestTimeout()).To(Equal(3900 * time.Millisecond))
+		})
+
+		It("keeps the timeout within bounds", func() {
+			s.observeLatency(10)
+			Expect(s.requestTimeout()).To(Equal(time.Second))
+
+			s.avgLatency = 50000
+			Expect(s.requestTimeout()).To(Equal(60 * time.Second))
+		})
+
+		It("is disabled by default", func() {
+			w.AdaptiveTimeout = false
+			s = &Server{timeout: w.requestTimeout(), adaptive: w.adaptiveTimeout()}
+			s.observeLatency(1000)
+			Expect(s.requestTimeout()).To(Equal(10 * time.Second))
+		})
+
+		It("updates the average on successful requests only", func() {
+			s.URL = &url.URL{Scheme: "http", Host: "1.1.1.1:80"}
+			s.l5 = [5]bool{true, true, true, true, true}
+			s.Requests = 2
+			s.finish(time.Now().Add(-time.Second), nil)
+			avg := s.avgLatency
+			Expect(avg).To(BeNumerically("~", 1000, 50))
+
+			s.finish(time.Now().Add(-5*time.Second), errors.New("timeout"))
+			Expect(s.avgLatency).To(Equal(avg))
+		})
+	})
+})
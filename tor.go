@@ -0,0 +1,115 @@
+package httptines
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tor configures a local Tor client used as a rotating proxy.
+type Tor struct {
+	// SOCKS is the address of the Tor SOCKS endpoint
+	// Default: "127.0.0.1:9050".
+	SOCKS string
+	// Control is the address of the Tor control port
+	// Default: "127.0.0.1:9051".
+	Control string
+	// Password authenticates on the control port, empty for no authentication
+	Password string
+	// RotateAfter requests a new circuit after the given number of requests. Zero disables rotation.
+	RotateAfter int
+}
+
+// torCircuit counts requests made through Tor and rotates the circuit.
+type torCircuit struct {
+	m        sync.Mutex
+	cfg      *Tor
+	requests int
+}
+
+// url returns the proxy URL of the Tor SOCKS endpoint.
+// Returns:
+//   - *url.URL: SOCKS5 proxy URL
+func (t *Tor) url() *url.URL {
+	return &url.URL{Scheme: "socks5", Host: t.SOCKS}
+}
+
+// used counts a request and requests a new circuit every RotateAfter requests.
+// Parameters:
+//   - w: Worker logging the circuit changes
+func (c *torCircuit) used(w *Worker) {
+	if c.cfg.RotateAfter <= 0 {
+		return
+	}
+
+	c.m.Lock()
+	c.requests++
+	rotate := c.requests%c.cfg.RotateAfter == 0
+	c.m.Unlock()
+
+	if rotate {
+		go func() {
+			if err := c.cfg.newnym(); err != nil {
+				w.werr(fmt.Sprintf("tor: %v", err))
+				return
+			}
+			w.wlog("tor: new circuit requested")
+		}()
+	}
+}
+
+// newnym asks Tor to switch to new circuits via the control port.
+// Returns:
+//   - error: Any error that occurred while talking to the control port
+func (t *Tor) newnym() error {
+	conn, err := net.DialTimeout("tcp", t.Control, 10*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	r := bufio.NewReader(conn)
+	for _, cmd := range []string{"AUTHENTICATE " + strconv.Quote(t.Password), "SIGNAL NEWNYM"} {
+		if _, err := fmt.Fprintf(conn, "%s\r\n", cmd); err != nil {
+			return err
+		}
+
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(line, "250") {
+			return errors.New("control port: " + strings.TrimSpace(line))
+		}
+	}
+
+	return nil
+}
+
+// Default sets the addresses left empty to those of a local Tor client.
+func (t *Tor) Default() {
+	setDefault(&t.SOCKS, "127.0.0.1:9050")
+	setDefault(&t.Control, "127.0.0.1:9051")
+}
+
+// NewIdentity asks Tor to switch to new circuits, so subsequent requests exit from a different address.
+// Returns:
+//   - error: Error if Tor isn't configured or the control port refused the request
+func (w *Worker) NewIdentity() error {
+	if w.Tor == nil {
+		return errors.New("tor is not configured")
+	}
+	w.Tor.Default()
+	if err := w.Tor.newnym(); err != nil {
+		return err
+	}
+	w.wlog("tor: new circuit requested")
+	return nil
+}
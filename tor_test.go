@@ -0,0 +1,103 @@
+package httptines
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Tor", func() {
+	var (
+		control  net.Listener
+		commands []string
+		m        sync.Mutex
+		password string
+	)
+
+	BeforeEach(func() {
+		commands = nil
+		password = "secret"
+		control, _ = net.Listen("tcp", "127.0.0.1:0")
+
+		go func() {
+			for {
+				conn, err := control.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer conn.Close()
+					r := bufio.NewReader(conn)
+					for {
+						line, err := r.ReadString('\n')
+						if err != nil {
+							return
+						}
+						line = strings.TrimSpace(line)
+						m.Lock()
+						commands = append(commands, line)
+						m.Unlock()
+
+						if strings.HasPrefix(line, "AUTHENTICATE") && line != `AUTHENTICATE "`+password+`"` {
+							conn.Write([]byte("515 Authentication failed\r\n"))
+							return
+						}
+						conn.Write([]byte("250 OK\r\n"))
+					}
+				}()
+			}
+		}()
+	})
+
+	AfterEach(func() {
+		control.Close()
+	})
+
+	received := func() []string {
+		m.Lock()
+		defer m.Unlock()
+		return append([]string(nil), commands...)
+	}
+
+	Describe("NewIdentity()", func() {
+		It("requests a new circuit", func() {
+			w := &Worker{Tor: &Tor{Control: control.Addr().String(), Password: "secret"}}
+
+			Expect(w.NewIdentity()).To(Succeed())
+			Expect(received()).To(Equal([]string{`AUTHENTICATE "secret"`, "SIGNAL NEWNYM"}))
+		})
+
+		It("fails on a wrong password", func() {
+			w := &Worker{Tor: &Tor{Control: control.Addr().String(), Password: "wrong"}}
+			Expect(w.NewIdentity()).To(MatchError(ContainSubstring("515")))
+		})
+
+		It("fails when Tor isn't configured", func() {
+			Expect((&Worker{}).NewIdentity()).To(HaveOccurred())
+		})
+	})
+
+	Describe("used()", func() {
+		It("rotates the circuit every RotateAfter requests", func() {
+			c := &torCircuit{cfg: &Tor{Control: control.Addr().String(), Password: "secret", RotateAfter: 2}}
+
+			w := &Worker{Logger: io.Discard}
+			c.used(w)
+			Consistently(received, "100ms").Should(BeEmpty())
+			c.used(w)
+			Eventually(received).Should(ContainElement("SIGNAL NEWNYM"))
+		})
+	})
+
+	Describe("Validate()", func() {
+		It("doesn't require sources in Tor mode", func() {
+			w := &Worker{TestTarget: "http://example.com", Tor: &Tor{}}
+			Expect(w.Validate()).To(Succeed())
+		})
+	})
+})
@@ -34,3 +34,23 @@ type userAgent struct {
 func (a *userAgent) get() string {
 	return a.agents[rand.Intn(len(a.agents))]
 }
+
+// checkAgent returns the user agent used while checking proxies
+// Returns:
+//   - string: CheckUserAgent or the first built-in user agent
+func (w *Worker) checkAgent() string {
+	if w.CheckUserAgent != "" {
+		return w.CheckUserAgent
+	}
+	return ua.agents[0]
+}
+
+// scrapeAgent returns a random user agent used while scraping targets
+// Returns:
+//   - string: A random user agent from UserAgents or the built-in list
+func (w *Worker) scrapeAgent() string {
+	if len(w.UserAgents) > 0 {
+		return w.UserAgents[rand.Intn(len(w.UserAgents))]
+	}
+	return ua.get()
+}
@@ -25,4 +25,25 @@ var _ = Describe("UserAgent", func() {
 			Expect(first == second && second == third && first == third).To(BeFalse())
 		})
 	})
+	Describe("checkAgent()", func() {
+		It("returns the first built-in user agent by default", func() {
+			Expect((&Worker{}).checkAgent()).To(Equal(ua.agents[0]))
+		})
+
+		It("returns the configured user agent", func() {
+			w := &Worker{CheckUserAgent: "checker/1.0"}
+			Expect(w.checkAgent()).To(Equal("checker/1.0"))
+		})
+	})
+
+	Describe("scrapeAgent()", func() {
+		It("returns a built-in user agent by default", func() {
+			Expect(ua.agents).To(ContainElement((&Worker{}).scrapeAgent()))
+		})
+
+		It("returns a configured user agent", func() {
+			w := &Worker{UserAgents: []string{"a/1.0", "b/1.0"}}
+			Expect(w.UserAgents).To(ContainElement(w.scrapeAgent()))
+		})
+	})
 })
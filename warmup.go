@@ -0,0 +1,74 @@
+package httptines
+
+import "sync"
+
+// ramp gradually raises the concurrency of a new proxy up to its capacity. The limit
+// doubles after as many successful requests in a row as the limit allows, and halves
+// on every failure until the capacity is reached.
+type ramp struct {
+	m     sync.Mutex
+	limit int // Current concurrency limit
+	ok    int // Successful requests since the limit last changed
+}
+
+// Proxies with at least trustedRequests requests in earlier runs and a score of at
+// least trustedScore skip the warm-up.
+const (
+	trustedRequests = 20
+	trustedScore    = 0.9
+)
+
+// newRamp creates the warm-up ramp of a new proxy.
+// Parameters:
+//   - past: Statistics of the proxy from earlier runs
+//
+// Returns:
+//   - *ramp: Ramp starting with a single request, nil if WarmUp is disabled or the proxy is trusted
+func (w *Worker) newRamp(past proxyRecord) *ramp {
+	if !w.WarmUp {
+		return nil
+	}
+	if past.Positive+past.Negative >= trustedRequests && successRate(past.Positive, past.Negative) >= trustedScore {
+		return nil
+	}
+	return &ramp{limit: 1}
+}
+
+// cap returns the number of concurrent requests allowed through the proxy.
+// Parameters:
+//   - capacity: Capacity of the proxy
+//
+// Returns:
+//   - int: Concurrency limit, capacity once the proxy is warmed up
+func (r *ramp) cap(capacity int) int {
+	if r == nil {
+		return capacity
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+	return min(r.limit, capacity)
+}
+
+// record adjusts the limit by the outcome of a request. Outcomes don't
+// change the limit once it reaches the capacity.
+// Parameters:
+//   - success: Whether the request succeeded
+//   - capacity: Capacity of the proxy
+func (r *ramp) record(success bool, capacity int) {
+	if r == nil {
+		return
+	}
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	if r.limit >= capacity {
+		return
+	}
+	if !success {
+		r.limit, r.ok = max(r.limit/2, 1), 0
+		return
+	}
+	if r.ok++; r.ok >= r.limit {
+		r.limit, r.ok = min(r.limit*2, capacity), 0
+	}
+}
@@ -0,0 +1,69 @@
+package httptines
+
+import (
+	"errors"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Warm-up", func() {
+	Describe("newRamp()", func() {
+		It("is only created with WarmUp", func() {
+			Expect((&Worker{}).newRamp(proxyRecord{})).To(BeNil())
+			Expect((&Worker{WarmUp: true}).newRamp(proxyRecord{}).cap(8)).To(Equal(1))
+		})
+
+		It("lets proxies with a good history skip the warm-up", func() {
+			w := &Worker{WarmUp: true}
+			Expect(w.newRamp(proxyRecord{Positive: 50, Negative: 1})).To(BeNil())
+			Expect(w.newRamp(proxyRecord{Positive: 5})).NotTo(BeNil())
+			Expect(w.newRamp(proxyRecord{Positive: 30, Negative: 20})).NotTo(BeNil())
+		})
+	})
+
+	Describe("record()", func() {
+		It("doubles the limit after a streak of successes", func() {
+			r := &ramp{limit: 1}
+			var limits []int
+			for range 7 {
+				r.record(true, 8)
+				limits = append(limits, r.cap(8))
+			}
+			Expect(limits).To(Equal([]int{2, 2, 4, 4, 4, 4, 8}))
+		})
+
+		It("halves the limit on failures", func() {
+			r := &ramp{limit: 4, ok: 3}
+			r.record(false, 8)
+			Expect(r.cap(8)).To(Equal(2))
+			r.record(true, 8)
+			Expect(r.cap(8)).To(Equal(2))
+
+			r.record(false, 8)
+			r.record(false, 8)
+			Expect(r.cap(8)).To(Equal(1))
+		})
+
+		It("stops adjusting once the capacity is reached", func() {
+			r := &ramp{limit: 2}
+			r.record(true, 3)
+			r.record(true, 3)
+			Expect(r.cap(3)).To(Equal(3))
+			r.record(false, 3)
+			Expect(r.cap(3)).To(Equal(3))
+		})
+	})
+
+	Describe("finish()", func() {
+		It("feeds the ramp of the server", func() {
+			s := &Server{URL: &url.URL{Scheme: "http", Host: "1.1.1.1:80"}, Capacity: 4, ramp: &ramp{limit: 1}, l5: [5]bool{true, true, true, true, true}}
+			s.finish(time.Now(), nil)
+			Expect(s.ramp.cap(s.Capacity)).To(Equal(2))
+			s.finish(time.Now(), errors.New("boom"))
+			Expect(s.ramp.cap(s.Capacity)).To(Equal(1))
+		})
+	})
+})
@@ -1,82 +1,557 @@
 package httptines
 
 import (
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"log"
+	"math"
+	"net"
 	"net/http"
+	"net/url"
 	"path"
 	"runtime"
+	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"text/template"
+	"time"
+	"unicode/utf8"
 
 	"github.com/gorilla/websocket"
 )
 
 // Global variables for web server management.
 var (
-	upgrader  = websocket.Upgrader{}           // WebSocket connection upgrader
-	clients   = make(map[*websocket.Conn]bool) // Connected WebSocket clients
-	broadcast = make(chan []byte)              // Channel for broadcasting messages
-	wsm       sync.Mutex                       // Mutex for client map access
+	upgrader  = websocket.Upgrader{}             // WebSocket connection upgrader
+	clients   = make(map[*websocket.Conn]client) // Connected WebSocket clients
+	broadcast = make(chan message)               // Channel for broadcasting messages
+	wsm       sync.Mutex                         // Mutex for client map access
+	hmo       sync.Once                          // Starts handleMessages once
 )
 
+// client represents the preferences of a connected WebSocket client.
+type client struct {
+	level    string // Log level filter
+	encoding string // Negotiated payload encoding, empty for JSON
+}
+
+// write sends the message to the client in its encoding.
+// Parameters:
+//   - conn: Client connection
+//   - msg: Message to send
+//
+// Returns:
+//   - error: Any error that occurred while writing, nil if the message isn't available in the encoding
+func (c client) write(conn *websocket.Conn, msg message) error {
+	data := msg.in(c.encoding)
+	if data == nil {
+		return nil
+	}
+	return writeEncoded(conn, c.encoding, data)
+}
+
+// writeEncoded sends an encoded message. JSON is sent as text frames, other
+// encodings as binary frames.
+// Parameters:
+//   - conn: Client connection
+//   - encoding: Encoding of the data, empty for JSON
+//   - data: Encoded message
+//
+// Returns:
+//   - error: Any error that occurred while writing
+func writeEncoded(conn *websocket.Conn, encoding string, data []byte) error {
+	if encoding == "" || encoding == encodingJSON {
+		return conn.WriteMessage(websocket.TextMessage, data)
+	}
+	return conn.WriteMessage(websocket.BinaryMessage, data)
+}
+
 // Payload represents the structure of WebSocket messages.
 type Payload struct {
 	Kind string `json:"kind"` // Type of the message
 	Body any    `json:"body"` // Content of the message
 }
 
-// listenAndServe starts the HTTP server on the specified port
+// listenAndServe starts the HTTP server on the worker's port
 // Parameters:
-//   - port: Port number to listen on
-func listenAndServe(port int) {
-	http.HandleFunc("/", serveIndex)
-	http.HandleFunc("/ws", wsHandler)
+//   - wk: Worker whose state is exposed by the API
+func listenAndServe(wk *Worker) {
+	port := wk.Port
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", serveIndex)
+	mux.HandleFunc("/ws", wsHandler(wk))
+	mux.HandleFunc("GET /api/queue", queueHandler(wk))
+	mux.HandleFunc("POST /api/targets", adminHandler(wk, prioritizeHandler(wk)))
+	mux.HandleFunc("GET /api/results/stream", resultsStreamHandler(wk))
+	mux.HandleFunc("GET /api/failed", failedHandler(wk))
+	mux.HandleFunc("GET /api/stats", statsHandler(wk))
+	mux.HandleFunc("POST /api/concurrency/{direction}", adminHandler(wk, concurrencyHandler(wk)))
+	mux.HandleFunc("GET /api/proxies", proxiesHandler(wk))
+	mux.HandleFunc("POST /api/proxies/refresh", adminHandler(wk, refreshHandler(wk)))
+	mux.HandleFunc("POST /api/proxies/check", adminHandler(wk, recheckHandler(wk)))
+	mux.HandleFunc("GET /api/bans", bansHandler(wk))
+	mux.HandleFunc("POST /api/bans", adminHandler(wk, banHandler(wk)))
+	mux.HandleFunc("DELETE /api/bans", adminHandler(wk, unbanHandler(wk)))
 
 	fs := http.FileServer(http.Dir(absolutePath()))
-	http.Handle("/static/", http.StripPrefix("/static/", fs))
+	mux.Handle("/static/", http.StripPrefix("/static/", fs))
 
-	go handleMessages()
+	hmo.Do(func() { go handleMessages() })
+
+	srv := &http.Server{Addr: ":" + strconv.Itoa(port), Handler: mux}
+	go func() {
+		<-wk.quit
+		srv.Close()
+	}()
 
 	log.Println("Server started on :", port)
-	if err := http.ListenAndServe(":"+strconv.Itoa(port), nil); err != nil {
-		log.Fatal("ListenAndServe: ", err)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Print("ListenAndServe: ", err)
 	}
 }
 
-// wsHandler handles incoming WebSocket connection requests
+// wsHandler handles incoming WebSocket connection requests. The recent log history
+// is replayed to the new client. The "level" query parameter set to "error"
+// limits the log messages to errors. The payload encoding is negotiated with the
+// WebSocket subprotocols requested by the client among the worker's payload encoders,
+// e.g. "msgpack", JSON is used otherwise.
 // Parameters:
-//   - w: HTTP response writer
-//   - r: HTTP request
-func wsHandler(w http.ResponseWriter, r *http.Request) {
-	conn, err := upgrader.Upgrade(w, r, nil)
-	if err != nil {
-		log.Print("upgrade:", err)
-		return
-	}
+//   - wk: Worker whose payload encoders are offered
+//
+// Returns:
+//   - http.HandlerFunc: Handler for /ws
+func wsHandler(wk *Worker) http.HandlerFunc {
+	encodings := wk.payloadEncodings()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		c := client{level: r.URL.Query().Get("level"), encoding: negotiateEncoding(websocket.Subprotocols(r), encodings)}
 
-	wsm.Lock()
-	clients[conn] = true
-	wsm.Unlock()
+		var h http.Header
+		if c.encoding != "" {
+			h = http.Header{"Sec-Websocket-Protocol": {c.encoding}}
+		}
+		conn, err := upgrader.Upgrade(w, r, h)
+		if err != nil {
+			log.Print("upgrade:", err)
+			return
+		}
+
+		wsm.Lock()
+		defer wsm.Unlock()
+
+		for _, msg := range history.recent() {
+			if !msg.accepts(c.level) {
+				continue
+			}
+			if err := c.write(conn, msg); err != nil {
+				conn.Close()
+				return
+			}
+		}
+		clients[conn] = c
+	}
 }
 
 // handleMessages processes incoming messages from the broadcast channel.
+// Messages are encoded when they are created, see newMessage.
 func handleMessages() {
 	for {
 		msg := <-broadcast
 
 		wsm.Lock()
-		for c := range clients {
-			err := c.WriteMessage(websocket.TextMessage, msg)
-			if err != nil {
-				c.Close()
-				delete(clients, c)
+		for conn, c := range clients {
+			if !msg.accepts(c.level) {
+				continue
+			}
+			if err := c.write(conn, msg); err != nil {
+				conn.Close()
+				delete(clients, conn)
 			}
 		}
 		wsm.Unlock()
 	}
 }
 
+// queueHandler returns a handler serving the queue changes since the "since" token
+// Parameters:
+//   - wk: Worker whose queue is exposed
+//
+// Returns:
+//   - http.HandlerFunc: Handler for GET /api/queue
+func queueHandler(wk *Worker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var token uint64
+		if v := r.URL.Query().Get("since"); v != "" {
+			var err error
+			if token, err = strconv.ParseUint(v, 10, 64); err != nil {
+				http.Error(w, "invalid since token", http.StatusBadRequest)
+				return
+			}
+		}
+
+		writeJSON(w, wk.queueChanges(token))
+	}
+}
+
+// concurrencyHandler returns a handler adjusting the concurrency limit by one step up or down
+// Parameters:
+//   - wk: Worker whose concurrency is adjusted
+//
+// Returns:
+//   - http.HandlerFunc: Handler for POST /api/concurrency/{direction}
+func concurrencyHandler(wk *Worker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var steps int
+		switch r.PathValue("direction") {
+		case "up":
+			steps = 1
+		case "down":
+			steps = -1
+		default:
+			http.Error(w, "direction must be up or down", http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, map[string]int{"concurrency": wk.scale(steps)})
+	}
+}
+
+// prioritizeHandler returns a handler queuing the targets given in the "url"
+// parameters in the priority lane
+// Parameters:
+//   - wk: Worker processing the targets
+//
+// Returns:
+//   - http.HandlerFunc: Handler for POST /api/targets
+func prioritizeHandler(wk *Worker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || len(r.Form["url"]) == 0 {
+			http.Error(w, "url is required", http.StatusBadRequest)
+			return
+		}
+
+		writeJSON(w, map[string]int{"queued": wk.Prioritize(r.Form["url"]...)})
+	}
+}
+
+// streamedResult is a result as written by the NDJSON results stream.
+type streamedResult struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Proxy    string `json:"proxy"`
+	Latency  int64  `json:"latency"`
+	Attempts int    `json:"attempts"`
+	Waited   int64  `json:"queueWait,omitempty"`
+	Body     string `json:"body"`
+	Base64   bool   `json:"base64,omitempty"`
+	Encoding string `json:"encoding,omitempty"`
+	Language string `json:"language,omitempty"`
+	Attempt  string `json:"attemptId,omitempty"`
+}
+
+// toStreamed converts a result to the form written by the results stream
+// Parameters:
+//   - r: Result to convert
+//
+// Returns:
+//   - streamedResult: Result with the latency in milliseconds and the body as text,
+//     or base64 encoded if it is compressed or not valid UTF-8
+func toStreamed(r Result) streamedResult {
+	body, encoded := string(r.Body), r.Encoding != "" || !utf8.Valid(r.Body)
+	if encoded {
+		body = base64.StdEncoding.EncodeToString(r.Body)
+	}
+
+	return streamedResult{
+		URL:      r.URL,
+		Status:   r.Status,
+		Proxy:    r.Proxy,
+		Latency:  r.Latency.Milliseconds(),
+		Attempts: r.Attempts,
+		Waited:   r.QueueWait.Milliseconds(),
+		Body:     body,
+		Base64:   encoded,
+		Encoding: r.Encoding,
+		Language: r.Language,
+		Attempt:  r.AttemptID,
+	}
+}
+
+// resultsStreamHandler returns a handler streaming processed results as
+// newline-delimited JSON until the client disconnects or the run ends
+// Parameters:
+//   - wk: Worker whose results are streamed
+//
+// Returns:
+//   - http.HandlerFunc: Handler for GET /api/results/stream
+func resultsStreamHandler(wk *Worker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ch := wk.results.subscribe()
+		defer wk.results.unsubscribe(ch)
+
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+		rc := http.NewResponseController(w)
+		rc.Flush()
+
+		enc := json.NewEncoder(w)
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-wk.quit:
+				return
+			case res := <-ch:
+				err := enc.Encode(toStreamed(res))
+				if err != nil {
+					return
+				}
+				rc.Flush()
+			}
+		}
+	}
+}
+
+// statsHandler returns a handler exposing the current statistics
+// Parameters:
+//   - wk: Worker whose statistics are exposed
+//
+// Returns:
+//   - http.HandlerFunc: Handler for GET /api/stats
+func statsHandler(wk *Worker) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		wk.stat.m.RLock()
+		defer wk.stat.m.RUnlock()
+
+		writeJSON(w, wk.stat)
+	}
+}
+
+// proxiesHandler returns a handler listing the alive proxies without credentials, best scores
+// first. The "scheme" parameter limits the list to a protocol. With "format" set to "text",
+// the proxies are listed one per line, so another worker can use the list as a source.
+// Parameters:
+//   - wk: Worker whose pool is exposed
+//
+// Returns:
+//   - http.HandlerFunc: Handler for GET /api/proxies
+func proxiesHandler(wk *Worker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		proxies := wk.AliveProxies()
+		if scheme := r.URL.Query().Get("scheme"); scheme != "" {
+			proxies = slices.DeleteFunc(proxies, func(p ProxyInfo) bool {
+				return !strings.EqualFold(p.Proxy.Scheme, scheme)
+			})
+		}
+
+		if r.URL.Query().Get("format") != "text" {
+			writeJSON(w, proxies)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, p := range proxies {
+			fmt.Fprintln(w, p.URL)
+		}
+	}
+}
+
+// failedHandler returns a handler listing the targets abandoned after MaxRetries
+// Parameters:
+//   - wk: Worker whose dead-letter list is exposed
+//
+// Returns:
+//   - http.HandlerFunc: Handler for GET /api/failed
+func failedHandler(wk *Worker) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, wk.Failed())
+	}
+}
+
+// bansHandler returns a handler listing the banned proxies
+// Parameters:
+//   - wk: Worker whose bans are exposed
+//
+// Returns:
+//   - http.HandlerFunc: Handler for GET /api/bans
+func bansHandler(wk *Worker) http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, wk.Bans())
+	}
+}
+
+// banHandler returns a handler banning the proxy given in the "url" parameter
+// for "ttl" seconds (BanTTL if omitted)
+// Parameters:
+//   - wk: Worker whose proxy is banned
+//
+// Returns:
+//   - http.HandlerFunc: Handler for POST /api/bans
+func banHandler(wk *Worker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		proxy := r.FormValue("url")
+		if proxy == "" {
+			http.Error(w, "url is required", http.StatusBadRequest)
+			return
+		}
+
+		var ttl int
+		if v := r.FormValue("ttl"); v != "" {
+			var err error
+			if ttl, err = strconv.Atoi(v); err != nil || ttl < 0 {
+				http.Error(w, "invalid ttl", http.StatusBadRequest)
+				return
+			}
+		}
+
+		wk.Ban(proxy, time.Duration(ttl)*time.Second)
+		writeJSON(w, wk.Bans())
+	}
+}
+
+// unbanHandler returns a handler restoring the proxy given in the "url" parameter
+// Parameters:
+//   - wk: Worker whose proxy is restored
+//
+// Returns:
+//   - http.HandlerFunc: Handler for DELETE /api/bans
+func unbanHandler(wk *Worker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		proxy := r.URL.Query().Get("url")
+		if proxy == "" {
+			http.Error(w, "url is required", http.StatusBadRequest)
+			return
+		}
+
+		wk.Unban(proxy)
+		writeJSON(w, wk.Bans())
+	}
+}
+
+// refreshHandler returns a handler starting a proxy fetch and check cycle. It responds
+// with 202 Accepted, 409 Conflict if the worker isn't running, or 429 Too Many Requests
+// with Retry-After if the last cycle was too recent.
+// Parameters:
+//   - wk: Worker whose proxies are refreshed
+//
+// Returns:
+//   - http.HandlerFunc: Handler for POST /api/proxies/refresh
+func refreshHandler(wk *Worker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		wait, err := wk.Refresh()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "refreshed too recently", http.StatusTooManyRequests)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		writeJSON(w, map[string]bool{"refreshing": true})
+	}
+}
+
+// recheckHandler returns a handler checking the downloaded proxies again without
+// downloading the sources. It responds with 202 Accepted, 409 Conflict if the worker
+// isn't running, or 429 Too Many Requests with Retry-After if the last check was too recent.
+// Parameters:
+//   - wk: Worker whose proxies are checked
+//
+// Returns:
+//   - http.HandlerFunc: Handler for POST /api/proxies/check
+func recheckHandler(wk *Worker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		wait, err := wk.Recheck()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, "checked too recently", http.StatusTooManyRequests)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		writeJSON(w, map[string]bool{"checking": true})
+	}
+}
+
+// adminHandler guards a route changing the worker's state. Cross-origin requests are
+// rejected, so a page opened in the browser can't forge them. With AdminToken set, the
+// request must carry it as a bearer token, otherwise it must come from the loopback interface.
+// Parameters:
+//   - wk: Worker whose state is changed
+//   - h: Handler of the route
+//
+// Returns:
+//   - http.HandlerFunc: Handler responding with 403 Forbidden or 401 Unauthorized before calling h
+func adminHandler(wk *Worker, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if crossOrigin(r) {
+			http.Error(w, "cross-origin request", http.StatusForbidden)
+			return
+		}
+
+		if wk.AdminToken == "" {
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err != nil || !net.ParseIP(host).IsLoopback() {
+				http.Error(w, "only allowed from localhost without AdminToken", http.StatusForbidden)
+				return
+			}
+		} else {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(wk.AdminToken)) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "invalid token", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		h(w, r)
+	}
+}
+
+// crossOrigin reports whether the request was sent by a page of another origin.
+// Parameters:
+//   - r: HTTP request
+//
+// Returns:
+//   - bool: True if Sec-Fetch-Site or Origin show another origin
+func crossOrigin(r *http.Request) bool {
+	switch r.Header.Get("Sec-Fetch-Site") {
+	case "", "same-origin", "none":
+	default:
+		return true
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	u, err := url.Parse(origin)
+	return err != nil || u.Host != r.Host
+}
+
+// writeJSON writes the value as a JSON response
+// Parameters:
+//   - w: HTTP response writer
+//   - v: Value to encode
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Print("encode:", err)
+	}
+}
+
 // serveIndex serves the main HTML template page
 // Parameters:
 //   - w: HTTP response writer
@@ -1,7 +1,18 @@
+let socket;
+
 function connectWebSocket() {
-  const ws = new WebSocket(wsURL);
+  const errorsOnly = localStorage.getItem("errorsOnly") === "true";
+  const ws = new WebSocket(errorsOnly ? `${wsURL}?level=error` : wsURL, [
+    "msgpack",
+    "json",
+  ]);
+  ws.binaryType = "arraybuffer";
+  socket = ws;
+
+  document.getElementById("errors-only").checked = errorsOnly;
 
   ws.onopen = function (evt) {
+    document.getElementById("log").innerHTML = "";
     handleLog(`${now()} connected`);
   };
   ws.onclose = function (evt) {
@@ -9,14 +20,22 @@ function connectWebSocket() {
     setTimeout(connectWebSocket, 2000);
   };
   ws.onmessage = function (evt) {
-    const { kind, body } = JSON.parse(evt.data);
+    const { kind, body } =
+      typeof evt.data === "string"
+        ? JSON.parse(evt.data)
+        : decodeMsgpack(evt.data);
 
     switch (kind) {
       case "stat":
         handleStat(body);
         break;
       case "log":
-        handleLog(body);
+        handleLog(body.message, body.level);
+        break;
+      case "histogram":
+        handleHistogram(body);
+        break;
+      case "alert":
         break;
       default:
         console.warn(`Unknown payload's kind "${kind}"`);
@@ -31,22 +50,39 @@ function handleStat(j) {
   const t = document.getElementById("servers");
 
   const {
+    namespace,
+    build,
+    state,
     elapsed,
     targets,
     rpm,
     processed,
     servers,
+    sources,
+    bans,
   } = j;
   const eta = Math.round((targets - processed) / rpm);
 
   const progress = `
           <div>${Math.round((processed * 100) / targets)}% / ~${eta}min. </div>
           <div>${processed} / ${targets} / ${elapsed}</div>
+          <div>${state}</div>
         `;
 
+  document.title = namespace ? `httptines - ${namespace}` : "httptines";
   document.getElementById("progress").innerHTML = progress;
   document.getElementById("rpm").textContent = `${rpm}`;
 
+  if (build) {
+    const commit = build.commit ? ` (${build.commit.slice(0, 7)}${build.modified ? ", modified" : ""})` : "";
+    document.getElementById("build").textContent = `${build.version}${commit}, ${build.goVersion}`;
+  }
+
+  if (sources) {
+    const rejected = Object.values(sources).reduce((sum, { rejected }) => sum + rejected, 0);
+    document.getElementById("rejected").textContent = `${rejected}`;
+  }
+
   if (servers) {
     document.getElementById('proxies').textContent = `${Object.keys(servers).length}`;
 
@@ -60,38 +96,89 @@ function handleStat(j) {
         <th>Requests</th>
         <th>Positive</th>
         <th>Negative</th>
+        <th>Redirects</th>
+        <th>Traffic (KB)</th>
+        <th></th>
       </tr>
     `;
 
     Object.values(servers)
       .sort((a, b) => b.positive - a.positive)
-      .forEach(({ url, disabled, latency, efficiency, capacity, requests, positive, negative }, idx) => {
+      .forEach(({ url, disabled, latency, efficiency, capacity, requests, positive, negative, redirects, headers, cached, sent, received }, idx) => {
         const row = document.createElement("tr");
 
         // row.classList.add(disabled ? "disabled" : "");
 
+        row.title = Object.entries(headers || {})
+          .map(([k, v]) => `${k}: ${v.join(", ")}`)
+          .join("\n");
+
         row.innerHTML = `
           <td>${idx + 1}.</td>
-          <td class="host">${url}</td>
+          <td class="host">${url}${cached ? " (cached)" : ""}</td>
           <td class="">${(latency / 1000).toFixed(1)}</td>
           <td class="">${efficiency}</td>
           <td class="">${capacity}</td>
           <td class="">${requests}</td>
           <td class="positive">${positive}</td>
           <td class="negative">${negative}</td>
+          <td class="negative">${redirects}</td>
+          <td class="">${Math.round(sent / 1024)} / ${Math.round(received / 1024)}</td>
+          <td>${bans && bans[url]
+            ? `<a href="#" onclick="restoreProxy('${url}'); return false;">restore</a>`
+            : `<a href="#" onclick="banProxy('${url}'); return false;">ban</a>`}</td>
         `;
         t.appendChild(row);
       });
   }
 }
 
-function handleLog(text) {
+function handleHistogram(buckets) {
+  const max = Math.max(1, ...buckets.map(({ count }) => count));
+
+  document.getElementById("histogram").innerHTML = buckets
+    .map(
+      ({ label, count }) => `
+        <div class="bucket">
+          <span class="label">${label}</span>
+          <span class="bar" style="width: ${(count * 100) / max}%"></span>
+          <span class="number">${count}</span>
+        </div>
+      `
+    )
+    .join("");
+}
+
+// Token sent to the routes changing the worker's state, given as ?token= in the page URL.
+const adminToken = new URLSearchParams(location.search).get("token");
+
+function adminFetch(path, method) {
+  const headers = adminToken ? { Authorization: `Bearer ${adminToken}` } : {};
+  fetch(path, { method, headers });
+}
+
+function banProxy(url) {
+  adminFetch(`/api/bans?url=${encodeURIComponent(url)}`, "POST");
+}
+
+function restoreProxy(url) {
+  adminFetch(`/api/bans?url=${encodeURIComponent(url)}`, "DELETE");
+}
+
+function handleLog(text, level = "info") {
   const l = document.getElementById("log");
   const p = document.createElement("p");
   p.innerText = text;
+  p.className = level;
   l.insertBefore(p, l.firstChild);
 }
 
+// Switches the log between all messages and errors only by reconnecting
+function toggleErrorsOnly(checked) {
+  localStorage.setItem("errorsOnly", checked);
+  socket.close();
+}
+
 // Returns the current time
 function now() {
   let now = new Date();
@@ -0,0 +1,94 @@
+// Decodes the MessagePack payloads sent to clients negotiating the "msgpack" subprotocol.
+function decodeMsgpack(buffer) {
+  const view = new DataView(buffer);
+  const utf8 = new TextDecoder();
+  let pos = 0;
+
+  function str(n) {
+    const s = utf8.decode(new Uint8Array(buffer, pos, n));
+    pos += n;
+    return s;
+  }
+
+  function arr(n) {
+    const a = new Array(n);
+    for (let i = 0; i < n; i++) a[i] = next();
+    return a;
+  }
+
+  function map(n) {
+    const m = {};
+    for (let i = 0; i < n; i++) {
+      const k = next();
+      m[k] = next();
+    }
+    return m;
+  }
+
+  function next() {
+    const b = view.getUint8(pos++);
+    let v;
+
+    if (b <= 0x7f) return b;
+    if (b >= 0xe0) return b - 0x100;
+    if ((b & 0xf0) === 0x80) return map(b & 0x0f);
+    if ((b & 0xf0) === 0x90) return arr(b & 0x0f);
+    if ((b & 0xe0) === 0xa0) return str(b & 0x1f);
+
+    switch (b) {
+      case 0xc0:
+        return null;
+      case 0xc2:
+        return false;
+      case 0xc3:
+        return true;
+      case 0xcb:
+        v = view.getFloat64(pos);
+        pos += 8;
+        return v;
+      case 0xd0:
+        return view.getInt8(pos++);
+      case 0xd1:
+        v = view.getInt16(pos);
+        pos += 2;
+        return v;
+      case 0xd2:
+        v = view.getInt32(pos);
+        pos += 4;
+        return v;
+      case 0xd3:
+        v = Number(view.getBigInt64(pos));
+        pos += 8;
+        return v;
+      case 0xd9:
+        return str(view.getUint8(pos++));
+      case 0xda:
+        v = view.getUint16(pos);
+        pos += 2;
+        return str(v);
+      case 0xdb:
+        v = view.getUint32(pos);
+        pos += 4;
+        return str(v);
+      case 0xdc:
+        v = view.getUint16(pos);
+        pos += 2;
+        return arr(v);
+      case 0xdd:
+        v = view.getUint32(pos);
+        pos += 4;
+        return arr(v);
+      case 0xde:
+        v = view.getUint16(pos);
+        pos += 2;
+        return map(v);
+      case 0xdf:
+        v = view.getUint32(pos);
+        pos += 4;
+        return map(v);
+    }
+    throw new Error(`Unsupported MessagePack type 0x${b.toString(16)}`);
+  }
+
+  return next();
+}
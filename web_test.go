@@ -0,0 +1,102 @@
+package httptines
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Web", func() {
+	Describe("resultsStreamHandler()", func() {
+		It("streams results as NDJSON", func() {
+			wk := &Worker{quit: make(chan struct{})}
+			srv := httptest.NewServer(resultsStreamHandler(wk))
+			defer srv.Close()
+			defer close(wk.quit)
+
+			resp, err := http.Get(srv.URL)
+			Expect(err).NotTo(HaveOccurred())
+			defer resp.Body.Close()
+			Expect(resp.Header.Get("Content-Type")).To(Equal("application/x-ndjson"))
+
+			wk.results.publish(Result{URL: "http://test1.com", Status: 200, Latency: 20 * time.Millisecond, Attempts: 1, Body: []byte("ok")})
+
+			line, err := bufio.NewReader(resp.Body).ReadBytes('\n')
+			Expect(err).NotTo(HaveOccurred())
+
+			var res streamedResult
+			Expect(json.Unmarshal(line, &res)).To(Succeed())
+			Expect(res).To(Equal(streamedResult{URL: "http://test1.com", Status: 200, Latency: 20, Attempts: 1, Body: "ok"}))
+		})
+	})
+
+	Describe("statsHandler()", func() {
+		It("returns the statistics with the build info", func() {
+			wk := &Worker{stat: &Stat{Targets: 10, Build: BuildInfo{Version: "v1.2.3", GoVersion: "go1.24.1"}}}
+			rec := httptest.NewRecorder()
+			statsHandler(wk)(rec, httptest.NewRequest(http.MethodGet, "/api/stats", nil))
+
+			var body map[string]any
+			Expect(json.Unmarshal(rec.Body.Bytes(), &body)).To(Succeed())
+			Expect(body).To(HaveKeyWithValue("targets", 10.0))
+			Expect(body).To(HaveKeyWithValue("build", map[string]any{"version": "v1.2.3", "goVersion": "go1.24.1"}))
+		})
+	})
+
+	Describe("adminHandler()", func() {
+		var (
+			wk     *Worker
+			called bool
+		)
+
+		serve := func(r *http.Request) int {
+			rec := httptest.NewRecorder()
+			adminHandler(wk, func(http.ResponseWriter, *http.Request) { called = true })(rec, r)
+			return rec.Code
+		}
+		newRequest := func(remote string, header http.Header) *http.Request {
+			r := httptest.NewRequest(http.MethodPost, "http://localhost:8080/api/targets", nil)
+			r.RemoteAddr = remote
+			for k, v := range header {
+				r.Header[k] = v
+			}
+			return r
+		}
+
+		BeforeEach(func() {
+			wk, called = &Worker{}, false
+		})
+
+		It("accepts same-origin requests from localhost without a token", func() {
+			Expect(serve(newRequest("127.0.0.1:5000", http.Header{"Origin": {"http://localhost:8080"}}))).To(Equal(http.StatusOK))
+			Expect(serve(newRequest("[::1]:5000", nil))).To(Equal(http.StatusOK))
+			Expect(called).To(BeTrue())
+		})
+
+		It("rejects remote requests without a token", func() {
+			Expect(serve(newRequest("203.0.113.1:5000", nil))).To(Equal(http.StatusForbidden))
+			Expect(called).To(BeFalse())
+		})
+
+		It("rejects cross-origin requests", func() {
+			Expect(serve(newRequest("127.0.0.1:5000", http.Header{"Origin": {"http://evil.com"}}))).To(Equal(http.StatusForbidden))
+			Expect(serve(newRequest("127.0.0.1:5000", http.Header{"Sec-Fetch-Site": {"cross-site"}}))).To(Equal(http.StatusForbidden))
+			Expect(called).To(BeFalse())
+		})
+
+		It("requires the token if set", func() {
+			wk.AdminToken = "secret"
+			Expect(serve(newRequest("127.0.0.1:5000", nil))).To(Equal(http.StatusUnauthorized))
+			Expect(serve(newRequest("203.0.113.1:5000", http.Header{"Authorization": {"Bearer wrong"}}))).To(Equal(http.StatusUnauthorized))
+			Expect(called).To(BeFalse())
+
+			Expect(serve(newRequest("203.0.113.1:5000", http.Header{"Authorization": {"Bearer secret"}}))).To(Equal(http.StatusOK))
+			Expect(called).To(BeTrue())
+		})
+	})
+})